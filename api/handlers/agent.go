@@ -63,14 +63,31 @@ func AgentAskHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	cfg := agentcore.Config{
-		XMCP:      os.Getenv("X_MCP_HTTP"),
-		WalletMCP: os.Getenv("WALLET_MCP_HTTP"),
-		BNBMCP:    os.Getenv("BNB_MCP_HTTP"),
-		SolanaMCP: os.Getenv("SOLANA_MCP_HTTP"),
-		Model:     os.Getenv("OPENAI_MODEL"),
+		XMCP:        os.Getenv("X_MCP_HTTP"),
+		WalletMCP:   os.Getenv("WALLET_MCP_HTTP"),
+		SolanaMCP:   os.Getenv("SOLANA_MCP_HTTP"),
+		GoldRushMCP: os.Getenv("GOLDRUSH_MCP_HTTP"),
 	}
+	cfg.LLM, cfg.Fallback = agentcore.LLMConfigFromEnv()
+	cfg.Mode = os.Getenv("AGENT_MODE")
+	policy, err := agentcore.PolicyFromEnv()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(ErrorResponse{Error: "invalid tool policy: " + err.Error()})
+		return
+	}
+	cfg.Policy = policy
+	registry, err := agentcore.RegistryFromEnv()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(ErrorResponse{Error: "invalid MCP server registry: " + err.Error()})
+		return
+	}
+	cfg.Registry = registry
 	agentcore.CreateWalletForTwitterIDWithConfig(r.Context(), twitterID, cfg)
-	out, err := agentcore.AskAgent(r.Context(), req.Input, twitterID, "", "", cfg)
+	out, err := agentcore.AskAgent(r.Context(), req.Input, twitterID, "", nil, cfg, agentcore.WithToolContext(agentcore.ContextAPI))
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -2,16 +2,24 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	agentcore "cg-mentions-bot/internal/agentcore"
+	"cg-mentions-bot/internal/shadow"
+	"cg-mentions-bot/internal/traces"
+	"cg-mentions-bot/internal/types"
+	"cg-mentions-bot/internal/utils/db"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
 // InputRequest defines the expected JSON body
@@ -60,7 +68,6 @@ func main() {
 
 		xURL := os.Getenv("X_MCP_HTTP")
 		walletMcpUrl := os.Getenv("WALLET_MCP_HTTP")
-		bnbHttpURL := os.Getenv("BNB_MCP_HTTP")
 		if xURL == "" {
 			fmt.Fprintln(os.Stderr, "Set X_MCP_HTTP (e.g., http://localhost:8081/mcp)")
 			os.Exit(1)
@@ -69,9 +76,32 @@ func main() {
 		question := flag.String("q", "", "question to ask the agent (fallback: AGENT_INPUT or stdin)")
 		replyTo := flag.String("reply-to", "", "tweet id to reply under using x_post_reply (optional)")
 		twitterId := flag.String("ti", "", "twitter id of the user that posts it")
-		mentionedUser := flag.String("m", "", "mentioned user that is in the tweet")
+		var mentioned mentionFlag
+		flag.Var(&mentioned, "m", "mentioned user as twitter_id or handle=twitter_id; repeat for several users")
+		mentionedJSON := flag.String("mentioned", "", `JSON array of mentioned users, e.g. [{"username":"alice","id":"123"}] (optional)`)
+		historyJSON := flag.String("history", "", "JSON array of earlier tweets in the thread, oldest first (optional)")
+		memoryBlock := flag.String("memory", "", "summary of what the bot remembers about the user (optional)")
+		shadowMode := flag.Bool("shadow", strings.EqualFold(os.Getenv("SHADOW_MODE"), "on"), "record replies and transfers instead of executing them (default from SHADOW_MODE)")
+		jsonOut := flag.Bool("json", false, "print a JSON result (answer, tools_used, tx_hashes, posted, error) instead of plain text")
 		flag.Parse()
 
+		if strings.TrimSpace(*mentionedJSON) != "" {
+			var more []types.MentionedUser
+			if err := json.Unmarshal([]byte(*mentionedJSON), &more); err != nil {
+				fmt.Fprintln(os.Stderr, "invalid -mentioned:", err)
+				os.Exit(1)
+			}
+			mentioned = append(mentioned, more...)
+		}
+
+		var history []types.Turn
+		if strings.TrimSpace(*historyJSON) != "" {
+			if err := json.Unmarshal([]byte(*historyJSON), &history); err != nil {
+				fmt.Fprintln(os.Stderr, "invalid -history:", err)
+				os.Exit(1)
+			}
+		}
+
 		q := strings.TrimSpace(*question)
 		if q == "" {
 			if v := strings.TrimSpace(os.Getenv("AGENT_INPUT")); v != "" {
@@ -88,45 +118,131 @@ func main() {
 
 		// Build agentcore config from env
 		cfg := agentcore.Config{
-			XMCP:      xURL,
-			WalletMCP: walletMcpUrl,
-			BNBMCP:    bnbHttpURL,
-			Model:     os.Getenv("OPENAI_MODEL"),
+			XMCP:        xURL,
+			WalletMCP:   walletMcpUrl,
+			SolanaMCP:   os.Getenv("SOLANA_MCP_HTTP"),
+			GoldRushMCP: os.Getenv("GOLDRUSH_MCP_HTTP"),
 		}
-
-		// Create wallet for the user if twitter_id is provided (preserve behavior)
-		fmt.Println("Users twitter id", *twitterId)
-		if strings.TrimSpace(*twitterId) != "" {
-			if err := agentcore.CreateWalletForTwitterIDWithConfig(context.Background(), strings.TrimSpace(*twitterId), cfg); err != nil {
-				fmt.Fprintln(os.Stderr, "failed to create wallet:", err)
+		cfg.LLM, cfg.Fallback = agentcore.LLMConfigFromEnv()
+		cfg.Mode = os.Getenv("AGENT_MODE")
+		policy, err := agentcore.PolicyFromEnv()
+		if err != nil {
+			fail(*jsonOut, types.AgentResult{}, fmt.Errorf("invalid tool policy: %w", err))
+		}
+		cfg.Policy = policy
+		registry, err := agentcore.RegistryFromEnv()
+		if err != nil {
+			fail(*jsonOut, types.AgentResult{}, fmt.Errorf("invalid MCP server registry: %w", err))
+		}
+		cfg.Registry = registry
+
+		// With MONGO_URI, shadow calls and traces land where the bot can serve them.
+		var mongoClient *mongo.Client
+		if uri := os.Getenv("MONGO_URI"); uri != "" {
+			c, err := db.ConnectToDB(uri)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "mongo unavailable, shadow calls and traces are not stored:", err)
+			} else {
+				mongoClient = c
+			}
+		}
+		if *shadowMode {
+			cfg.Shadow = shadowRecorder(mongoClient)
+		}
+		if mongoClient != nil && !strings.EqualFold(os.Getenv("TRACES"), "off") {
+			ts, err := traces.NewMongoStore(mongoClient, traces.DefaultTTL)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "failed to init trace store:", err)
+			} else {
+				cfg.Traces = ts
 			}
 		}
 
-		// Ask using the same prompt logic (reply or non-reply)
-		ctx := context.Background()
-		answer, err := agentcore.AskAgent(ctx, q, strings.TrimSpace(*twitterId), strings.TrimSpace(*replyTo), strings.TrimSpace(*mentionedUser), cfg)
+		fmt.Fprintln(os.Stderr, "Users twitter id", *twitterId)
+		a, err := agentcore.NewAgent(cfg)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, err.Error())
-			os.Exit(1)
+			fail(*jsonOut, types.AgentResult{}, err)
 		}
 
-		// If reply target provided, truncate then post via X
-		if strings.TrimSpace(*replyTo) != "" {
-			if answer == "" {
-				fmt.Fprintln(os.Stderr, "agent produced empty answer; cannot post to X")
-				os.Exit(1)
-			}
-			runes := []rune(answer)
-			const maxTweetLen = 270
-			if len(runes) > maxTweetLen {
-				answer = string(runes[:maxTweetLen]) + "…"
-			}
-			if err := agentcore.TweetOnly(ctx, strings.TrimSpace(*replyTo), answer, cfg); err != nil {
-				fmt.Fprintln(os.Stderr, "failed to post via X:", err)
-				os.Exit(1)
-			}
+		// Creates the user's wallet, answers, and posts under -reply-to unless the agent already replied
+		res, err := a.Run(context.Background(), types.AgentRequest{
+			Question:        q,
+			ReplyTo:         strings.TrimSpace(*replyTo),
+			TwitterID:       strings.TrimSpace(*twitterId),
+			MentionedPeople: mentioned,
+			History:         history,
+			Memory:          *memoryBlock,
+		})
+		if err != nil {
+			fail(*jsonOut, res, err)
+		}
+
+		if *jsonOut {
+			_ = json.NewEncoder(os.Stdout).Encode(res)
+			return
 		}
+		fmt.Println(res.Answer)
+	}
+}
+
+// shadowRecorder stores intercepted calls in Mongo when a client is available;
+// otherwise they are only printed to stderr.
+func shadowRecorder(client *mongo.Client) *shadow.Recorder {
+	rec := &shadow.Recorder{Store: stderrShadow{}}
+	if client == nil {
+		return rec
+	}
+	s, err := shadow.NewMongoStore(client, 7*24*time.Hour)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "shadow: failed to init store, printing calls only:", err)
+		return rec
+	}
+	rec.Store = s
+	return rec
+}
+
+type stderrShadow struct{}
 
-		fmt.Println(answer)
+func (stderrShadow) Add(ctx context.Context, e shadow.Entry) error {
+	b, _ := json.Marshal(e)
+	fmt.Fprintln(os.Stderr, "shadow:", string(b))
+	return nil
+}
+
+func (stderrShadow) List(ctx context.Context, f shadow.Filter) ([]shadow.Entry, error) {
+	return nil, nil
+}
+
+// fail reports err on stderr, or as a JSON result with -json, and exits.
+func fail(jsonOut bool, res types.AgentResult, err error) {
+	if jsonOut {
+		res.Error = err.Error()
+		_ = json.NewEncoder(os.Stdout).Encode(res)
+	}
+	fmt.Fprintln(os.Stderr, err.Error())
+	os.Exit(1)
+}
+
+// mentionFlag collects repeated -m values ("123" or "alice=123").
+type mentionFlag []types.MentionedUser
+
+func (f *mentionFlag) String() string {
+	parts := make([]string, 0, len(*f))
+	for _, u := range *f {
+		parts = append(parts, u.Username+"="+u.ID)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f *mentionFlag) Set(v string) error {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil
+	}
+	handle, id, ok := strings.Cut(v, "=")
+	if !ok {
+		handle, id = "", v
 	}
+	*f = append(*f, types.MentionedUser{Username: strings.TrimPrefix(handle, "@"), ID: id})
+	return nil
 }
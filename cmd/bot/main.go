@@ -1,16 +1,35 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"cg-mentions-bot/internal/agent"
+	"cg-mentions-bot/internal/agentcore"
 	"cg-mentions-bot/internal/cg"
+	"cg-mentions-bot/internal/conversation"
 	"cg-mentions-bot/internal/handlers"
 	"cg-mentions-bot/internal/httpserver"
+	"cg-mentions-bot/internal/intents"
+	"cg-mentions-bot/internal/ledger"
+	"cg-mentions-bot/internal/memory"
+	"cg-mentions-bot/internal/queue"
+	"cg-mentions-bot/internal/ratelimit"
+	"cg-mentions-bot/internal/shadow"
+	"cg-mentions-bot/internal/traces"
 	"cg-mentions-bot/internal/twitter"
+	"cg-mentions-bot/internal/users"
+	"cg-mentions-bot/internal/utils/db"
+	"cg-mentions-bot/internal/webhook"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"golang.org/x/time/rate"
 )
 
 func main() {
@@ -35,12 +54,271 @@ func main() {
 	ask := cg.NewAsker(mcpCmd, mcpTool)
 	reply := twitter.NewPoster(baseURL, bearerToken)
 
-	handler := handlers.MentionsHandler{Secret: webhookSecret}
-	if agentCmd != "" {
+	var mongoClient *mongo.Client
+	if uri := os.Getenv("MONGO_URI"); uri != "" {
+		c, err := db.ConnectToDB(uri)
+		if err != nil {
+			log.Fatalf("failed to connect to mongo: %v", err)
+		}
+		mongoClient = c
+	}
+
+	// Agent traces (prompt, tool calls, token usage) per tweet; TRACES=off disables them.
+	var traceStore traces.Store
+	if getEnv("TRACES", "on") != "off" {
+		if mongoClient != nil {
+			ts, err := traces.NewMongoStore(mongoClient, getEnvDuration("TRACE_TTL", traces.DefaultTTL))
+			if err != nil {
+				log.Fatalf("failed to init trace store: %v", err)
+			}
+			traceStore = ts
+		} else {
+			traceStore = traces.NewMemoryStore()
+		}
+	}
+
+	handler := handlers.MentionsHandler{
+		Secret:         webhookSecret,
+		Concurrency:    getEnvInt("MENTIONS_CONCURRENCY", 4),
+		MentionTimeout: getEnvDuration("MENTION_TIMEOUT", 0),
+		Traces:         traceStore,
+	}
+	// Reply also carries throttle notices when the agent posts its own answers.
+	handler.Reply = reply
+
+	// SHADOW_MODE=on runs against live traffic without posting or moving funds:
+	// replies and transfers are recorded (GET /shadow/replies) and simulated.
+	var shadowRec *shadow.Recorder
+	if strings.EqualFold(os.Getenv("SHADOW_MODE"), "on") {
+		log.Printf("shadow mode: replies and transfers are recorded, not executed")
+		shadowRec = &shadow.Recorder{}
+		handler.Reply = handlers.ShadowReply(shadowRec)
+	}
+	// MCP_SERVERS_FILE lists more servers; edits are picked up without a restart.
+	registry, err := agentcore.RegistryFromEnv()
+	if err != nil {
+		log.Fatalf("invalid MCP server registry: %v", err)
+	}
+	if registry != nil {
+		go registry.Watch(context.Background(), getEnvDuration("MCP_SERVERS_RELOAD", agentcore.DefaultReloadInterval))
+	}
+	switch {
+	case os.Getenv("AGENT_RUNNER") == "inprocess":
+		// One shared agent: MCP connections, tools and the LLM client are reused across mentions.
+		cfg := agentcore.Config{
+			XMCP:        os.Getenv("X_MCP_HTTP"),
+			WalletMCP:   os.Getenv("WALLET_MCP_HTTP"),
+			SolanaMCP:   getEnv("AGENT_SOLANA_MCP_HTTP", os.Getenv("SOLANA_MCP_HTTP")),
+			GoldRushMCP: getEnv("AGENT_GOLDRUSH_MCP_HTTP", os.Getenv("GOLDRUSH_MCP_HTTP")),
+			Shadow:      shadowRec,
+			Traces:      traceStore,
+		}
+		cfg.LLM, cfg.Fallback = agentcore.LLMConfigFromEnv()
+		cfg.Mode = os.Getenv("AGENT_MODE")
+		policy, err := agentcore.PolicyFromEnv()
+		if err != nil {
+			log.Fatalf("invalid tool policy: %v", err)
+		}
+		cfg.Policy = policy
+		cfg.Registry = registry
+		// Discovered tools are reused for MCP_TOOLS_TTL; servers that fail a
+		// health check are left out until they answer again.
+		cfg.ToolsTTL = getEnvDuration("MCP_TOOLS_TTL", agentcore.DefaultToolsTTL)
+		a, err := agentcore.NewAgent(cfg)
+		if err != nil {
+			log.Fatalf("failed to init agent: %v", err)
+		}
+		if err := a.Warm(); err != nil {
+			log.Printf("agent tool discovery incomplete, unavailable servers are retried in the background: %v", err)
+		}
+		go a.Monitor(context.Background(), getEnvDuration("MCP_HEALTH_INTERVAL", agentcore.DefaultHealthInterval))
+		handler.AgentRun = agent.NewInProcessRunner(a)
+	case agentCmd != "":
+		// The agent process inherits SHADOW_MODE and MONGO_URI and records its own calls and traces.
 		handler.AgentRun = agent.NewRunner(agentCmd)
-	} else {
+	default:
 		handler.Ask = ask
-		handler.Reply = reply
+	}
+
+	// Wallet commands run directly against the wallet MCP instead of through the
+	// agent, on the same "wallet" server the agent resolves.
+	walletCfg := agentcore.Config{WalletMCP: os.Getenv("WALLET_MCP_HTTP"), Registry: registry}
+	if wallet, ok := agentcore.NewServerClient(walletCfg, agentcore.ServerWallet); ok && getEnv("COMMANDS", "on") != "off" {
+		handler.Wallet = wallet
+		handler.DefaultChain = getEnv("COMMANDS_DEFAULT_CHAIN", "bnb")
+		if shadowRec != nil {
+			handler.Wallet = handlers.ShadowWallet{Tools: handler.Wallet, Recorder: shadowRec}
+		}
+	}
+
+	if shadowRec != nil {
+		if mongoClient != nil {
+			ss, err := shadow.NewMongoStore(mongoClient, getEnvDuration("SHADOW_TTL", 7*24*time.Hour))
+			if err != nil {
+				log.Fatalf("failed to init shadow store: %v", err)
+			}
+			shadowRec.Store = ss
+		} else {
+			shadowRec.Store = shadow.NewMemoryStore(0)
+		}
+		handler.Shadow = shadowRec.Store
+	}
+
+	// Signed /mentions requests (timestamp + nonce + HMAC of the body); several secrets may be active for rotation.
+	if secrets := splitList(os.Getenv("WEBHOOK_SIGNING_SECRETS")); len(secrets) > 0 {
+		var nonces webhook.NonceStore = webhook.NewMemoryNonces()
+		if mongoClient != nil {
+			n, err := webhook.NewMongoNonces(mongoClient)
+			if err != nil {
+				log.Fatalf("failed to init webhook nonce store: %v", err)
+			}
+			nonces = n
+		}
+		handler.Verifier = &webhook.Verifier{
+			Secrets:   secrets,
+			Tolerance: getEnvDuration("WEBHOOK_SIGNATURE_TOLERANCE", 5*time.Minute),
+			Nonces:    nonces,
+		}
+	}
+
+	// Thread history for follow-up questions; earlier tweets come from the store or,
+	// for threads the bot has not seen yet, from X recent search.
+	convos := &conversation.Service{MaxTurns: getEnvInt("CONVERSATION_MAX_TURNS", 8)}
+	if mongoClient != nil {
+		cs, err := conversation.NewMongoStore(mongoClient)
+		if err != nil {
+			log.Fatalf("failed to init conversation store: %v", err)
+		}
+		convos.Store = cs
+	} else {
+		convos.Store = conversation.NewMemoryStore()
+	}
+	if readToken := getEnv("X_READ_BEARER_TOKEN", bearerToken); readToken != "" {
+		convos.Fetcher = &conversation.XFetcher{BaseURL: baseURL, Bearer: readToken, BotUserID: os.Getenv("X_BOT_USER_ID")}
+	}
+	handler.Conversations = convos
+
+	// @handle → user id resolution for mentioned users (batched lookups, LRU + Mongo cache).
+	usersTTL := getEnvDuration("USERS_CACHE_TTL", users.DefaultTTL)
+	var usersCache users.Cache
+	if mongoClient != nil {
+		uc, err := users.NewMongoCache(mongoClient, usersTTL)
+		if err != nil {
+			log.Fatalf("failed to init users cache: %v", err)
+		}
+		usersCache = uc
+	}
+	resolver := users.NewResolver(baseURL, getEnv("X_READ_BEARER_TOKEN", getEnv("XAUTH_TOKEN", bearerToken)), usersCache, usersTTL)
+	resolver.BotUserID = os.Getenv("X_BOT_USER_ID")
+	resolver.BotUsername = os.Getenv("X_BOT_USERNAME")
+	handler.Users = resolver
+
+	// Per-user memory (recent questions, addresses, preferred chain); MEMORY=off disables it.
+	if getEnv("MEMORY", "on") != "off" {
+		if mongoClient != nil {
+			handler.Memory = &memory.Service{Store: memory.NewMongoStore(mongoClient)}
+		} else {
+			handler.Memory = &memory.Service{Store: memory.NewMemoryStore()}
+		}
+	}
+
+	// Wallet command sends above CONFIRM_ABOVE (native units, e.g. 0.05) wait for "confirm <code>".
+	if above := strings.TrimSpace(os.Getenv("CONFIRM_ABOVE")); above != "" && handler.Wallet != nil {
+		threshold, err := handlers.ParseNativeAmount(above)
+		if err != nil {
+			log.Fatalf("invalid CONFIRM_ABOVE=%q: %v", above, err)
+		}
+		handler.ConfirmAbove = threshold
+		handler.ConfirmWindow = getEnvDuration("CONFIRM_WINDOW", intents.DefaultWindow)
+		if mongoClient != nil {
+			is, err := intents.NewMongoStore(mongoClient)
+			if err != nil {
+				log.Fatalf("failed to init intent store: %v", err)
+			}
+			handler.Intents = is
+		} else {
+			handler.Intents = intents.NewMemoryStore()
+		}
+	}
+
+	// Rate limits in front of the agent: per-author buckets, a global budget and a
+	// block/allow list. RATE_LIMIT=off disables them.
+	if getEnv("RATE_LIMIT", "on") != "off" {
+		limiter := &ratelimit.Limiter{
+			PerUser:        rate.Limit(float64(getEnvInt("RATE_LIMIT_PER_USER", 10)) / time.Hour.Seconds()),
+			Burst:          getEnvInt("RATE_LIMIT_BURST", 3),
+			Global:         rate.NewLimiter(rate.Limit(float64(getEnvInt("RATE_LIMIT_GLOBAL", 60))/time.Minute.Seconds()), getEnvInt("RATE_LIMIT_GLOBAL", 60)),
+			NoticeCooldown: getEnvDuration("RATE_LIMIT_NOTICE_COOLDOWN", time.Hour),
+		}
+		if mongoClient != nil {
+			limiter.Lists = ratelimit.NewMongoLists(mongoClient)
+		} else {
+			limiter.Lists = ratelimit.NewMemoryLists()
+		}
+		handler.Limiter = limiter
+		handler.ThrottleMessage = os.Getenv("RATE_LIMIT_MESSAGE")
+	}
+
+	// Every tweet id is recorded so webhook replays are answered from the ledger.
+	handler.AdminToken = os.Getenv("ADMIN_TOKEN")
+	handler.ConsumerSecret = os.Getenv("X_WEBHOOK_CONSUMER_SECRET")
+	dedupTTL := getEnvDuration("MENTIONS_DEDUP_TTL", ledger.DefaultTTL)
+	if mongoClient != nil {
+		l, err := ledger.NewMongoStore(mongoClient, dedupTTL)
+		if err != nil {
+			log.Fatalf("failed to init mention ledger: %v", err)
+		}
+		handler.Ledger = l
+	} else {
+		handler.Ledger = ledger.NewMemoryStore(dedupTTL)
+	}
+
+	// Mentions are queued and answered by a worker pool unless MENTIONS_QUEUE=off.
+	if !strings.EqualFold(getEnv("MENTIONS_QUEUE", "on"), "off") {
+		var store queue.Store
+		if mongoClient != nil {
+			s, err := queue.NewMongoStore(mongoClient)
+			if err != nil {
+				log.Fatalf("failed to init mention queue: %v", err)
+			}
+			store = s
+		} else {
+			log.Printf("MONGO_URI not set; mention queue is in-memory and not durable")
+			store = queue.NewMemoryStore()
+		}
+		handler.Queue = store
+		pool := &queue.Pool{
+			Store:       store,
+			Process:     handler.Process,
+			Workers:     getEnvInt("MENTIONS_WORKERS", 4),
+			MaxAttempts: getEnvInt("MENTIONS_MAX_ATTEMPTS", 3),
+			Backoff:     getEnvDuration("MENTIONS_RETRY_BACKOFF", 5*time.Second),
+		}
+		go pool.Run(context.Background())
+	}
+
+	// Optionally read mentions straight from the X API instead of waiting for n8n.
+	if strings.EqualFold(os.Getenv("MENTIONS_POLLER"), "on") {
+		botUserID := os.Getenv("X_BOT_USER_ID")
+		readToken := getEnv("X_READ_BEARER_TOKEN", bearerToken)
+		if botUserID == "" || readToken == "" {
+			log.Fatal("MENTIONS_POLLER=on requires X_BOT_USER_ID and X_BEARER_TOKEN (or X_READ_BEARER_TOKEN)")
+		}
+		var checkpoints twitter.CheckpointStore = twitter.NewMemoryCheckpoints()
+		if mongoClient != nil {
+			checkpoints = twitter.NewMongoCheckpoints(mongoClient)
+		} else {
+			log.Printf("MONGO_URI not set; poller checkpoint is in-memory")
+		}
+		poller := &twitter.Poller{
+			BaseURL:     baseURL,
+			Bearer:      readToken,
+			UserID:      botUserID,
+			Checkpoints: checkpoints,
+			Handle:      handler.Submit,
+			MinInterval: getEnvDuration("MENTIONS_POLL_INTERVAL", 20*time.Second),
+		}
+		go poller.Run(context.Background())
 	}
 
 	srv := httpserver.NewServer(port, handler)
@@ -56,3 +334,40 @@ func getEnv(key, def string) string {
 	}
 	return def
 }
+
+func getEnvInt(key string, def int) int {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		log.Printf("invalid %s=%q, using %d", key, v, def)
+		return def
+	}
+	return n
+}
+
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(v))
+	if err != nil {
+		log.Printf("invalid %s=%q, using %s", key, v, def)
+		return def
+	}
+	return d
+}
+
+// splitList parses a comma-separated env value, dropping blanks.
+func splitList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -16,7 +16,9 @@ require (
 	github.com/swaggo/swag v1.16.6
 	github.com/tmc/langchaingo v0.1.13
 	go.mongodb.org/mongo-driver/v2 v2.3.0
+	golang.org/x/time v0.11.0
 	google.golang.org/api v0.231.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -146,7 +148,6 @@ require (
 	golang.org/x/sync v0.16.0 // indirect
 	golang.org/x/sys v0.34.0 // indirect
 	golang.org/x/text v0.27.0 // indirect
-	golang.org/x/time v0.11.0 // indirect
 	golang.org/x/tools v0.34.0 // indirect
 	google.golang.org/appengine/v2 v2.0.6 // indirect
 	google.golang.org/genproto v0.0.0-20250505200425-f936aa4a68b2 // indirect
@@ -154,5 +155,4 @@ require (
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250505200425-f936aa4a68b2 // indirect
 	google.golang.org/grpc v1.72.0 // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
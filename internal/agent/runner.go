@@ -3,25 +3,51 @@ package agent
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
+
+	"cg-mentions-bot/internal/agentcore"
+	"cg-mentions-bot/internal/types"
 )
 
-// Runner invokes the agent executable with a question and optional reply-to tweet id.
-type Runner func(ctx context.Context, question string, replyTo string, twitterId string, mentionedPeople []string) (string, error)
+// Runner answers (and replies to) one mention and reports what the agent did.
+type Runner func(ctx context.Context, req types.AgentRequest) (types.AgentResult, error)
+
+// NewInProcessRunner runs the agent inside the bot process, sharing the agent's
+// warmed MCP connections, tools and LLM client across mentions.
+func NewInProcessRunner(a *agentcore.Agent) Runner {
+	return a.Run
+}
 
-// NewRunner constructs a Runner for the given agent command. It inherits the current
-// process environment and optionally overrides common agent envs if set:
+// NewRunner constructs a Runner that forks the given agent command per mention,
+// for isolation. It inherits the current process environment and optionally
+// overrides common agent envs if set:
 //   - AGENT_CG_MCP_HTTP, X_MCP_HTTP, OPENAI_API_KEY, OPENAI_MODEL
 func NewRunner(agentCmd string) Runner {
-	return func(ctx context.Context, question string, replyTo string, twitterId string, mentionedPeople []string) (string, error) {
-		args := []string{"-q", question, "-ti", twitterId}
-		if len(mentionedPeople) > 0 {
-			args = append(args, "-m", mentionedPeople[0])
+	return func(ctx context.Context, req types.AgentRequest) (types.AgentResult, error) {
+		args := []string{"-json", "-q", req.Question, "-ti", req.TwitterID}
+		if len(req.MentionedPeople) > 0 {
+			mentioned, err := json.Marshal(req.MentionedPeople)
+			if err != nil {
+				return types.AgentResult{}, fmt.Errorf("encode mentioned users: %w", err)
+			}
+			args = append(args, "-mentioned", string(mentioned))
+		}
+		if req.ReplyTo != "" {
+			args = append(args, "-reply-to", req.ReplyTo)
 		}
-		if replyTo != "" {
-			args = append(args, "-reply-to", replyTo)
+		if len(req.History) > 0 {
+			history, err := json.Marshal(req.History)
+			if err != nil {
+				return types.AgentResult{}, fmt.Errorf("encode history: %w", err)
+			}
+			args = append(args, "-history", string(history))
+		}
+		if req.Memory != "" {
+			args = append(args, "-memory", req.Memory)
 		}
 		cmd := exec.CommandContext(ctx, agentCmd, args...)
 		// Inherit env, apply optional overrides
@@ -38,9 +64,6 @@ func NewRunner(agentCmd string) Runner {
 		if v := os.Getenv("AGENT_BNB_AGENT_MCP_SSE"); v != "" {
 			env = append(env, fmt.Sprintf("BNB_AGENT_MCP_SSE=%s", v))
 		}
-		if v := os.Getenv("BNB_MCP_HTTP"); v != "" {
-			env = append(env, fmt.Sprintf("BNB_MCP_HTTP=%s", v))
-		}
 		if v := os.Getenv("AGENT_SOLANA_MCP_HTTP"); v != "" {
 			env = append(env, fmt.Sprintf("SOLANA_MCP_HTTP=%s", v))
 		}
@@ -59,14 +82,24 @@ func NewRunner(agentCmd string) Runner {
 		cmd.Stderr = &errBuf
 		err := cmd.Run()
 		stdout := outBuf.String()
+
+		// The agent prints a JSON result with -json; anything else is treated as the answer.
+		var res types.AgentResult
+		jerr := json.Unmarshal(bytes.TrimSpace(outBuf.Bytes()), &res)
+		if jerr != nil {
+			res = types.AgentResult{Answer: strings.TrimSpace(stdout)}
+		}
 		if err != nil {
-			// Include both stdout and stderr in the error for better diagnostics via the bot
-			if stdout != "" {
-				return stdout, fmt.Errorf("agent error: %v; stdout: %q; stderr: %q", err, stdout, errBuf.String())
+			if jerr != nil {
+				// The agent died without reporting what it did; it may have posted
+				// or transferred already.
+				res.SideEffects = true
+			}
+			if res.Error != "" {
+				return res, fmt.Errorf("agent error: %s; stderr: %q", res.Error, errBuf.String())
 			}
-			print(err)
-			return "", fmt.Errorf("agent error: %v; stdout: %q; stderr: %q", err, stdout, errBuf.String())
+			return res, fmt.Errorf("agent error: %v; stdout: %q; stderr: %q", err, stdout, errBuf.String())
 		}
-		return stdout, nil
+		return res, nil
 	}
 }
@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cg-mentions-bot/internal/agent"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeAgent(t *testing.T, script string) string {
+	path := filepath.Join(t.TempDir(), "agent")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSubprocessRunner(t *testing.T) {
+	t.Run("The JSON result of the agent binary is returned as is", func(t *testing.T) {
+		run := agent.NewRunner(fakeAgent(t, `echo '{"answer":"sent","tools_used":["transfer_asset"],"tx_hashes":["0xabc"],"posted":true}'`))
+
+		res, err := run(context.Background(), types.AgentRequest{Question: "send", TwitterID: "1"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, types.AgentResult{Answer: "sent", ToolsUsed: []string{"transfer_asset"}, TxHashes: []string{"0xabc"}, Posted: true}, res)
+	})
+
+	t.Run("Agent failures surface the structured error", func(t *testing.T) {
+		run := agent.NewRunner(fakeAgent(t, `echo '{"answer":"","posted":false,"error":"wallet down"}'; exit 1`))
+
+		_, err := run(context.Background(), types.AgentRequest{Question: "send", TwitterID: "1"})
+
+		assert.ErrorContains(t, err, "wallet down")
+	})
+
+	t.Run("A crash without a JSON result counts as a possible side effect", func(t *testing.T) {
+		run := agent.NewRunner(fakeAgent(t, `echo "panic: boom" >&2; exit 2`))
+
+		res, err := run(context.Background(), types.AgentRequest{Question: "send", TwitterID: "1"})
+
+		assert.Error(t, err)
+		assert.True(t, res.SideEffects)
+	})
+
+	t.Run("Plain text output from older agent binaries is used as the answer", func(t *testing.T) {
+		run := agent.NewRunner(fakeAgent(t, `echo "hello"`))
+
+		res, err := run(context.Background(), types.AgentRequest{Question: "hi", TwitterID: "1"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", res.Answer)
+	})
+
+	t.Run("Every mentioned user is passed to the agent", func(t *testing.T) {
+		argsFile := filepath.Join(t.TempDir(), "args")
+		run := agent.NewRunner(fakeAgent(t, `printf '%s\n' "$@" > `+argsFile+`; echo '{"answer":"ok","posted":true}'`))
+
+		_, err := run(context.Background(), types.AgentRequest{
+			Question:        "split 0.1 BNB between @a and @b",
+			TwitterID:       "1",
+			MentionedPeople: []types.MentionedUser{{Username: "a", ID: "10"}, {Username: "b", ID: "20"}},
+		})
+
+		assert.NoError(t, err)
+		args, _ := os.ReadFile(argsFile)
+		assert.Contains(t, string(args), "-mentioned\n"+`[{"id":"10","username":"a"},{"id":"20","username":"b"}]`)
+	})
+}
@@ -0,0 +1,413 @@
+package agentcore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"cg-mentions-bot/internal/shadow"
+	"cg-mentions-bot/internal/traces"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/tmc/langchaingo/agents"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/tools"
+)
+
+// maxTweetLen is where replies are cut before posting.
+const maxTweetLen = 270
+
+var (
+	txHashRe        = regexp.MustCompile(`\b0x[0-9a-fA-F]{64}\b|\b[1-9A-HJ-NP-Za-km-z]{86,88}\b`)
+	walletAddressRe = regexp.MustCompile(`^(0x[0-9a-fA-F]{40}|[1-9A-HJ-NP-Za-km-z]{32,44})$`)
+)
+
+// Agent is a long-lived agent: the LLM client and MCP connections are created
+// once and discovered tools are cached per server (Config.ToolsTTL). With a
+// registry, servers added, changed or removed in its file are picked up on
+// the next request.
+type Agent struct {
+	cfg   Config
+	model string
+	llm   llms.Model
+
+	mu      sync.Mutex
+	servers []ServerSpec
+	version int // registry version servers was built from
+	x       *mcpClient
+	wallet  *mcpClient
+}
+
+// NewAgent creates the LLM client (cfg.LLM, OpenAI by default) and MCP
+// connections for cfg. Tools are discovered on the first request or by Warm.
+func NewAgent(cfg Config) (*Agent, error) {
+	primary := cfg.LLM
+	if primary.Model == "" {
+		primary.Model = cfg.Model
+	}
+	llm, model, err := newModel(primary, cfg.Fallback)
+	if err != nil {
+		return nil, err
+	}
+	a := &Agent{cfg: cfg, model: model, llm: llm, version: -1}
+	a.configure()
+	return a, nil
+}
+
+// configure rebuilds the server list when the registry changed. The caller holds a.mu, except in NewAgent.
+func (a *Agent) configure() {
+	servers, version := a.cfg.servers()
+	if version == a.version {
+		return
+	}
+	a.servers, a.version = servers, version
+	a.x, a.wallet = nil, nil
+	for _, s := range servers {
+		switch s.Name {
+		case ServerX:
+			a.x = mcpFor(s)
+		case ServerWallet:
+			a.wallet = mcpFor(s)
+		}
+	}
+}
+
+// clients returns the X and wallet servers of the current configuration.
+func (a *Agent) clients() (x, wallet *mcpClient) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.configure()
+	return a.x, a.wallet
+}
+
+// Warm discovers the tools of every configured MCP server. Servers that fail are
+// left out until they answer again; the error lists them.
+func (a *Agent) Warm() error {
+	ctx, cancel := context.WithTimeout(context.Background(), mcpTimeout)
+	defer cancel()
+	_, _, err := a.toolset(ctx)
+	return err
+}
+
+// toolset assembles the tools of every available server from the discovery
+// cache. down names the servers whose tools are missing because they failed.
+// Discovery runs outside a.mu so a slow server does not hold up other requests.
+func (a *Agent) toolset(ctx context.Context) (toolsList []tools.Tool, down []string, err error) {
+	a.mu.Lock()
+	a.configure()
+	servers := a.servers
+	a.mu.Unlock()
+
+	var errs []error
+	taken := map[string]bool{}
+	for _, s := range servers {
+		c := mcpFor(s)
+		if err := c.unavailable(DefaultHealthInterval); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name, err))
+			down = append(down, s.Name)
+			continue
+		}
+		if s.Name == ServerX {
+			// Only posting is exposed from X.
+			toolsList = append(toolsList, xTool{client: c})
+			taken[xTool{}.Name()] = true
+			continue
+		}
+		raw, err := c.cachedTools(ctx, a.cfg.toolsTTL())
+		if err != nil {
+			log.Printf("failed to discover %s MCP tools: %v", s.Name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name, err))
+			down = append(down, s.Name)
+			continue
+		}
+		found := discoveredTools(s, c, raw, taken)
+		if s.Name == ServerGoldRush {
+			found = goldrushTools(found)
+		}
+		toolsList = append(toolsList, found...)
+	}
+	return toolsList, down, errors.Join(errs...)
+}
+
+// Run answers one mention end to end: it makes sure the author has a wallet,
+// asks the agent and, for replies, posts the answer unless the agent already did.
+func (a *Agent) Run(ctx context.Context, req types.AgentRequest) (types.AgentResult, error) {
+	x, wallet := a.clients()
+	if wallet != nil && strings.TrimSpace(req.TwitterID) != "" {
+		if err := a.ensureWallet(ctx, wallet, req); err != nil {
+			log.Printf("failed to create wallet for %s: %v", req.TwitterID, err)
+		}
+	}
+
+	res, err := a.ask(ctx, req.Question, req.TwitterID, req.ReplyTo, a.recipients(ctx, wallet, req.MentionedPeople),
+		WithHistory(req.History), WithMemory(req.Memory), WithToolContext(req.Source))
+	replyTo := strings.TrimSpace(req.ReplyTo)
+	if err != nil || replyTo == "" || res.Posted {
+		return res, err
+	}
+
+	if res.Answer == "" {
+		return res, fmt.Errorf("agent produced empty answer; cannot post to X")
+	}
+	if x == nil && a.cfg.Shadow == nil {
+		return res, fmt.Errorf("X_MCP_HTTP is required")
+	}
+	if runes := []rune(res.Answer); len(runes) > maxTweetLen {
+		res.Answer = string(runes[:maxTweetLen]) + "…"
+	}
+	args := map[string]any{
+		"in_reply_to_tweet_id": replyTo,
+		"text":                 res.Answer,
+	}
+	if a.cfg.Shadow != nil {
+		if _, err := a.cfg.Shadow.Intercept(ctx, replyTo, req.TwitterID, "twitter.post_reply", args); err != nil {
+			log.Printf("shadow: failed to record reply to %s: %v", replyTo, err)
+		}
+	} else if _, err := x.call(ctx, "twitter.post_reply", args); err != nil {
+		// The reply may have gone out before the error; do not post it again.
+		res.SideEffects = true
+		return res, fmt.Errorf("failed to post via X: %w", err)
+	}
+	res.Posted = true
+	return res, nil
+}
+
+// ensureWallet creates the author's wallet. Shadow mode only reads it, and
+// records the creation it would have made when there is none.
+func (a *Agent) ensureWallet(ctx context.Context, wallet *mcpClient, req types.AgentRequest) error {
+	args := map[string]any{"twitter_id": strings.TrimSpace(req.TwitterID)}
+	if a.cfg.Shadow == nil {
+		_, err := wallet.call(ctx, "create_wallet", args)
+		return err
+	}
+	if _, err := wallet.call(ctx, "read_wallet", args); err == nil {
+		return nil
+	}
+	_, err := a.cfg.Shadow.Intercept(ctx, req.ReplyTo, req.TwitterID, "create_wallet", args)
+	return err
+}
+
+// recipients looks up the wallet of every mentioned user so the prompt can map
+// handle -> twitter_id -> wallet. Users without a wallet are left for the agent.
+func (a *Agent) recipients(ctx context.Context, wallet *mcpClient, mentioned []types.MentionedUser) []Recipient {
+	out := make([]Recipient, 0, len(mentioned))
+	for _, u := range mentioned {
+		r := Recipient{Handle: u.Username, TwitterID: u.ID}
+		if wallet != nil && u.ID != "" {
+			if addr, err := wallet.call(ctx, "read_wallet", map[string]any{"twitter_id": u.ID}); err == nil && walletAddressRe.MatchString(strings.TrimSpace(addr)) {
+				r.Wallet = strings.TrimSpace(addr)
+			}
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func (a *Agent) ask(ctx context.Context, question string, twitterID string, replyTo string, mentioned []Recipient, opts ...AskOption) (types.AgentResult, error) {
+	q := strings.TrimSpace(question)
+	if q == "" || strings.TrimSpace(twitterID) == "" {
+		return types.AgentResult{}, fmt.Errorf("input and twitter_id are required")
+	}
+	var o askOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// Discovery errors are logged; answer with whatever tools are available
+	// and tell the agent what is missing.
+	toolsList, down, _ := a.toolset(ctx)
+	o.unavailable = down
+	toolContext := o.toolContext
+	if toolContext == "" {
+		toolContext = ContextMention
+	}
+	if a.cfg.Shadow != nil {
+		toolContext = ContextShadow
+	}
+	toolsList = a.cfg.Policy.filter(toolContext, toolsList)
+	prompt := buildPrompt(q, twitterID, replyTo, mentioned, o)
+	run := traces.Start(strings.TrimSpace(replyTo), twitterID, q, a.model, prompt)
+	ctx = traces.WithRun(ctx, run)
+
+	rec := &recorder{}
+	wrapped := rec.wrap(a.shadowed(toolsList, replyTo, twitterID))
+	var raw string
+	var callErr error
+	if a.cfg.Mode == ModeFunctions {
+		// Native tool calls carry structured arguments, so there is no ReAct text to clean up.
+		raw, callErr = a.callFunctions(ctx, prompt, functionDefs(toolsList), wrapped)
+	} else {
+		raw, callErr = a.callReact(ctx, prompt, wrapped, run)
+	}
+	res := rec.result()
+	res.Answer = raw
+	if callErr == nil && a.cfg.Mode != ModeFunctions {
+		res.Answer = sanitizeFinalAnswer(raw)
+	}
+	a.saveTrace(ctx, run.Finish(raw, res.Answer, callErr))
+	return res, callErr
+}
+
+// callReact runs the ZeroShotReact executor and returns its raw output.
+func (a *Agent) callReact(ctx context.Context, prompt string, list []tools.Tool, run *traces.Run) (string, error) {
+	exec, err := agents.Initialize(
+		a.llm,
+		list,
+		agents.ZeroShotReactDescription,
+		agents.WithMaxIterations(maxSteps),
+		agents.WithParserErrorHandler(agents.NewParserErrorHandler(func(msg string) string {
+			run.ParseError(msg)
+			return msg
+		})),
+		agents.WithCallbacksHandler(tracer{}),
+	)
+	if err != nil {
+		return "", err
+	}
+	out, err := exec.Call(ctx, map[string]any{"input": prompt})
+	raw, _ := out["output"].(string)
+	return raw, err
+}
+
+// saveTrace stores the trace of a reply; runs without a tweet are not kept.
+func (a *Agent) saveTrace(ctx context.Context, t traces.Trace) {
+	if a.cfg.Traces == nil || t.TweetID == "" {
+		return
+	}
+	// The run's context may already be cancelled; the trace matters most then.
+	sctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := a.cfg.Traces.Save(sctx, t); err != nil {
+		log.Printf("failed to save trace for tweet %s: %v", t.TweetID, err)
+	}
+}
+
+// shadowed swaps the intercepted tools for simulations in shadow mode.
+func (a *Agent) shadowed(list []tools.Tool, tweetID, twitterID string) []tools.Tool {
+	if a.cfg.Shadow == nil {
+		return list
+	}
+	out := make([]tools.Tool, len(list))
+	for i, t := range list {
+		if server, remote := originOf(t); shadow.Intercepts(remote) {
+			t = shadowTool{Tool: t, server: server, remote: remote, rec: a.cfg.Shadow, tweetID: tweetID, twitterID: twitterID}
+		}
+		out[i] = t
+	}
+	return out
+}
+
+type shadowTool struct {
+	tools.Tool
+	server    string
+	remote    string
+	rec       *shadow.Recorder
+	tweetID   string
+	twitterID string
+}
+
+func (t shadowTool) origin() (string, string) { return t.server, t.remote }
+
+func (t shadowTool) Call(ctx context.Context, input string) (string, error) {
+	var args map[string]any
+	_ = json.Unmarshal([]byte(input), &args)
+	out, err := t.rec.Intercept(ctx, t.tweetID, t.twitterID, t.remote, args)
+	if err != nil {
+		log.Printf("shadow: failed to record %s: %v", t.Name(), err)
+	}
+	return out, nil
+}
+
+// recorder collects which tools one run used, the transaction hashes returned
+// by the ones that move value, whether the reply was posted and whether anything but a lookup was
+// attempted.
+type recorder struct {
+	mu     sync.Mutex
+	tools  []string
+	txs    []string
+	posted bool
+	acted  bool
+}
+
+func (r *recorder) wrap(list []tools.Tool) []tools.Tool {
+	out := make([]tools.Tool, len(list))
+	for i, t := range list {
+		server, remote := originOf(t)
+		out[i] = recordingTool{Tool: t, rec: r, acts: !readOnlyTool(server, remote)}
+	}
+	return out
+}
+
+// readOnlyTool reports whether a tool only looks things up. create_wallet
+// counts: it returns the existing wallet when called again.
+func readOnlyTool(server, remote string) bool {
+	return matchAny(readOnly, server+":"+remote) || remote == "create_wallet"
+}
+
+// record notes one call. A call that may post or move value counts even when
+// it failed, since the server may have acted before the error reached us.
+func (r *recorder) record(name string, acts bool, output string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !contains(r.tools, name) {
+		r.tools = append(r.tools, name)
+	}
+	r.acted = r.acted || acts
+	if err != nil {
+		return
+	}
+	if name == "x_post_reply" {
+		r.posted = true
+		return
+	}
+	if !acts {
+		// Lookups such as get_transaction return hashes the bot never sent.
+		return
+	}
+	for _, h := range txHashRe.FindAllString(output, -1) {
+		if !contains(r.txs, h) {
+			r.txs = append(r.txs, h)
+		}
+	}
+}
+
+func (r *recorder) result() types.AgentResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return types.AgentResult{ToolsUsed: r.tools, TxHashes: r.txs, Posted: r.posted, SideEffects: r.acted}
+}
+
+type recordingTool struct {
+	tools.Tool
+	rec  *recorder
+	acts bool // the tool may post or move value
+}
+
+func (t recordingTool) Call(ctx context.Context, input string) (string, error) {
+	started := time.Now()
+	out, err := t.Tool.Call(ctx, input)
+	failed := err
+	if failed == nil && failedObservation(out) {
+		failed = &toolError{tool: t.Name(), text: out}
+	}
+	t.rec.record(t.Name(), t.acts, out, failed)
+	if run := traces.FromContext(ctx); run != nil {
+		run.Tool(t.Name(), input, out, failed, started)
+	}
+	return out, err
+}
+
+func contains(list []string, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -1,19 +1,19 @@
 package agentcore
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
 	"regexp"
 	"strings"
 	"time"
 
-	"github.com/tmc/langchaingo/agents"
-	"github.com/tmc/langchaingo/llms/openai"
+	"cg-mentions-bot/internal/shadow"
+	"cg-mentions-bot/internal/traces"
+	"cg-mentions-bot/internal/types"
+
 	"github.com/tmc/langchaingo/tools"
 )
 
@@ -21,172 +21,193 @@ import (
 type Config struct {
 	XMCP      string
 	WalletMCP string
-	BNBMCP    string
 	SolanaMCP string
-	Model     string
+	// GoldRushMCP serves on-chain analytics; its results are shaped to fit the context.
+	GoldRushMCP string
+	Model       string // shorthand for LLM.Model
+	// LLM selects the provider; Fallback, if set, answers when it fails or times out.
+	LLM      LLMConfig
+	Fallback *LLMConfig
+	// Mode is ModeReact (default) or ModeFunctions for native tool calling.
+	Mode string
+	// Registry adds MCP servers from a file; an entry named like one of the
+	// URL fields above ("x", "wallet", "solana", "goldrush") replaces it.
+	Registry *Registry
+	// Policy limits the tools of each request context by server name and the
+	// server's tool name; nil allows every tool everywhere.
+	Policy Policy
+	// Shadow, when set, intercepts posting and value-moving tool calls and
+	// answers them with simulated results (dry-run mode).
+	Shadow *shadow.Recorder
+	// Traces, when set, keeps a trace of every reply keyed by tweet id.
+	Traces traces.Store
+	// ToolsTTL is how long discovered tools are reused (DefaultToolsTTL when
+	// zero); a server's notifications/tools/list_changed refreshes them sooner.
+	ToolsTTL time.Duration
 }
 
-type mcpHTTP struct {
-	base string
-	hc   *http.Client
+func (cfg Config) toolsTTL() time.Duration {
+	if cfg.ToolsTTL <= 0 {
+		return DefaultToolsTTL
+	}
+	return cfg.ToolsTTL
 }
 
-func newMCP(base string) *mcpHTTP {
-	c := &http.Client{Timeout: 60 * time.Second}
-	_ = post(c, base, map[string]any{
-		"jsonrpc": "2.0", "id": 1, "method": "initialize",
-		"params": map[string]any{
-			"protocolVersion": "2025-06-18",
-			"capabilities":    map[string]any{},
-			"clientInfo":      map[string]any{"name": "lc", "version": "0.1"},
-		},
-	})
-	return &mcpHTTP{base: base, hc: c}
+// ToolClient calls tools on one MCP server, for callers that run tools
+// directly instead of through the agent.
+type ToolClient struct {
+	name string
+	spec func() (ServerSpec, bool)
 }
 
-func (m *mcpHTTP) call(name string, args map[string]any) (string, error) {
-	var out struct {
-		Result struct {
-			Content []struct {
-				Type string `json:"type"`
-				Text string `json:"text"`
-			} `json:"content"`
-		} `json:"result"`
-	}
-	if _, err := postJSON(m.hc, m.base, map[string]any{
-		"jsonrpc": "2.0", "id": 2, "method": "tools/call",
-		"params": map[string]any{"name": name, "arguments": args},
-	}, &out); err != nil {
-		return "", err
-	}
-	if len(out.Result.Content) == 0 {
-		return "", nil
-	}
-	return out.Result.Content[0].Text, nil
+// NewToolClient returns a client for the MCP server at url; the session is
+// shared with every other client of the same url.
+func NewToolClient(url string) *ToolClient {
+	spec := ServerSpec{URL: strings.TrimSpace(url)}
+	return &ToolClient{name: url, spec: func() (ServerSpec, bool) { return spec, true }}
 }
 
-func (m *mcpHTTP) listTools() ([]map[string]any, error) {
-	var out struct {
-		Result struct {
-			Tools []map[string]any `json:"tools"`
-		} `json:"result"`
-	}
-	if _, err := postJSON(m.hc, m.base, map[string]any{
-		"jsonrpc": "2.0", "id": 3, "method": "tools/list",
-	}, &out); err != nil {
-		return nil, err
+// NewServerClient returns a client for the server cfg calls name, resolved
+// like the agent does: a registry entry replaces the URL field. The server is
+// looked up again on every call, so registry reloads apply. ok is false when
+// cfg has no such server.
+func NewServerClient(cfg Config, name string) (c *ToolClient, ok bool) {
+	if _, ok := cfg.server(name); !ok {
+		return nil, false
 	}
-	return out.Result.Tools, nil
-}
-
-func post(c *http.Client, url string, body any) error {
-	_, err := postJSON(c, url, body, nil)
-	return err
+	return &ToolClient{name: name, spec: func() (ServerSpec, bool) { return cfg.server(name) }}, true
 }
 
-func postJSON(c *http.Client, url string, body any, out any) (*http.Response, error) {
-	b, _ := json.Marshal(body)
-	req, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := c.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		if out == nil {
-			resp.Body.Close()
-		}
-	}()
-	if out != nil {
-		defer resp.Body.Close()
-		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
-			return resp, err
-		}
+// Call runs a tool and returns its text. Results the tool flags as errors are
+// returned as errors so they are never mistaken for output.
+func (c *ToolClient) Call(ctx context.Context, name string, args map[string]any) (string, error) {
+	spec, ok := c.spec()
+	if !ok {
+		return "", fmt.Errorf("MCP server %s is not configured", c.name)
 	}
-	return resp, nil
+	return mcpFor(spec).call(ctx, name, args)
 }
 
 type genericMCPTool struct {
-	client *mcpHTTP
-	name   string
+	client *mcpClient
+	server string // name used by tool policies
+	name   string // as shown to the model, with the server's prefix
+	remote string // as known to the server
 	desc   string
+	schema map[string]any // inputSchema from tools/list
+	v      *validator
+}
+
+func (t genericMCPTool) Name() string { return t.name }
+
+// Description appends the input schema for ReAct mode, where the model only sees text.
+func (t genericMCPTool) Description() string {
+	if t.schema == nil {
+		return t.desc
+	}
+	b, err := json.Marshal(t.schema)
+	if err != nil {
+		return t.desc
+	}
+	return fmt.Sprintf("%s\nInput JSON must match schema: %s", t.desc, string(b))
 }
 
-func (t genericMCPTool) Name() string        { return t.name }
-func (t genericMCPTool) Description() string { return t.desc }
+func (t genericMCPTool) Summary() string             { return t.desc }
+func (t genericMCPTool) InputSchema() map[string]any { return t.schema }
 func (t genericMCPTool) Call(ctx context.Context, input string) (string, error) {
-	var a map[string]any
-	_ = json.Unmarshal([]byte(input), &a)
-	return t.client.call(t.name, a)
+	// Invalid arguments go back to the model as an observation it can correct.
+	a, problem := parseArgs(t.name, input, t.v)
+	if problem != "" {
+		return problem, nil
+	}
+	return observe(ctx, t.client, t.name, t.remote, a)
 }
 
+func (t genericMCPTool) origin() (string, string) { return t.server, t.remote }
+
 // xTool mirrors cmd/agent behavior to expose twitter.post_reply as a tool
-type xTool struct{ client *mcpHTTP }
+type xTool struct{ client *mcpClient }
 
 func (t xTool) Name() string { return "x_post_reply" }
 func (t xTool) Description() string {
 	return "Reply under a tweet via X MCP. Input JSON: {\"in_reply_to_tweet_id\":\"...\",\"text\":\"...\"}"
 }
+func (t xTool) Summary() string { return "Reply under a tweet via X MCP." }
+func (t xTool) InputSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"in_reply_to_tweet_id": map[string]any{"type": "string", "description": "id of the tweet to reply to"},
+			"text":                 map[string]any{"type": "string", "description": "reply text"},
+		},
+		"required": []any{"in_reply_to_tweet_id", "text"},
+	}
+}
 func (t xTool) Call(ctx context.Context, input string) (string, error) {
-	var a map[string]any
-	_ = json.Unmarshal([]byte(input), &a)
-	return t.client.call("twitter.post_reply", a)
+	a, problem := parseArgs(t.Name(), input, compileSchema(t.InputSchema()))
+	if problem != "" {
+		return problem, nil
+	}
+	return observe(ctx, t.client, t.Name(), "twitter.post_reply", a)
 }
 
-func wlDiscoveredTools(wl *mcpHTTP) ([]tools.Tool, error) {
-	raw, err := wl.listTools()
+func (t xTool) origin() (string, string) { return ServerX, "twitter.post_reply" }
+
+// observe calls remote for the model, which knows it as name: failures flagged
+// by the tool become a tool_error observation and long results are summarized.
+func observe(ctx context.Context, c *mcpClient, name, remote string, args map[string]any) (string, error) {
+	out, isErr, err := c.callTool(ctx, remote, args)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	out := make([]tools.Tool, 0, len(raw))
-	for _, t := range raw {
-		name, _ := t["name"].(string)
-		if name == "" {
-			continue
-		}
-		description, _ := t["description"].(string)
-		if schemaVal, ok := t["inputSchema"]; ok && schemaVal != nil {
-			if b, err := json.Marshal(schemaVal); err == nil {
-				description = fmt.Sprintf("%s\nInput JSON must match schema: %s", description, string(b))
-			}
-		}
-		out = append(out, genericMCPTool{client: wl, name: name, desc: description})
+	if isErr {
+		return toolErrorObservation(name, summarize(out, maxObservation)), nil
 	}
-	return out, nil
+	return summarize(out, maxObservation), nil
 }
 
-func bnbDiscoveredTools(bnb *mcpHTTP) ([]tools.Tool, error) {
-	raw, err := bnb.listTools()
-	if err != nil {
-		return nil, err
-	}
+// discoveredTools turns the tools raw listed by spec's server into agent
+// tools. Names get the server's tool prefix; a name already in taken is
+// namespaced as "<server>_<name>".
+func discoveredTools(spec ServerSpec, c *mcpClient, raw []map[string]any, taken map[string]bool) []tools.Tool {
 	out := make([]tools.Tool, 0, len(raw))
 	for _, t := range raw {
-		name, _ := t["name"].(string)
-		if name == "" {
+		remote, _ := t["name"].(string)
+		if remote == "" {
 			continue
 		}
-		description, _ := t["description"].(string)
-		if schemaVal, ok := t["inputSchema"]; ok && schemaVal != nil {
-			if b, err := json.Marshal(schemaVal); err == nil {
-				description = fmt.Sprintf("%s\nInput JSON must match schema: %s", description, string(b))
-			}
+		name := spec.ToolPrefix + remote
+		if taken[name] {
+			log.Printf("mcp: tool %s of %s collides with another server, exposed as %s_%s", name, spec.Name, spec.Name, name)
+			name = spec.Name + "_" + name
 		}
-		out = append(out, genericMCPTool{client: bnb, name: name, desc: description})
+		taken[name] = true
+		description, _ := t["description"].(string)
+		schema, _ := t["inputSchema"].(map[string]any)
+		out = append(out, genericMCPTool{client: c, server: spec.Name, name: name, remote: remote, desc: description, schema: schema, v: compileSchema(schema)})
 	}
-	return out, nil
+	return out
 }
 
 // Ask returns env-based AskWithConfig
 func Ask(ctx context.Context, input string, twitterID string) (string, error) {
 	cfg := Config{
-		XMCP:      os.Getenv("X_MCP_HTTP"),
-		WalletMCP: os.Getenv("WALLET_MCP_HTTP"),
-		BNBMCP:    os.Getenv("BNB_MCP_HTTP"),
-		SolanaMCP: os.Getenv("SOLANA_MCP_HTTP"),
-		Model:     os.Getenv("OPENAI_MODEL"),
+		XMCP:        os.Getenv("X_MCP_HTTP"),
+		WalletMCP:   os.Getenv("WALLET_MCP_HTTP"),
+		SolanaMCP:   os.Getenv("SOLANA_MCP_HTTP"),
+		GoldRushMCP: os.Getenv("GOLDRUSH_MCP_HTTP"),
 	}
-	return AskAgent(ctx, input, twitterID, "", "", cfg)
+	cfg.LLM, cfg.Fallback = LLMConfigFromEnv()
+	cfg.Mode = os.Getenv("AGENT_MODE")
+	policy, err := PolicyFromEnv()
+	if err != nil {
+		return "", err
+	}
+	cfg.Policy = policy
+	if cfg.Registry, err = RegistryFromEnv(); err != nil {
+		return "", err
+	}
+	return AskAgent(ctx, input, twitterID, "", nil, cfg)
 }
 
 // Tweet env-based wrapper
@@ -199,11 +220,11 @@ func CreateWalletForTwitterIDWithConfig(ctx context.Context, twitterID string, c
 	if strings.TrimSpace(twitterID) == "" {
 		return fmt.Errorf("twitter_id is required")
 	}
-	if strings.TrimSpace(cfg.WalletMCP) == "" {
+	spec, ok := cfg.server(ServerWallet)
+	if !ok {
 		return nil
 	}
-	wl := newMCP(cfg.WalletMCP)
-	_, err := wl.call("create_wallet", map[string]any{"twitter_id": strings.TrimSpace(twitterID)})
+	_, err := mcpFor(spec).call(ctx, "create_wallet", map[string]any{"twitter_id": strings.TrimSpace(twitterID)})
 	return err
 }
 
@@ -233,78 +254,120 @@ func sanitizeFinalAnswer(s string) string {
 	return strings.TrimSpace(s)
 }
 
+// askOptions carries optional per-request context for AskAgent.
+type askOptions struct {
+	history     []types.Turn
+	memory      string
+	toolContext string
+	unavailable []string // servers that are down
+}
+
+// AskOption customizes a single AskAgent call.
+type AskOption func(*askOptions)
+
+// WithHistory gives the agent earlier tweets of the thread (oldest first) so
+// follow-ups like "send it again" can be resolved.
+func WithHistory(turns []types.Turn) AskOption {
+	return func(o *askOptions) { o.history = turns }
+}
+
+// WithToolContext sets the policy context of the request (ContextMention by default).
+func WithToolContext(c string) AskOption {
+	return func(o *askOptions) { o.toolContext = c }
+}
+
+// WithMemory prepends a short, pre-rendered summary of what the bot remembers
+// about the user (recent questions, addresses, preferred chain).
+func WithMemory(block string) AskOption {
+	return func(o *askOptions) { o.memory = strings.TrimSpace(block) }
+}
+
+// memoryBlock wraps the user's memory summary for the prompt.
+func memoryBlock(block string) string {
+	if block == "" {
+		return ""
+	}
+	return block + "\nOnly rely on this memory when the new tweet is ambiguous; the tweet always wins.\n"
+}
+
+// historyBlock renders prior turns as a compact transcript for the prompt.
+func historyBlock(turns []types.Turn) string {
+	if len(turns) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Conversation so far (oldest first), use it to resolve references in the new tweet:\n")
+	for _, t := range turns {
+		who := "user"
+		switch {
+		case t.FromBot:
+			who = "you"
+		case t.AuthorUsername != "":
+			who = "@" + t.AuthorUsername
+		}
+		b.WriteString(fmt.Sprintf("- %s: %s\n", who, strings.Join(strings.Fields(t.Text), " ")))
+	}
+	b.WriteString("New tweet: ")
+	return b.String()
+}
+
 // AskAgent: unified ask that can handle reply/non-reply prompts (no posting)
-func AskAgent(ctx context.Context, question string, twitterID string, replyTo string, mentionedUser string, cfg Config) (string, error) {
+func AskAgent(ctx context.Context, question string, twitterID string, replyTo string, mentioned []Recipient, cfg Config, opts ...AskOption) (string, error) {
 	q := strings.TrimSpace(question)
 	if q == "" || strings.TrimSpace(twitterID) == "" {
 		return "", fmt.Errorf("input and twitter_id are required")
 	}
-	var toolsList []tools.Tool
-	if strings.TrimSpace(cfg.XMCP) != "" {
-		x := newMCP(cfg.XMCP)
-		toolsList = append(toolsList, xTool{client: x})
+	a, err := NewAgent(cfg)
+	if err != nil {
+		return "", err
 	}
-	// if strings.TrimSpace(cfg.BNBMCP) != "" {
-	// 	if t, err := bnbDiscoveredTools(newMCP(cfg.BNBMCP)); err == nil {
-	// 		toolsList = append(toolsList, t...)
-	// 	} else {
-	// 		log.Println("failed to discover BNB HTTP tools:", err)
-	// 	}
-	// }
-	// Discover Solana tools (optional)
-	if strings.TrimSpace(cfg.SolanaMCP) != "" {
-		if t, err := bnbDiscoveredTools(newMCP(cfg.SolanaMCP)); err == nil {
-			toolsList = append(toolsList, t...)
-		} else {
-			log.Println("failed to discover Solana HTTP tools:", err)
-		}
+	res, err := a.ask(ctx, q, twitterID, replyTo, mentioned, opts...)
+	return res.Answer, err
+}
+
+// Recipient is a user mentioned in the tweet: handle, twitter id and, when
+// known, their wallet address.
+type Recipient struct {
+	Handle    string `json:"handle"`
+	TwitterID string `json:"twitter_id"`
+	Wallet    string `json:"wallet,omitempty"`
+}
+
+// recipientsBlock lists every mentioned user so multi-recipient commands
+// ("split 0.1 BNB between @a and @b") can address each of them.
+func recipientsBlock(mentioned []Recipient) string {
+	if len(mentioned) == 0 {
+		return " Nobody else is mentioned in the tweet."
 	}
-	if strings.TrimSpace(cfg.WalletMCP) != "" {
-		if t, err := wlDiscoveredTools(newMCP(cfg.WalletMCP)); err == nil {
-			toolsList = append(toolsList, t...)
-		} else {
-			log.Println("failed to discover Wallet MCP tools:", err)
+	var b strings.Builder
+	b.WriteString(" Users mentioned in the tweet (handle -> twitter_id -> wallet):\n")
+	for _, r := range mentioned {
+		wallet := r.Wallet
+		if wallet == "" {
+			wallet = "unknown; call read_wallet with this twitter_id, and create_wallet if it has none"
 		}
+		handle := r.Handle
+		if handle == "" {
+			handle = "(unknown handle)"
+		}
+		b.WriteString(fmt.Sprintf("- @%s -> %s -> %s\n", strings.TrimPrefix(handle, "@"), r.TwitterID, wallet))
 	}
-	model := cfg.Model
-	if model == "" {
-		model = "gpt-4.1-mini"
-	}
-	llm, err := openai.New(openai.WithModel(model))
-	if err != nil {
-		return "", fmt.Errorf("OPENAI_API_KEY is required or configure a provider supported by LangChainGo")
-	}
-	exec, err := agents.Initialize(
-		llm,
-		toolsList,
-		agents.ZeroShotReactDescription,
-		agents.WithMaxIterations(20),
-		agents.WithParserErrorHandler(agents.NewParserErrorHandler(nil)),
-	)
-	if err != nil {
-		return "", err
-	}
-	prompt := q
+	b.WriteString("When the tweet sends to several of these users (e.g. splitting an amount), make one transfer per recipient to their wallet and list every transaction hash.")
+	return b.String()
+}
+
+// buildPrompt wraps the question with memory, thread history, the mentioned
+// users and the reply or non-reply instructions.
+func buildPrompt(q string, twitterID string, replyTo string, mentioned []Recipient, o askOptions) string {
+	prompt := memoryBlock(o.memory) + historyBlock(o.history) + q
 	if strings.TrimSpace(replyTo) != "" {
-		prompt = fmt.Sprintf("%s Answer this question using the available MCP tools. You are an AI agent that manages user wallets via tweet commands. "+
+		return fmt.Sprintf("%s Answer this question using the available MCP tools. You are an AI agent that manages user wallets via tweet commands. "+
 			"Your reply will be posted on X; write concise, user-facing text. "+
 			"Never share private keys or the twitter_id in the reply. Then reply to tweet %s using x_post_reply. Also user\\'s twitter_id is %s. "+
-			"If a blockchain transaction is executed (e.g., a transfer), include its transaction hash; for wallet creation or reads, provide the wallet address."+
-			"Also if user mentions another user like meaning to transfer something to another users, i am giving you possible user that is in tweet. If the input is empty, act like user has not mention"+
-			"anybody. And in this condition always use Kanalabs mcp server or tool. Here is the id: %s",
-			prompt, strings.TrimSpace(replyTo), strings.TrimSpace(twitterID), strings.TrimSpace(mentionedUser))
-	} else {
-		prompt = fmt.Sprintf("%s Answer this question using the available MCP tools. The twitter id of the user is: %s ", q, twitterID)
-	}
-	out, callErr := exec.Call(ctx, map[string]any{"input": prompt})
-	if callErr != nil {
-		if v, ok := out["output"].(string); ok && v != "" {
-			return v, callErr
-		}
-		return "", callErr
+			"If a blockchain transaction is executed (e.g., a transfer), include its transaction hash; for wallet creation or reads, provide the wallet address.%s",
+			prompt, strings.TrimSpace(replyTo), strings.TrimSpace(twitterID), recipientsBlock(mentioned)) + unavailableBlock(o.unavailable)
 	}
-	ans, _ := out["output"].(string)
-	return sanitizeFinalAnswer(ans), nil
+	return fmt.Sprintf("%s Answer this question using the available MCP tools. The twitter id of the user is: %s.%s", prompt, twitterID, recipientsBlock(mentioned)) + unavailableBlock(o.unavailable)
 }
 
 // TweetOnly: post without modification so caller can truncate/format as desired
@@ -312,11 +375,11 @@ func TweetOnly(ctx context.Context, replyTo string, text string, cfg Config) err
 	if strings.TrimSpace(replyTo) == "" || strings.TrimSpace(text) == "" {
 		return fmt.Errorf("reply_to and text are required")
 	}
-	if strings.TrimSpace(cfg.XMCP) == "" {
+	spec, ok := cfg.server(ServerX)
+	if !ok {
 		return fmt.Errorf("X_MCP_HTTP is required")
 	}
-	x := newMCP(cfg.XMCP)
-	if _, err := x.call("twitter.post_reply", map[string]any{
+	if _, err := mcpFor(spec).call(ctx, "twitter.post_reply", map[string]any{
 		"in_reply_to_tweet_id": strings.TrimSpace(replyTo),
 		"text":                 text,
 	}); err != nil {
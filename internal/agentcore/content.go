@@ -0,0 +1,197 @@
+package agentcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxObservation caps, in characters, a tool result handed to the model.
+const maxObservation = 6000
+
+const (
+	keepItems    = 5   // array items kept when a JSON observation is shrunk
+	keepStrLen   = 200 // characters kept of long strings in a shrunk observation
+	shrinkPasses = 3   // shrink passes before falling back to plain truncation
+)
+
+// toolError is a result the tool flagged with isError.
+type toolError struct {
+	tool string
+	text string
+}
+
+func (e *toolError) Error() string { return e.tool + ": " + e.text }
+
+// parseToolResult decodes a tools/call result. Content parts mcp-go cannot
+// parse are kept as their raw JSON.
+func parseToolResult(raw json.RawMessage) (*mcp.CallToolResult, error) {
+	var r struct {
+		Content           []map[string]any `json:"content"`
+		StructuredContent any              `json:"structuredContent"`
+		IsError           bool             `json:"isError"`
+	}
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return nil, fmt.Errorf("invalid tool result: %w", err)
+	}
+	res := &mcp.CallToolResult{StructuredContent: r.StructuredContent, IsError: r.IsError}
+	for _, part := range r.Content {
+		c, err := mcp.ParseContent(part)
+		if err != nil {
+			c = mcp.NewTextContent(compactJSON(part))
+		}
+		res.Content = append(res.Content, c)
+	}
+	return res, nil
+}
+
+// renderContent flattens a tool result into text: text parts are joined,
+// resources and structured content become compact JSON, and media is
+// described instead of inlined.
+func renderContent(res *mcp.CallToolResult) string {
+	var parts []string
+	for _, c := range res.Content {
+		switch c := c.(type) {
+		case mcp.TextContent:
+			if c.Text != "" {
+				parts = append(parts, c.Text)
+			}
+		case mcp.EmbeddedResource:
+			parts = append(parts, compactJSON(resourceValue(c.Resource)))
+		case mcp.ResourceLink:
+			parts = append(parts, compactJSON(map[string]any{"type": "resource_link", "uri": c.URI, "name": c.Name, "mimeType": c.MIMEType}))
+		case mcp.ImageContent:
+			parts = append(parts, fmt.Sprintf("[image %s, %d base64 chars]", c.MIMEType, len(c.Data)))
+		case mcp.AudioContent:
+			parts = append(parts, fmt.Sprintf("[audio %s, %d base64 chars]", c.MIMEType, len(c.Data)))
+		}
+	}
+	text := strings.Join(parts, "\n")
+	if res.StructuredContent != nil {
+		// Servers often repeat the structured result as text; keep one copy.
+		if s := compactJSON(res.StructuredContent); s != compactString(text) {
+			if text == "" {
+				return s
+			}
+			text += "\n" + s
+		}
+	}
+	return text
+}
+
+// resourceValue keeps text resources whole and describes blobs by size.
+func resourceValue(r mcp.ResourceContents) map[string]any {
+	switch r := r.(type) {
+	case mcp.TextResourceContents:
+		return map[string]any{"type": "resource", "uri": r.URI, "mimeType": r.MIMEType, "text": r.Text}
+	case mcp.BlobResourceContents:
+		return map[string]any{"type": "resource", "uri": r.URI, "mimeType": r.MIMEType, "blob_base64_chars": len(r.Blob)}
+	}
+	return map[string]any{"type": "resource"}
+}
+
+// toolErrorObservation tells the model the call failed so it is not reported as done.
+func toolErrorObservation(tool, message string) string {
+	b, _ := json.Marshal(map[string]any{
+		"error":   "tool_error",
+		"tool":    tool,
+		"message": message,
+		"hint":    "The call failed. Do not report it as done; fix the input and retry, or tell the user it failed.",
+	})
+	return string(b)
+}
+
+// failedObservation reports whether out is a tool_error observation.
+func failedObservation(out string) bool {
+	return strings.HasPrefix(out, `{"error":"tool_error"`)
+}
+
+// summarize fits out into limit characters. JSON is shrunk first (long arrays
+// and strings are cut with a note of what was left out) so it stays
+// parseable; anything still too long is truncated with a marker.
+func summarize(out string, limit int) string {
+	if len(out) <= limit {
+		return out
+	}
+	var v any
+	dec := json.NewDecoder(strings.NewReader(out))
+	dec.UseNumber()
+	if dec.Decode(&v) == nil && !dec.More() {
+		items, strLen := keepItems, keepStrLen
+		for i := 0; i < shrinkPasses; i++ {
+			if s := compactJSON(shrink(v, items, strLen)); len(s) <= limit {
+				return s
+			}
+			items, strLen = max1(items/2), max1(strLen/2)
+		}
+	}
+	cut := limit - 80
+	if cut < 0 {
+		cut = 0
+	}
+	// Avoid splitting a multi-byte character.
+	for cut > 0 && cut < len(out) && out[cut]&0xC0 == 0x80 {
+		cut--
+	}
+	return fmt.Sprintf("%s\n[output truncated: showed %d of %d characters]", out[:cut], cut, len(out))
+}
+
+// shrink copies v keeping at most items array elements and strLen characters
+// of each string.
+func shrink(v any, items, strLen int) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, x := range v {
+			out[k] = shrink(x, items, strLen)
+		}
+		return out
+	case []any:
+		n := len(v)
+		if n > items {
+			v = v[:items]
+		}
+		out := make([]any, 0, len(v)+1)
+		for _, x := range v {
+			out = append(out, shrink(x, items, strLen))
+		}
+		if n > items {
+			out = append(out, fmt.Sprintf("… %d more items", n-items))
+		}
+		return out
+	case string:
+		if r := []rune(v); len(r) > strLen {
+			return string(r[:strLen]) + "…"
+		}
+	}
+	return v
+}
+
+func compactJSON(v any) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// compactString compacts s if it is JSON, so it can be compared with compactJSON output.
+func compactString(s string) string {
+	var v any
+	if json.Unmarshal([]byte(s), &v) != nil {
+		return s
+	}
+	return compactJSON(v)
+}
+
+func max1(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
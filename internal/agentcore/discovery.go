@@ -0,0 +1,159 @@
+package agentcore
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+)
+
+const (
+	// DefaultToolsTTL is how long a server's tool list is reused before it is
+	// listed again.
+	DefaultToolsTTL = 5 * time.Minute
+	// DefaultHealthInterval is how often Monitor pings the servers; a server
+	// that failed is also left out this long when nothing monitors it.
+	DefaultHealthInterval = 30 * time.Second
+	// healthTimeout bounds one ping.
+	healthTimeout = 10 * time.Second
+)
+
+// capabilities says what the agent loses when a server with a built-in role
+// is down; other servers are named by their tools.
+var capabilities = map[string]string{
+	ServerX:        "posting replies on X",
+	ServerWallet:   "wallet creation, balances and transfers",
+	ServerSolana:   "Solana operations",
+	ServerGoldRush: "on-chain analytics (balances, transactions, prices)",
+}
+
+// cachedTools returns the server's tools, listing them again when the list is
+// older than ttl or the server announced notifications/tools/list_changed.
+func (m *mcpClient) cachedTools(ctx context.Context, ttl time.Duration) ([]map[string]any, error) {
+	m.stateMu.Lock()
+	gen := m.gen
+	if m.tools != nil && m.listedGen == gen && time.Since(m.listedAt) < ttl {
+		list := m.tools
+		m.stateMu.Unlock()
+		return list, nil
+	}
+	m.stateMu.Unlock()
+
+	list, err := m.listTools(ctx)
+	if ctx.Err() == nil {
+		m.markHealth(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.stateMu.Lock()
+	// A change announced while listing keeps the new list stale.
+	m.tools, m.listedAt, m.listedGen = list, time.Now(), gen
+	m.stateMu.Unlock()
+	return list, nil
+}
+
+// invalidate makes the next cachedTools list the tools again.
+func (m *mcpClient) invalidate() {
+	m.stateMu.Lock()
+	m.gen++
+	m.stateMu.Unlock()
+}
+
+// check pings the server and records the outcome.
+func (m *mcpClient) check(ctx context.Context) error {
+	err := m.do(ctx, func(c *client.Client) error { return c.Ping(ctx) })
+	m.markHealth(err)
+	return err
+}
+
+// markHealth records the outcome of a request made to learn whether the
+// server is up. A server that comes back has its tools listed again.
+func (m *mcpClient) markHealth(err error) {
+	m.stateMu.Lock()
+	wasDown := m.down != nil
+	m.down, m.checkedAt = err, time.Now()
+	if wasDown && err == nil {
+		m.gen++
+	}
+	m.stateMu.Unlock()
+	switch {
+	case err != nil && !wasDown:
+		log.Printf("mcp: %s is unavailable, its tools are removed: %v", m.target(), err)
+	case err == nil && wasDown:
+		log.Printf("mcp: %s recovered", m.target())
+	}
+}
+
+// unavailable returns the last failure of a server whose tools should not be
+// offered; nil when it is up or failed more than retry ago and may be tried
+// again.
+func (m *mcpClient) unavailable(retry time.Duration) error {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	if m.down == nil || time.Since(m.checkedAt) >= retry {
+		return nil
+	}
+	return m.down
+}
+
+// Monitor pings every configured MCP server each interval until ctx is done.
+// A server that fails is left out of the toolset, and the agent told so,
+// until a ping succeeds again.
+func (a *Agent) Monitor(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultHealthInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			a.checkServers(ctx)
+		}
+	}
+}
+
+// checkServers pings the servers of the current configuration concurrently.
+func (a *Agent) checkServers(ctx context.Context) {
+	a.mu.Lock()
+	a.configure()
+	servers := a.servers
+	a.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, s := range servers {
+		wg.Add(1)
+		go func(s ServerSpec) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+			defer cancel()
+			_ = mcpFor(s).check(ctx)
+		}(s)
+	}
+	wg.Wait()
+}
+
+// unavailableBlock tells the agent which capabilities are missing because
+// their servers are down, so it says so instead of guessing.
+func unavailableBlock(down []string) string {
+	if len(down) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(down))
+	for _, name := range down {
+		c, ok := capabilities[name]
+		if !ok {
+			c = "the tools of " + name
+		}
+		parts = append(parts, fmt.Sprintf("%s (%s)", c, name))
+	}
+	return " These capabilities are temporarily unavailable: " + strings.Join(parts, "; ") +
+		". If the question needs them, say briefly that they are unavailable right now instead of guessing."
+}
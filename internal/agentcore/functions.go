@@ -0,0 +1,118 @@
+package agentcore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cg-mentions-bot/internal/traces"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/tools"
+)
+
+// Agent modes selectable in Config.Mode.
+const (
+	ModeReact     = "react"     // ZeroShotReact text parsing (default)
+	ModeFunctions = "functions" // the model's native tool-calling API
+)
+
+// maxSteps bounds the model round trips of one run, like the ReAct executor's
+// iteration limit.
+const maxSteps = 20
+
+// schemaTool is a tool that can describe its input as a JSON schema.
+type schemaTool interface {
+	Summary() string
+	InputSchema() map[string]any
+}
+
+// functionDefs turns tools into native tool definitions. Tools without a
+// schema accept any object.
+func functionDefs(list []tools.Tool) []llms.Tool {
+	out := make([]llms.Tool, 0, len(list))
+	for _, t := range list {
+		desc := t.Description()
+		params := map[string]any{"type": "object"}
+		if st, ok := t.(schemaTool); ok {
+			desc = st.Summary()
+			if s := st.InputSchema(); s != nil {
+				params = s
+			}
+		}
+		out = append(out, llms.Tool{
+			Type:     "function",
+			Function: &llms.FunctionDefinition{Name: t.Name(), Description: desc, Parameters: params},
+		})
+	}
+	return out
+}
+
+// callFunctions runs the tool-calling loop: the model either answers or asks for
+// tool calls, whose results are sent back until it answers. defs describe the
+// tools, run executes them (possibly wrapped for recording or shadow mode).
+func (a *Agent) callFunctions(ctx context.Context, prompt string, defs []llms.Tool, run []tools.Tool) (string, error) {
+	byName := make(map[string]tools.Tool, len(run))
+	for _, t := range run {
+		byName[t.Name()] = t
+	}
+	var opts []llms.CallOption
+	if len(defs) > 0 {
+		opts = append(opts, llms.WithTools(defs))
+	}
+	msgs := []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, prompt)}
+
+	for step := 0; step < maxSteps; step++ {
+		resp, err := a.llm.GenerateContent(ctx, msgs, opts...)
+		if err != nil {
+			return "", err
+		}
+		if len(resp.Choices) == 0 {
+			return "", fmt.Errorf("empty response from model")
+		}
+		choice := resp.Choices[0]
+		if len(choice.ToolCalls) == 0 {
+			return strings.TrimSpace(choice.Content), nil
+		}
+
+		call := llms.MessageContent{Role: llms.ChatMessageTypeAI}
+		if choice.Content != "" {
+			call.Parts = append(call.Parts, llms.TextContent{Text: choice.Content})
+		}
+		for _, tc := range choice.ToolCalls {
+			call.Parts = append(call.Parts, tc)
+		}
+		msgs = append(msgs, call)
+
+		for _, tc := range choice.ToolCalls {
+			if tc.FunctionCall == nil {
+				continue
+			}
+			if tr := traces.FromContext(ctx); tr != nil {
+				tr.Thought(tc.FunctionCall.Name, choice.Content)
+			}
+			out, err := runFunction(ctx, byName, tc.FunctionCall)
+			if err != nil {
+				out = fmt.Sprintf("%s failed: %v", tc.FunctionCall.Name, err)
+			}
+			msgs = append(msgs, llms.MessageContent{
+				Role:  llms.ChatMessageTypeTool,
+				Parts: []llms.ContentPart{llms.ToolCallResponse{ToolCallID: tc.ID, Name: tc.FunctionCall.Name, Content: out}},
+			})
+		}
+	}
+	return "", fmt.Errorf("agent did not answer within %d steps", maxSteps)
+}
+
+func runFunction(ctx context.Context, byName map[string]tools.Tool, fc *llms.FunctionCall) (string, error) {
+	t, ok := byName[fc.Name]
+	if !ok {
+		return fmt.Sprintf("%s is not a valid tool, try another one", fc.Name), nil
+	}
+	args := strings.TrimSpace(fc.Arguments)
+	if args == "" {
+		args = "{}"
+	}
+	// Tools check their own arguments and explain what to fix.
+	return t.Call(ctx, args)
+}
@@ -0,0 +1,222 @@
+package agentcore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tmc/langchaingo/tools"
+)
+
+const (
+	charsPerToken  = 4                              // rough estimate for English and JSON
+	goldrushTokens = maxObservation / charsPerToken // token cap of one GoldRush observation
+	goldrushPage   = 10                             // items per page before the token cap applies
+)
+
+// goldrushShape is the part of a GoldRush (Covalent) response the agent needs.
+type goldrushShape struct {
+	top    []string            // fields of data kept next to the items
+	fields []string            // item fields kept; none keeps whole items
+	nested map[string][]string // fields kept of nested lists, e.g. transfers
+}
+
+var (
+	balanceShape = goldrushShape{
+		top:    []string{"address", "chain_name", "quote_currency"},
+		fields: []string{"chain_name", "contract_name", "contract_ticker_symbol", "contract_address", "contract_decimals", "native_token", "balance", "quote_rate", "quote", "pretty_quote"},
+	}
+	transactionShape = goldrushShape{
+		top:    []string{"address", "chain_name", "quote_currency"},
+		fields: []string{"chain_name", "block_signed_at", "tx_hash", "successful", "from_address", "to_address", "value", "pretty_value_quote", "fees_paid", "pretty_gas_quote"},
+	}
+
+	// goldrushShapes projects the responses of the GoldRush MCP tools.
+	goldrushShapes = map[string]goldrushShape{
+		"get_token_balance_for_address":        balanceShape,
+		"get_native_token_balance_for_address": balanceShape,
+		"get_multichain_balances":              balanceShape,
+		"get_bitcoin_balances_for_HD_address":  balanceShape,
+		"get_multichain_transactions":          transactionShape,
+		"get_transaction":                      transactionShape,
+		"get_erc20_token_transfer_for_address": {
+			top:    []string{"address", "chain_name"},
+			fields: []string{"block_signed_at", "tx_hash", "successful", "transfers"},
+			nested: map[string][]string{"transfers": {"from_address", "to_address", "contract_ticker_symbol", "contract_decimals", "transfer_type", "delta", "pretty_delta_quote"}},
+		},
+		"get_nfts_for_address": {
+			top:    []string{"address", "chain_name"},
+			fields: []string{"contract_name", "contract_ticker_symbol", "contract_address", "type", "balance"},
+		},
+		"get_activity_across_all_chains": {
+			top:    []string{"address"},
+			fields: []string{"name", "chain_id", "is_testnet", "last_seen_at"},
+		},
+		"get_gas_prices": {
+			top:    []string{"chain_name", "event_type", "quote_currency", "base_fee"},
+			fields: []string{"interval", "gas_price", "gas_spent", "gas_quote", "pretty_total_gas_quote"},
+		},
+		"get_historical_token_prices": {
+			fields: []string{"contract_ticker_symbol", "contract_address", "quote_currency", "prices"},
+			nested: map[string][]string{"prices": {"date", "price"}},
+		},
+	}
+)
+
+// goldrushTool shapes the raw Covalent JSON of a GoldRush tool: items are
+// projected to the fields in goldrushShapes (plus any the agent asks for),
+// spam is dropped, and the list is paged to fit goldrushTokens with a "more"
+// marker naming the next offset.
+type goldrushTool struct {
+	genericMCPTool
+	shape goldrushShape
+}
+
+func (t goldrushTool) Call(ctx context.Context, input string) (string, error) {
+	a, problem := parseArgs(t.name, input, t.v)
+	if problem != "" {
+		return problem, nil
+	}
+	offset, _ := strconv.Atoi(fmt.Sprint(a["offset"]))
+	var extra []string
+	for _, f := range asSlice(a["fields"]) {
+		if s, ok := f.(string); ok && s != "" {
+			extra = append(extra, s)
+		}
+	}
+	// Paging arguments are ours; the server never sees them.
+	delete(a, "offset")
+	delete(a, "fields")
+
+	out, isErr, err := t.client.callTool(ctx, t.remote, a)
+	if err != nil {
+		return "", err
+	}
+	if isErr {
+		return toolErrorObservation(t.name, summarize(out, maxObservation)), nil
+	}
+	return shapeGoldRush(t.name, out, t.shape, offset, extra, goldrushTokens), nil
+}
+
+// goldrushTools wraps discovered GoldRush tools, adding the paging arguments
+// to their schemas.
+func goldrushTools(list []tools.Tool) []tools.Tool {
+	out := make([]tools.Tool, 0, len(list))
+	for _, t := range list {
+		g := t.(genericMCPTool)
+		g.schema = withPaging(g.schema)
+		g.v = compileSchema(g.schema)
+		out = append(out, goldrushTool{genericMCPTool: g, shape: goldrushShapes[g.remote]})
+	}
+	return out
+}
+
+// withPaging copies schema adding the offset and fields arguments.
+func withPaging(schema map[string]any) map[string]any {
+	out := map[string]any{"type": "object"}
+	for k, v := range schema {
+		out[k] = v
+	}
+	props := map[string]any{}
+	if p, ok := out["properties"].(map[string]any); ok {
+		for k, v := range p {
+			props[k] = v
+		}
+	}
+	props["offset"] = map[string]any{"type": "integer", "description": "Index of the first item to return; use more.next_offset from the previous result."}
+	props["fields"] = map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Extra item fields to include, when the default ones do not answer the question."}
+	out["properties"] = props
+	return out
+}
+
+// shapeGoldRush renders one page of a GoldRush response within budget tokens.
+// Responses it does not recognize are summarized as they are.
+func shapeGoldRush(tool, out string, s goldrushShape, offset int, extra []string, budget int) string {
+	var body map[string]any
+	dec := json.NewDecoder(strings.NewReader(out))
+	dec.UseNumber()
+	if dec.Decode(&body) != nil {
+		return summarize(out, budget*charsPerToken)
+	}
+	if failed, _ := body["error"].(bool); failed {
+		return toolErrorObservation(tool, fmt.Sprint(body["error_message"]))
+	}
+
+	res := map[string]any{}
+	var list []any
+	switch data := body["data"].(type) {
+	case []any:
+		list = data
+	case map[string]any:
+		list, _ = data["items"].([]any)
+		for _, f := range s.top {
+			if v, ok := data[f]; ok && v != nil {
+				res[f] = v
+			}
+		}
+	default:
+		return summarize(out, budget*charsPerToken)
+	}
+
+	items := make([]any, 0, len(list))
+	for _, it := range list {
+		if m, ok := it.(map[string]any); ok && m["is_spam"] == true {
+			continue
+		}
+		items = append(items, project(it, s, extra))
+	}
+	total := len(items)
+	if offset < 0 || offset > total {
+		offset = 0
+	}
+	n := total - offset
+	if n > goldrushPage {
+		n = goldrushPage
+	}
+	res["offset"] = offset
+	res["total_items"] = total
+	for ; ; n-- {
+		res["items"] = items[offset : offset+n]
+		delete(res, "more")
+		if next := offset + n; next < total {
+			res["more"] = map[string]any{
+				"remaining":   total - next,
+				"next_offset": next,
+				"hint":        fmt.Sprintf(`More items are available: call %s again with the same arguments and "offset":%d.`, tool, next),
+			}
+		}
+		rendered := compactJSON(res)
+		if len(rendered) <= budget*charsPerToken || n <= 1 {
+			return summarize(rendered, budget*charsPerToken)
+		}
+	}
+}
+
+// project keeps the shape's fields (and extra) of one item; nested lists are
+// projected the same way.
+func project(item any, s goldrushShape, extra []string) any {
+	m, ok := item.(map[string]any)
+	if !ok || len(s.fields) == 0 {
+		return item
+	}
+	out := make(map[string]any, len(s.fields)+len(extra))
+	for _, f := range append(append([]string{}, s.fields...), extra...) {
+		v, ok := m[f]
+		if !ok || v == nil {
+			continue
+		}
+		if keep, ok := s.nested[f]; ok {
+			if list, ok := v.([]any); ok {
+				sub := make([]any, len(list))
+				for i, x := range list {
+					sub[i] = project(x, goldrushShape{fields: keep}, nil)
+				}
+				v = sub
+			}
+		}
+		out[f] = v
+	}
+	return out
+}
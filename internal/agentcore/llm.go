@@ -0,0 +1,249 @@
+package agentcore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"cg-mentions-bot/internal/traces"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/anthropic"
+	"github.com/tmc/langchaingo/llms/fake"
+	"github.com/tmc/langchaingo/llms/ollama"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// LLM providers selectable in LLMConfig.
+const (
+	ProviderOpenAI    = "openai" // also any OpenAI-compatible server via BaseURL
+	ProviderAnthropic = "anthropic"
+	ProviderOllama    = "ollama"
+	ProviderFake      = "fake" // deterministic, offline
+)
+
+// DefaultFakeAnswer is what the fake provider answers when no responses are set.
+const DefaultFakeAnswer = "Final Answer: This is an offline answer from the fake LLM."
+
+// LLMConfig selects and configures one LLM provider.
+type LLMConfig struct {
+	Provider string // openai (default), anthropic, ollama or fake
+	Model    string
+	BaseURL  string // OpenAI-compatible endpoint, Anthropic base URL or Ollama server
+	APIKey   string // defaults to the provider's env var (OPENAI_API_KEY, ANTHROPIC_API_KEY)
+	// Timeout bounds each LLM call; when it fires the fallback provider is tried.
+	Timeout time.Duration
+	// Responses are replayed in order by the fake provider. A response of the
+	// form "call:<tool> <json args>" is returned as a native tool call.
+	Responses []string
+}
+
+// LLMConfigFromEnv reads the primary provider from LLM_* variables and, when
+// LLM_FALLBACK_PROVIDER is set, the fallback from LLM_FALLBACK_*. LLM_MODEL
+// falls back to OPENAI_MODEL.
+func LLMConfigFromEnv() (LLMConfig, *LLMConfig) {
+	primary := llmConfigFromEnv("LLM")
+	if primary.Model == "" {
+		primary.Model = os.Getenv("OPENAI_MODEL")
+	}
+	if os.Getenv("LLM_FALLBACK_PROVIDER") == "" {
+		return primary, nil
+	}
+	fallback := llmConfigFromEnv("LLM_FALLBACK")
+	return primary, &fallback
+}
+
+func llmConfigFromEnv(prefix string) LLMConfig {
+	c := LLMConfig{
+		Provider: strings.ToLower(strings.TrimSpace(os.Getenv(prefix + "_PROVIDER"))),
+		Model:    os.Getenv(prefix + "_MODEL"),
+		BaseURL:  os.Getenv(prefix + "_BASE_URL"),
+		APIKey:   os.Getenv(prefix + "_API_KEY"),
+	}
+	if v := os.Getenv(prefix + "_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Printf("invalid %s_TIMEOUT=%q, ignoring", prefix, v)
+		}
+		c.Timeout = d
+	}
+	if v := os.Getenv(prefix + "_FAKE_RESPONSES"); v != "" {
+		c.Responses = strings.Split(v, "||")
+	}
+	return c
+}
+
+// newModel builds the configured provider, wrapped with the fallback if any.
+// Both report token usage to the trace of the run.
+func newModel(primary LLMConfig, fallback *LLMConfig) (llms.Model, string, error) {
+	p, name, err := newProvider(primary)
+	if err != nil {
+		return nil, "", err
+	}
+	if fallback == nil {
+		return p, name, nil
+	}
+	f, fname, err := newProvider(*fallback)
+	if err != nil {
+		return nil, "", fmt.Errorf("fallback: %w", err)
+	}
+	return fallbackModel{primary: p, secondary: f, name: fname}, name, nil
+}
+
+func newProvider(c LLMConfig) (llms.Model, string, error) {
+	var (
+		m   llms.Model
+		err error
+	)
+	switch c.Provider {
+	case "", ProviderOpenAI:
+		if c.Model == "" {
+			c.Model = "gpt-4.1-mini"
+		}
+		opts := []openai.Option{openai.WithModel(c.Model)}
+		if c.BaseURL != "" {
+			opts = append(opts, openai.WithBaseURL(c.BaseURL))
+		}
+		if c.APIKey != "" {
+			opts = append(opts, openai.WithToken(c.APIKey))
+		}
+		if m, err = openai.New(opts...); err != nil {
+			return nil, "", fmt.Errorf("OPENAI_API_KEY is required or configure a provider supported by LangChainGo")
+		}
+	case ProviderAnthropic:
+		if c.Model == "" {
+			c.Model = "claude-3-5-haiku-latest"
+		}
+		opts := []anthropic.Option{anthropic.WithModel(c.Model)}
+		if c.BaseURL != "" {
+			opts = append(opts, anthropic.WithBaseURL(c.BaseURL))
+		}
+		if c.APIKey != "" {
+			opts = append(opts, anthropic.WithToken(c.APIKey))
+		}
+		if m, err = anthropic.New(opts...); err != nil {
+			return nil, "", fmt.Errorf("anthropic: %w", err)
+		}
+	case ProviderOllama:
+		if c.Model == "" {
+			c.Model = "llama3.1"
+		}
+		opts := []ollama.Option{ollama.WithModel(c.Model)}
+		if c.BaseURL != "" {
+			opts = append(opts, ollama.WithServerURL(c.BaseURL))
+		}
+		if m, err = ollama.New(opts...); err != nil {
+			return nil, "", fmt.Errorf("ollama: %w", err)
+		}
+	case ProviderFake:
+		c.Model = "fake"
+		responses := c.Responses
+		if len(responses) == 0 {
+			responses = []string{DefaultFakeAnswer}
+		}
+		m = &fakeModel{llm: fake.NewFakeLLM(responses)}
+	default:
+		return nil, "", fmt.Errorf("unknown LLM provider %q", c.Provider)
+	}
+	name := c.Provider
+	if name == "" {
+		name = ProviderOpenAI
+	}
+	name += "/" + c.Model
+	return tracedModel{Model: m, timeout: c.Timeout}, name, nil
+}
+
+// tracedModel applies the per-call timeout and adds token usage to the run's trace.
+type tracedModel struct {
+	llms.Model
+	timeout time.Duration
+}
+
+func (m tracedModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+	}
+	res, err := m.Model.GenerateContent(ctx, messages, options...)
+	if err != nil || res == nil || len(res.Choices) == 0 {
+		return res, err
+	}
+	if run := traces.FromContext(ctx); run != nil {
+		// Usage covers the whole response, every choice repeats it.
+		info := res.Choices[0].GenerationInfo
+		prompt := intInfo(info, "PromptTokens") + intInfo(info, "InputTokens")
+		completion := intInfo(info, "CompletionTokens") + intInfo(info, "OutputTokens")
+		total := intInfo(info, "TotalTokens")
+		if total == 0 {
+			total = prompt + completion
+		}
+		run.LLMCall(prompt, completion, total)
+	}
+	return res, nil
+}
+
+func (m tracedModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+// fallbackModel retries a failed or timed-out call on the secondary provider.
+// A cancelled request is not retried.
+type fallbackModel struct {
+	primary   llms.Model
+	secondary llms.Model
+	name      string
+}
+
+func (m fallbackModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	res, err := m.primary.GenerateContent(ctx, messages, options...)
+	if err == nil || ctx.Err() != nil {
+		return res, err
+	}
+	log.Printf("LLM call failed, falling back to %s: %v", m.name, err)
+	res, ferr := m.secondary.GenerateContent(ctx, messages, options...)
+	if ferr != nil {
+		return nil, errors.Join(err, fmt.Errorf("fallback %s: %w", m.name, ferr))
+	}
+	return res, nil
+}
+
+func (m fallbackModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+// fakeModel makes the fake LLM safe for concurrent mentions.
+type fakeModel struct {
+	mu    sync.Mutex
+	llm   *fake.LLM
+	calls int
+}
+
+func (m *fakeModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	res, err := m.llm.GenerateContent(ctx, messages, options...)
+	if err != nil || len(res.Choices) == 0 {
+		return res, err
+	}
+	if rest, ok := strings.CutPrefix(res.Choices[0].Content, "call:"); ok {
+		name, args, _ := strings.Cut(rest, " ")
+		res.Choices[0].Content = ""
+		res.Choices[0].ToolCalls = []llms.ToolCall{{
+			ID:           fmt.Sprintf("call_%d", m.calls),
+			Type:         "function",
+			FunctionCall: &llms.FunctionCall{Name: name, Arguments: args},
+		}}
+	}
+	return res, nil
+}
+
+func (m *fakeModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
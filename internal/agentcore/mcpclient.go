@@ -0,0 +1,212 @@
+package agentcore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// mcpTimeout bounds a single request to an MCP server.
+const mcpTimeout = 60 * time.Second
+
+// mcpClient is an MCP client over any ServerSpec transport. One client exists
+// per connection and is shared by every agent run; its session is opened on
+// first use and re-opened once when the server reports it as terminated.
+type mcpClient struct {
+	spec ServerSpec
+
+	mu  sync.Mutex
+	c   *client.Client
+	ids atomic.Int64
+
+	// Discovery cache and health, see discovery.go.
+	stateMu   sync.Mutex
+	tools     []map[string]any
+	listedAt  time.Time
+	listedGen int // gen the cached tools were listed at
+	gen       int // bumped by notifications/tools/list_changed
+	down      error
+	checkedAt time.Time
+}
+
+var (
+	mcpPoolMu sync.Mutex
+	mcpPool   = map[string]*mcpClient{}
+)
+
+// mcpFor returns the shared client for spec. No request is made until the
+// first call.
+func mcpFor(spec ServerSpec) *mcpClient {
+	key := spec.key()
+	mcpPoolMu.Lock()
+	defer mcpPoolMu.Unlock()
+	if m, ok := mcpPool[key]; ok {
+		return m
+	}
+	m := &mcpClient{spec: spec}
+	mcpPool[key] = m
+	return m
+}
+
+// dropMCP closes and forgets the client of spec, if any.
+func dropMCP(spec ServerSpec) {
+	key := spec.key()
+	mcpPoolMu.Lock()
+	m, ok := mcpPool[key]
+	delete(mcpPool, key)
+	mcpPoolMu.Unlock()
+	if ok {
+		m.mu.Lock()
+		if m.c != nil {
+			_ = m.c.Close()
+			m.c = nil
+		}
+		m.mu.Unlock()
+	}
+}
+
+// session returns the initialized client, performing the handshake
+// (initialize, then notifications/initialized) if there is none yet.
+func (m *mcpClient) session(ctx context.Context) (*client.Client, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.c != nil {
+		return m.c, nil
+	}
+	c, err := m.spec.newClient()
+	if err != nil {
+		return nil, err
+	}
+	c.OnNotification(func(n mcp.JSONRPCNotification) {
+		if n.Method == mcp.MethodNotificationToolsListChanged {
+			m.invalidate()
+		}
+	})
+	// The session outlives the run that opened it.
+	if err := c.Start(context.WithoutCancel(ctx)); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	if _, err := c.Initialize(ctx, mcp.InitializeRequest{
+		Params: mcp.InitializeParams{
+			ProtocolVersion: mcp.LATEST_PROTOCOL_VERSION,
+			Capabilities:    mcp.ClientCapabilities{},
+			ClientInfo:      mcp.Implementation{Name: "cg-mentions-bot", Version: "0.1.0"},
+		},
+	}); err != nil {
+		_ = c.Close()
+		return nil, fmt.Errorf("initialize %s: %w", m.target(), err)
+	}
+	m.c = c
+	return c, nil
+}
+
+// target names the server in errors.
+func (m *mcpClient) target() string {
+	if m.spec.transport() == TransportStdio {
+		return m.spec.Command
+	}
+	return m.spec.URL
+}
+
+// reset drops c so the next request opens a new session.
+func (m *mcpClient) reset(c *client.Client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.c == c {
+		m.c = nil
+		_ = c.Close()
+	}
+}
+
+// do runs fn on the current session, retrying once on a fresh session when
+// the server no longer knows the old one.
+func (m *mcpClient) do(ctx context.Context, fn func(*client.Client) error) error {
+	for retried := false; ; retried = true {
+		c, err := m.session(ctx)
+		if err != nil {
+			return err
+		}
+		err = fn(c)
+		if err == nil || retried || !errors.Is(err, transport.ErrSessionTerminated) {
+			return err
+		}
+		m.reset(c)
+	}
+}
+
+// call runs a tool for its text. Results the tool flags as errors are
+// returned as *toolError so they are never mistaken for output.
+func (m *mcpClient) call(ctx context.Context, name string, args map[string]any) (string, error) {
+	text, isErr, err := m.callTool(ctx, name, args)
+	if err != nil {
+		return "", err
+	}
+	if isErr {
+		return "", &toolError{tool: name, text: text}
+	}
+	return text, nil
+}
+
+// callTool returns the rendered result of a tool and whether the tool flagged
+// it as an error. JSON-RPC error objects are returned as errors.
+func (m *mcpClient) callTool(ctx context.Context, name string, args map[string]any) (string, bool, error) {
+	var raw json.RawMessage
+	err := m.do(ctx, func(c *client.Client) error {
+		// Sent on the transport directly: mcp-go's CallTool drops structuredContent
+		// and rejects content types it does not know.
+		resp, err := c.GetTransport().SendRequest(ctx, transport.JSONRPCRequest{
+			JSONRPC: mcp.JSONRPC_VERSION,
+			ID:      mcp.NewRequestId(fmt.Sprintf("call-%d", m.ids.Add(1))),
+			Method:  string(mcp.MethodToolsCall),
+			Params:  mcp.CallToolParams{Name: name, Arguments: args},
+		})
+		if err != nil {
+			return err
+		}
+		if resp.Error != nil {
+			return errors.New(resp.Error.Message)
+		}
+		raw = resp.Result
+		return nil
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %w", name, err)
+	}
+	res, err := parseToolResult(raw)
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %w", name, err)
+	}
+	return renderContent(res), res.IsError, nil
+}
+
+// listTools returns every tool of the server (all pages) as plain maps with
+// name, description and inputSchema.
+func (m *mcpClient) listTools(ctx context.Context) ([]map[string]any, error) {
+	var res *mcp.ListToolsResult
+	err := m.do(ctx, func(c *client.Client) error {
+		var err error
+		res, err = c.ListTools(ctx, mcp.ListToolsRequest{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(res.Tools)
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]any
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,169 @@
+package agentcore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/tmc/langchaingo/tools"
+)
+
+// Tool contexts a request can run in.
+const (
+	ContextMention = "mention" // public tweet mention (default)
+	ContextAPI     = "api"     // authenticated API call
+	ContextShadow  = "shadow"  // shadow mode, whatever the source
+)
+
+// ToolRules allows and denies tools by "server:tool" glob patterns
+// (path.Match syntax, e.g. "wallet:*" or "*:sign_*"). Deny wins. The tool is
+// matched by the server's own name for it, without any registry prefix.
+type ToolRules struct {
+	Allow []string `json:"allow" yaml:"allow"`
+	Deny  []string `json:"deny,omitempty" yaml:"deny,omitempty"`
+}
+
+// Policy maps each context to its tool rules. Contexts without rules get no
+// tools; a nil Policy allows everything.
+type Policy map[string]ToolRules
+
+// readOnly matches the tools that only look things up, by the naming the
+// built-in and data feed servers use ("get_*", "pyth.list_*", ...).
+var readOnly = []string{"*:get_*", "*:list_*", "*:read_*", "*:search_*", "*:*.get_*", "*:*.list_*"}
+
+// DefaultPolicy gives public mentions the read-only tools, replies and wallet
+// creation, nothing else: sends go through the wallet commands, where amounts
+// above the confirmation threshold wait for the author to confirm them. Shadow
+// runs also get the tools shadow mode simulates, so an unknown value-moving
+// tool never runs for real. Key export is kept from both; authenticated API
+// calls may use every tool.
+func DefaultPolicy() Policy {
+	keys := []string{"*:*private_key*", "*:export_*", "*:import_*"}
+	replies := []string{"x:twitter.post_reply", "*:create_wallet"}
+	shadowed := append(append([]string{}, replies...), "*:sign_*", "*:transfer_asset")
+	return Policy{
+		ContextMention: {Allow: append(append([]string{}, readOnly...), replies...), Deny: keys},
+		ContextShadow:  {Allow: append(append([]string{}, readOnly...), shadowed...), Deny: keys},
+		ContextAPI:     {Allow: []string{"*:*"}},
+	}
+}
+
+// LoadPolicy reads a policy from a JSON file, e.g.
+// {"mention":{"allow":["x:*","wallet:*"],"deny":["*:sign_*"]}}.
+func LoadPolicy(file string) (Policy, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var p Policy
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	for c, r := range p {
+		for _, pat := range append(append([]string{}, r.Allow...), r.Deny...) {
+			if _, err := path.Match(pat, "x:y"); err != nil {
+				return nil, fmt.Errorf("%s: context %s: bad pattern %q", file, c, pat)
+			}
+		}
+	}
+	return p, nil
+}
+
+// Allows reports whether tool of server may be used in context.
+func (p Policy) Allows(context, server, tool string) bool {
+	if p == nil {
+		return true
+	}
+	r, ok := p[context]
+	if !ok {
+		return false
+	}
+	key := server + ":" + tool
+	return matchAny(r.Allow, key) && !matchAny(r.Deny, key)
+}
+
+func matchAny(patterns []string, key string) bool {
+	for _, pat := range patterns {
+		if ok, _ := path.Match(pat, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// filter drops the tools context may not use, so the model never sees them.
+func (p Policy) filter(context string, list []tools.Tool) []tools.Tool {
+	if p == nil {
+		return list
+	}
+	out := make([]tools.Tool, 0, len(list))
+	for _, t := range list {
+		server, remote := originOf(t)
+		if p.Allows(context, server, remote) {
+			out = append(out, policyTool{Tool: t, policy: p, context: context, server: server, remote: remote})
+		}
+	}
+	return out
+}
+
+// originTool is a tool that knows its MCP server and the name the server
+// gives it, so policies and shadow mode are not bypassed by a tool prefix.
+type originTool interface {
+	origin() (server, remote string)
+}
+
+func originOf(t tools.Tool) (server, remote string) {
+	if o, ok := t.(originTool); ok {
+		return o.origin()
+	}
+	return "", t.Name()
+}
+
+// policyTool checks the policy again when called, in case a tool is reached by
+// a name the model made up or the policy changed.
+type policyTool struct {
+	tools.Tool
+	policy  Policy
+	context string
+	server  string
+	remote  string
+}
+
+func (t policyTool) origin() (string, string) { return t.server, t.remote }
+
+func (t policyTool) Call(ctx context.Context, input string) (string, error) {
+	if !t.policy.Allows(t.context, t.server, t.remote) {
+		return fmt.Sprintf("%s is not available for %s requests. Do not call it; tell the user to use a supported command instead.", t.Name(), t.context), nil
+	}
+	return t.Tool.Call(ctx, input)
+}
+
+// Summary and InputSchema keep native tool definitions intact behind the policy.
+func (t policyTool) Summary() string {
+	if st, ok := t.Tool.(schemaTool); ok {
+		return st.Summary()
+	}
+	return t.Description()
+}
+
+func (t policyTool) InputSchema() map[string]any {
+	if st, ok := t.Tool.(schemaTool); ok {
+		return st.InputSchema()
+	}
+	return nil
+}
+
+// PolicyFromEnv loads TOOL_POLICY_FILE, or DefaultPolicy when it is unset.
+// TOOL_POLICY=off disables policies.
+func PolicyFromEnv() (Policy, error) {
+	if strings.EqualFold(os.Getenv("TOOL_POLICY"), "off") {
+		return nil, nil
+	}
+	if file := os.Getenv("TOOL_POLICY_FILE"); file != "" {
+		return LoadPolicy(file)
+	}
+	return DefaultPolicy(), nil
+}
@@ -0,0 +1,320 @@
+package agentcore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"gopkg.in/yaml.v3"
+)
+
+// Transports an MCP server can be reached over.
+const (
+	TransportHTTP  = "http" // streamable HTTP (default)
+	TransportSSE   = "sse"
+	TransportStdio = "stdio"
+)
+
+// Server names with a built-in role: "x" posts replies (only its
+// twitter.post_reply, exposed as x_post_reply), "wallet" creates and reads user wallets and "goldrush"
+// results are shaped to fit the context.
+const (
+	ServerX        = "x"
+	ServerWallet   = "wallet"
+	ServerSolana   = "solana"
+	ServerGoldRush = "goldrush"
+)
+
+// DefaultReloadInterval is how often a watched registry file is checked.
+const DefaultReloadInterval = 30 * time.Second
+
+var identRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ServerSpec is one MCP server of the registry.
+type ServerSpec struct {
+	Name      string `json:"name" yaml:"name"`
+	Transport string `json:"transport,omitempty" yaml:"transport,omitempty"`
+	URL       string `json:"url,omitempty" yaml:"url,omitempty"`
+	// Headers are sent with every HTTP or SSE request; values may reference
+	// the environment, e.g. "Bearer ${GOLDRUSH_TOKEN}".
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	// Command, Args and Env start a stdio server.
+	Command string   `json:"command,omitempty" yaml:"command,omitempty"`
+	Args    []string `json:"args,omitempty" yaml:"args,omitempty"`
+	Env     []string `json:"env,omitempty" yaml:"env,omitempty"`
+	// ToolPrefix is prepended to every tool name the model sees; calls use
+	// the server's own names.
+	ToolPrefix string `json:"tool_prefix,omitempty" yaml:"tool_prefix,omitempty"`
+	// Enabled defaults to true.
+	Enabled *bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+}
+
+func (s ServerSpec) enabled() bool { return s.Enabled == nil || *s.Enabled }
+
+func (s ServerSpec) transport() string {
+	if s.Transport == "" {
+		return TransportHTTP
+	}
+	return strings.ToLower(s.Transport)
+}
+
+func (s ServerSpec) validate() error {
+	if !identRe.MatchString(s.Name) {
+		return fmt.Errorf("server name %q must be letters, digits, _ or -", s.Name)
+	}
+	if s.ToolPrefix != "" && !identRe.MatchString(s.ToolPrefix) {
+		return fmt.Errorf("server %s: tool_prefix %q must be letters, digits, _ or -", s.Name, s.ToolPrefix)
+	}
+	switch s.transport() {
+	case TransportHTTP, TransportSSE:
+		if strings.TrimSpace(s.URL) == "" {
+			return fmt.Errorf("server %s: url is required for %s", s.Name, s.transport())
+		}
+	case TransportStdio:
+		if strings.TrimSpace(s.Command) == "" {
+			return fmt.Errorf("server %s: command is required for stdio", s.Name)
+		}
+	default:
+		return fmt.Errorf("server %s: unknown transport %q", s.Name, s.Transport)
+	}
+	return nil
+}
+
+// key identifies the connection a spec needs; specs with the same key share
+// one client.
+func (s ServerSpec) key() string {
+	if s.transport() == TransportStdio {
+		return strings.Join(append(append([]string{TransportStdio, s.Command}, s.Args...), s.Env...), "\x00")
+	}
+	parts := []string{s.transport(), strings.TrimSpace(s.URL)}
+	names := make([]string, 0, len(s.Headers))
+	for k := range s.Headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		parts = append(parts, k+"="+s.Headers[k])
+	}
+	return strings.Join(parts, "\x00")
+}
+
+func (s ServerSpec) headers() map[string]string {
+	h := make(map[string]string, len(s.Headers))
+	for k, v := range s.Headers {
+		h[k] = os.ExpandEnv(v)
+	}
+	return h
+}
+
+// newClient creates an unstarted client for the spec's transport.
+func (s ServerSpec) newClient() (*client.Client, error) {
+	switch s.transport() {
+	case TransportSSE:
+		return client.NewSSEMCPClient(s.URL, client.WithHeaders(s.headers()))
+	case TransportStdio:
+		return client.NewClient(transport.NewStdio(s.Command, s.Env, s.Args...)), nil
+	}
+	// Listening keeps a GET stream open for notifications sent between calls,
+	// such as notifications/tools/list_changed.
+	return client.NewStreamableHttpClient(s.URL, transport.WithHTTPTimeout(mcpTimeout), transport.WithHTTPHeaders(s.headers()),
+		transport.WithContinuousListening())
+}
+
+// Registry is the list of MCP servers loaded from a YAML or JSON file:
+//
+//	servers:
+//	  - name: goldrush
+//	    url: http://localhost:8083/mcp
+//	    headers: {Authorization: "Bearer ${GOLDRUSH_TOKEN}"}
+//	    tool_prefix: gr_
+//	  - name: coingecko
+//	    transport: stdio
+//	    command: npx
+//	    args: ["-y", "@coingecko/coingecko-mcp"]
+//	    enabled: false
+//
+// Reload re-reads the file when it changed; agents pick up the new list on
+// their next request.
+type Registry struct {
+	file string
+
+	mu      sync.RWMutex
+	servers []ServerSpec
+	raw     []byte
+	version int
+}
+
+// LoadRegistry reads and validates file.
+func LoadRegistry(file string) (*Registry, error) {
+	r := &Registry{file: file}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Servers returns the enabled servers and the registry version, which
+// changes on every successful reload.
+func (r *Registry) Servers() ([]ServerSpec, int) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]ServerSpec, 0, len(r.servers))
+	for _, s := range r.servers {
+		if s.enabled() {
+			out = append(out, s)
+		}
+	}
+	return out, r.version
+}
+
+// Reload re-reads the file and applies it if its content changed. On error
+// the previous servers are kept.
+func (r *Registry) Reload() (bool, error) {
+	b, err := os.ReadFile(r.file)
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	unchanged := r.version > 0 && bytes.Equal(b, r.raw)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+	servers, err := parseServers(r.file, b)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	old := r.servers
+	r.servers = servers
+	r.raw = b
+	r.version++
+	r.mu.Unlock()
+
+	// Connections no server uses any more are closed (stdio processes exit).
+	keep := map[string]bool{}
+	for _, s := range servers {
+		if s.enabled() {
+			keep[s.key()] = true
+		}
+	}
+	for _, s := range old {
+		if !keep[s.key()] {
+			dropMCP(s)
+		}
+	}
+	return true, nil
+}
+
+// Watch reloads the file every interval until ctx is done.
+func (r *Registry) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if changed, err := r.Reload(); err != nil {
+				log.Printf("mcp registry: keeping previous servers, reload of %s failed: %v", r.file, err)
+			} else if changed {
+				log.Printf("mcp registry: reloaded %s", r.file)
+			}
+		}
+	}
+}
+
+// parseServers decodes b, read from file, as YAML (.yaml, .yml) or JSON.
+func parseServers(file string, b []byte) ([]ServerSpec, error) {
+	var err error
+	var doc struct {
+		Servers []ServerSpec `json:"servers" yaml:"servers"`
+	}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &doc)
+	default:
+		err = json.Unmarshal(b, &doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	seen := map[string]bool{}
+	for _, s := range doc.Servers {
+		if err := s.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("%s: server %s is listed twice", file, s.Name)
+		}
+		seen[s.Name] = true
+	}
+	return doc.Servers, nil
+}
+
+// RegistryFromEnv loads MCP_SERVERS_FILE; it returns nil when it is unset.
+func RegistryFromEnv() (*Registry, error) {
+	file := strings.TrimSpace(os.Getenv("MCP_SERVERS_FILE"))
+	if file == "" {
+		return nil, nil
+	}
+	return LoadRegistry(file)
+}
+
+// server finds the server called name in cfg.
+func (cfg Config) server(name string) (ServerSpec, bool) {
+	servers, _ := cfg.servers()
+	for _, s := range servers {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return ServerSpec{}, false
+}
+
+// servers lists the servers of cfg: the URL fields first, then the registry,
+// whose entries replace URL fields of the same name.
+func (cfg Config) servers() ([]ServerSpec, int) {
+	var out []ServerSpec
+	for _, s := range []ServerSpec{
+		{Name: ServerX, URL: cfg.XMCP},
+		{Name: ServerSolana, URL: cfg.SolanaMCP},
+		{Name: ServerGoldRush, URL: cfg.GoldRushMCP},
+		{Name: ServerWallet, URL: cfg.WalletMCP},
+	} {
+		if strings.TrimSpace(s.URL) != "" {
+			out = append(out, s)
+		}
+	}
+	if cfg.Registry == nil {
+		return out, 0
+	}
+	reg, version := cfg.Registry.Servers()
+	for _, s := range reg {
+		replaced := false
+		for i := range out {
+			if out[i].Name == s.Name {
+				out[i], replaced = s, true
+			}
+		}
+		if !replaced {
+			out = append(out, s)
+		}
+	}
+	return out, version
+}
@@ -0,0 +1,292 @@
+package agentcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	hexAddressRe = regexp.MustCompile(`^(?:0[xX])?[0-9a-fA-F]{40}$`)
+	numberRe     = regexp.MustCompile(`^-?\d+(?:\.\d+)?(?:[eE][+-]?\d+)?$`)
+)
+
+// hexFormats are the string formats that require a hex address.
+var hexFormats = map[string]bool{"address": true, "evm-address": true, "eth-address": true}
+
+// validator checks and coerces tool arguments against a compiled inputSchema.
+type validator struct {
+	typ        string
+	properties map[string]*validator
+	required   []string
+	items      *validator
+	enum       []any
+	pattern    *regexp.Regexp
+	format     string
+	address    bool // address-like name: hex values are trimmed and 0x-prefixed
+	raw        map[string]any
+}
+
+// argProblem is one invalid or missing argument.
+type argProblem struct {
+	Field   string `json:"field"`
+	Problem string `json:"problem"`
+	Got     any    `json:"got,omitempty"`
+}
+
+// compileSchema compiles the subset of JSON schema MCP servers use for tool
+// inputs: type, properties, required, items, enum, pattern and the address
+// formats in hexFormats. A nil schema accepts anything. Field names only guide
+// coercion; addresses are rejected only where the schema asks for them, since
+// servers also take xpubs, bech32 addresses and ENS names.
+func compileSchema(schema map[string]any) *validator {
+	if schema == nil {
+		return nil
+	}
+	return compileNode("", schema)
+}
+
+func compileNode(name string, s map[string]any) *validator {
+	v := &validator{raw: s}
+	v.typ, _ = s["type"].(string)
+	if v.typ == "" {
+		if types, ok := s["type"].([]any); ok && len(types) > 0 {
+			// ["string","null"]: check the first non-null type.
+			for _, t := range types {
+				if ts, _ := t.(string); ts != "" && ts != "null" {
+					v.typ = ts
+					break
+				}
+			}
+		}
+	}
+	if props, ok := s["properties"].(map[string]any); ok {
+		v.properties = make(map[string]*validator, len(props))
+		for pname, p := range props {
+			if pm, ok := p.(map[string]any); ok {
+				v.properties[pname] = compileNode(pname, pm)
+			}
+		}
+	}
+	for _, r := range asSlice(s["required"]) {
+		if rs, ok := r.(string); ok {
+			v.required = append(v.required, rs)
+		}
+	}
+	if items, ok := s["items"].(map[string]any); ok {
+		v.items = compileNode(name, items)
+	}
+	v.enum = asSlice(s["enum"])
+	if p, ok := s["pattern"].(string); ok {
+		if re, err := regexp.Compile(p); err == nil {
+			v.pattern = re
+		}
+	}
+	v.format, _ = s["format"].(string)
+	lname := strings.ToLower(name)
+	v.address = v.typ == "string" && (strings.Contains(lname, "address") || lname == "to" || lname == "from")
+	return v
+}
+
+func asSlice(v any) []any {
+	switch s := v.(type) {
+	case []any:
+		return s
+	case []string:
+		out := make([]any, len(s))
+		for i, x := range s {
+			out[i] = x
+		}
+		return out
+	}
+	return nil
+}
+
+// parseArgs decodes a tool input and checks it against v. On failure it
+// returns an observation telling the model what to fix.
+func parseArgs(tool, input string, v *validator) (map[string]any, string) {
+	args := map[string]any{}
+	if in := strings.TrimSpace(input); in != "" {
+		dec := json.NewDecoder(bytes.NewReader([]byte(in)))
+		dec.UseNumber() // keep wei amounts exact
+		if err := dec.Decode(&args); err != nil {
+			return nil, invalidArgs(tool, v, []argProblem{{Field: "(input)", Problem: "must be a JSON object: " + err.Error()}})
+		}
+	}
+	if v == nil {
+		return args, ""
+	}
+	if problems := v.check("", args); len(problems) > 0 {
+		return nil, invalidArgs(tool, v, problems)
+	}
+	return args, ""
+}
+
+// invalidArgs renders problems as a JSON observation including the schema, so
+// the model can correct the call.
+func invalidArgs(tool string, v *validator, problems []argProblem) string {
+	obs := map[string]any{
+		"error":    "invalid_arguments",
+		"tool":     tool,
+		"problems": problems,
+		"hint":     "Fix the arguments and call the tool again.",
+	}
+	if v != nil {
+		obs["expected_schema"] = v.raw
+	}
+	b, _ := json.Marshal(obs)
+	return string(b)
+}
+
+// check validates val, found under field, coercing nested values in place.
+func (v *validator) check(field string, val any) []argProblem {
+	label := field
+	if label == "" {
+		label = "(input)"
+	}
+	if len(v.enum) > 0 && !inEnum(val, v.enum) {
+		return []argProblem{{Field: label, Problem: fmt.Sprintf("must be one of %v", v.enum), Got: val}}
+	}
+
+	switch v.typ {
+	case "object":
+		obj, ok := val.(map[string]any)
+		if !ok {
+			return []argProblem{{Field: label, Problem: "must be an object", Got: val}}
+		}
+		var problems []argProblem
+		for _, r := range v.required {
+			if x, ok := obj[r]; !ok || x == nil {
+				problems = append(problems, argProblem{Field: join(field, r), Problem: "is required"})
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			p, ok := v.properties[name]
+			if !ok || obj[name] == nil {
+				continue
+			}
+			obj[name] = p.coerce(obj[name])
+			problems = append(problems, p.check(join(field, name), obj[name])...)
+		}
+		return problems
+	case "array":
+		arr, ok := val.([]any)
+		if !ok {
+			return []argProblem{{Field: label, Problem: "must be an array", Got: val}}
+		}
+		var problems []argProblem
+		if v.items != nil {
+			for i := range arr {
+				arr[i] = v.items.coerce(arr[i])
+				problems = append(problems, v.items.check(fmt.Sprintf("%s[%d]", label, i), arr[i])...)
+			}
+		}
+		return problems
+	case "string":
+		s, ok := val.(string)
+		if !ok {
+			return []argProblem{{Field: label, Problem: "must be a string", Got: val}}
+		}
+		if hexFormats[v.format] && !hexAddressRe.MatchString(s) {
+			return []argProblem{{Field: label, Problem: "must be a 0x-prefixed 40 hex character address", Got: val}}
+		}
+		if v.pattern != nil && !v.pattern.MatchString(s) {
+			return []argProblem{{Field: label, Problem: "must match " + v.pattern.String(), Got: val}}
+		}
+	case "integer":
+		n, ok := val.(json.Number)
+		if !ok {
+			return []argProblem{{Field: label, Problem: "must be an integer", Got: val}}
+		}
+		if _, ok := new(big.Int).SetString(n.String(), 10); !ok {
+			return []argProblem{{Field: label, Problem: "must be an integer", Got: val}}
+		}
+	case "number":
+		if _, ok := val.(json.Number); !ok {
+			return []argProblem{{Field: label, Problem: "must be a number", Got: val}}
+		}
+	case "boolean":
+		if _, ok := val.(bool); !ok {
+			return []argProblem{{Field: label, Problem: "must be true or false", Got: val}}
+		}
+	}
+	return nil
+}
+
+// coerce applies the safe conversions: numbers to decimal strings (wei amounts
+// must not go through float64), numeric strings to numbers, "true"/"false" to
+// booleans and hex addresses to their 0x-prefixed form.
+func (v *validator) coerce(val any) any {
+	switch v.typ {
+	case "string":
+		switch x := val.(type) {
+		case json.Number:
+			return plainNumber(x)
+		case string:
+			if s := strings.TrimSpace(x); (v.address || hexFormats[v.format]) && hexAddressRe.MatchString(s) {
+				return "0x" + s[len(s)-40:]
+			}
+		}
+	case "integer", "number":
+		if s, ok := val.(string); ok {
+			s = strings.TrimSpace(s)
+			if numberRe.MatchString(s) {
+				n := json.Number(s)
+				if v.typ == "integer" {
+					return json.Number(plainNumber(n))
+				}
+				return n
+			}
+		}
+	case "boolean":
+		if s, ok := val.(string); ok {
+			switch strings.ToLower(strings.TrimSpace(s)) {
+			case "true":
+				return true
+			case "false":
+				return false
+			}
+		}
+	}
+	return val
+}
+
+// plainNumber writes n without an exponent when it is integral ("1e18" becomes
+// "1000000000000000000"); other values keep their literal form.
+func plainNumber(n json.Number) string {
+	s := n.String()
+	if !strings.ContainsAny(s, "eE.") {
+		return s
+	}
+	f, ok := new(big.Float).SetPrec(256).SetString(s)
+	if !ok || !f.IsInt() {
+		return s
+	}
+	i, _ := f.Int(nil)
+	return i.String()
+}
+
+func inEnum(val any, enum []any) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(val) {
+			return true
+		}
+	}
+	return false
+}
+
+func join(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
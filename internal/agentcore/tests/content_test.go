@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"cg-mentions-bot/internal/agentcore"
+	"cg-mentions-bot/internal/traces"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func text(s string) map[string]any { return map[string]any{"type": "text", "text": s} }
+
+func TestToolContent(t *testing.T) {
+	ctx := context.Background()
+	hash := "0x" + strings.Repeat("ab", 32)
+
+	t.Run("text parts, resources and structured content are all rendered", func(t *testing.T) {
+		mcp := &fakeMCP{results: map[string]map[string]any{
+			"get_wallet_balance": {
+				"content": []any{
+					text("Balance:"),
+					text("1.25 BNB"),
+					map[string]any{"type": "resource", "resource": map[string]any{"uri": "wallet://7", "mimeType": "application/json", "text": `{"chain":56}`}},
+				},
+				"structuredContent": map[string]any{"wei": "1250000000000000000"},
+			},
+		}}
+
+		out, err := agentcore.NewToolClient(mcp.server(t).URL).Call(ctx, "get_wallet_balance", nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, "Balance:\n1.25 BNB\n"+
+			`{"mimeType":"application/json","text":"{\"chain\":56}","type":"resource","uri":"wallet://7"}`+"\n"+
+			`{"wei":"1250000000000000000"}`, out)
+	})
+
+	t.Run("structured content already given as text is kept once", func(t *testing.T) {
+		mcp := &fakeMCP{results: map[string]map[string]any{
+			"get_wallet_balance": {
+				"content":           []any{text(`{ "wei": "1" }`)},
+				"structuredContent": map[string]any{"wei": "1"},
+			},
+		}}
+
+		out, err := agentcore.NewToolClient(mcp.server(t).URL).Call(ctx, "get_wallet_balance", nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, `{ "wei": "1" }`, out)
+	})
+
+	t.Run("results flagged isError are errors for direct callers", func(t *testing.T) {
+		mcp := &fakeMCP{results: map[string]map[string]any{
+			"transfer_asset": {"content": []any{text("insufficient funds")}, "isError": true},
+		}}
+
+		_, err := agentcore.NewToolClient(mcp.server(t).URL).Call(ctx, "transfer_asset", nil)
+
+		assert.EqualError(t, err, "transfer_asset: insufficient funds")
+	})
+
+	t.Run("a failed tool reaches the model as a tool_error and its hashes are not reported", func(t *testing.T) {
+		mcp := &fakeMCP{results: map[string]map[string]any{
+			"transfer_asset": {"content": []any{text("replacement underpriced for " + hash)}, "isError": true},
+		}}
+		store := traces.NewMemoryStore()
+		a, err := agentcore.NewAgent(agentcore.Config{
+			WalletMCP: mcp.server(t).URL,
+			Mode:      agentcore.ModeFunctions,
+			Traces:    store,
+			LLM: agentcore.LLMConfig{Provider: agentcore.ProviderFake, Responses: []string{
+				`call:transfer_asset {"twitter_id":"7","to_address":"0x52908400098527886E0F7030069857D2E4169EE7","amount":"1","chain_id":56}`,
+				"The transfer failed.",
+			}},
+		})
+		require.NoError(t, err)
+
+		res, err := a.Run(ctx, types.AgentRequest{Question: "send", TwitterID: "7", ReplyTo: "300"})
+		assert.ErrorContains(t, err, "X_MCP_HTTP is required")
+		assert.Empty(t, res.TxHashes)
+
+		tr, _ := store.Get(ctx, "300")
+		require.NotNil(t, tr)
+		require.Len(t, tr.Steps, 1)
+		assert.Contains(t, tr.Steps[0].Output, `"error":"tool_error"`)
+		assert.Contains(t, tr.Steps[0].Output, "replacement underpriced")
+		assert.NotEmpty(t, tr.Steps[0].Error)
+	})
+
+	t.Run("only hashes returned by value-moving tools are reported", func(t *testing.T) {
+		sent := "0x" + strings.Repeat("b", 64)
+		mcp := &fakeMCP{results: map[string]map[string]any{
+			"get_wallet_balance": {"content": []any{text("last tx " + hash)}},
+			"transfer_asset":     {"content": []any{text(sent)}},
+		}}
+		a, err := agentcore.NewAgent(agentcore.Config{
+			WalletMCP: mcp.server(t).URL,
+			Mode:      agentcore.ModeFunctions,
+			LLM: agentcore.LLMConfig{Provider: agentcore.ProviderFake, Responses: []string{
+				`call:get_wallet_balance {"twitter_id":"7"}`,
+				`call:transfer_asset {"twitter_id":"7","to_address":"0x52908400098527886E0F7030069857D2E4169EE7","amount":"1","chain_id":56}`,
+				"Done.",
+			}},
+		})
+		require.NoError(t, err)
+
+		res, err := a.Run(ctx, types.AgentRequest{Question: "send", TwitterID: "7"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{sent}, res.TxHashes)
+	})
+
+	t.Run("oversized observations are summarized before they reach the model", func(t *testing.T) {
+		items := make([]any, 500)
+		for i := range items {
+			items[i] = map[string]any{"tx_hash": fmt.Sprintf("0x%064d", i), "value": "1"}
+		}
+		mcp := &fakeMCP{results: map[string]map[string]any{
+			"get_wallet_balance": {"content": []any{}, "structuredContent": map[string]any{"items": items}},
+			"transfer_asset":     {"content": []any{text(strings.Repeat("log line\n", 2000))}},
+		}}
+		store := traces.NewMemoryStore()
+		a, err := agentcore.NewAgent(agentcore.Config{
+			WalletMCP: mcp.server(t).URL,
+			Mode:      agentcore.ModeFunctions,
+			Traces:    store,
+			LLM: agentcore.LLMConfig{Provider: agentcore.ProviderFake, Responses: []string{
+				`call:get_wallet_balance {"twitter_id":"7"}`,
+				`call:transfer_asset {"twitter_id":"7","to_address":"0x52908400098527886E0F7030069857D2E4169EE7","amount":"1","chain_id":56}`,
+				"Done.",
+			}},
+		})
+		require.NoError(t, err)
+
+		_, _ = a.Run(ctx, types.AgentRequest{Question: "history", TwitterID: "7", ReplyTo: "400"})
+
+		tr, _ := store.Get(ctx, "400")
+		require.NotNil(t, tr)
+		require.Len(t, tr.Steps, 2)
+		assert.LessOrEqual(t, len(tr.Steps[0].Output), 6000)
+		assert.Contains(t, tr.Steps[0].Output, "… 495 more items")
+		assert.LessOrEqual(t, len(tr.Steps[1].Output), 6000)
+		assert.Contains(t, tr.Steps[1].Output, "[output truncated: showed")
+	})
+}
@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"cg-mentions-bot/internal/agentcore"
+	"cg-mentions-bot/internal/traces"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lists(f *fakeMCP) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lists
+}
+
+// promptOf runs one request on a and returns the prompt the model was given.
+func promptOf(t *testing.T, a *agentcore.Agent, store *traces.MemoryStore) string {
+	t.Helper()
+	_, _ = a.Run(context.Background(), types.AgentRequest{Question: "my tokens?", TwitterID: "7", ReplyTo: "500"})
+	tr, _ := store.Get(context.Background(), "500")
+	require.NotNil(t, tr)
+	return tr.Prompt
+}
+
+func TestDiscovery(t *testing.T) {
+	fake := agentcore.LLMConfig{Provider: agentcore.ProviderFake, Responses: []string{"Done."}}
+
+	t.Run("tools are listed once per TTL across AskAgent calls", func(t *testing.T) {
+		mcp := &fakeMCP{}
+		cfg := agentcore.Config{SolanaMCP: mcp.server(t).URL, Mode: agentcore.ModeFunctions, LLM: fake}
+
+		for i := 0; i < 3; i++ {
+			_, err := agentcore.AskAgent(context.Background(), "q", "7", "", nil, cfg)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 1, lists(mcp))
+
+		cfg.ToolsTTL = time.Nanosecond
+		_, err := agentcore.AskAgent(context.Background(), "q", "7", "", nil, cfg)
+		require.NoError(t, err)
+		assert.Equal(t, 2, lists(mcp))
+	})
+
+	t.Run("a tools/list_changed notification refreshes the list", func(t *testing.T) {
+		mcp := &fakeMCP{notify: true}
+		cfg := agentcore.Config{SolanaMCP: mcp.server(t).URL}
+
+		askWith(t, cfg, `call:get_wallet_balance {"twitter_id":"7"}`)
+		assert.Equal(t, 1, lists(mcp))
+		askWith(t, cfg)
+		assert.Equal(t, 2, lists(mcp))
+		askWith(t, cfg)
+		assert.Equal(t, 2, lists(mcp))
+	})
+
+	t.Run("a tools/list_changed notification sent between calls refreshes the list", func(t *testing.T) {
+		mcp := &fakeMCP{push: make(chan struct{})}
+		cfg := agentcore.Config{SolanaMCP: mcp.server(t).URL}
+
+		askWith(t, cfg)
+		assert.Equal(t, 1, lists(mcp))
+		require.Eventually(t, func() bool {
+			mcp.mu.Lock()
+			defer mcp.mu.Unlock()
+			return mcp.streams > 0
+		}, 2*time.Second, 10*time.Millisecond)
+
+		// No request is in flight: the notification arrives on the GET stream.
+		mcp.push <- struct{}{}
+		assert.Eventually(t, func() bool {
+			askWith(t, cfg)
+			return lists(mcp) == 2
+		}, 2*time.Second, 20*time.Millisecond)
+		askWith(t, cfg)
+		assert.Equal(t, 2, lists(mcp))
+	})
+
+	t.Run("tools of a server that is down are removed and the agent is told", func(t *testing.T) {
+		up, down := &fakeMCP{}, &fakeMCP{down: true}
+		store := traces.NewMemoryStore()
+		a, err := agentcore.NewAgent(agentcore.Config{
+			SolanaMCP:   up.server(t).URL,
+			GoldRushMCP: down.server(t).URL,
+			Mode:        agentcore.ModeFunctions,
+			Traces:      store,
+			LLM:         fake,
+		})
+		require.NoError(t, err)
+
+		prompt := promptOf(t, a, store)
+		assert.Contains(t, prompt, "temporarily unavailable: on-chain analytics (balances, transactions, prices) (goldrush)")
+		assert.NotContains(t, prompt, "(solana)")
+		assert.Equal(t, 1, lists(up))
+		assert.Error(t, a.Warm())
+
+		// The server is not asked again until it is retried or checked.
+		down.mu.Lock()
+		down.down = false
+		down.mu.Unlock()
+		promptOf(t, a, store)
+		assert.Equal(t, 0, lists(down))
+		assert.Empty(t, callNames(down))
+	})
+
+	t.Run("a successful health check brings the tools back", func(t *testing.T) {
+		mcp := &fakeMCP{down: true}
+		store := traces.NewMemoryStore()
+		a, err := agentcore.NewAgent(agentcore.Config{GoldRushMCP: mcp.server(t).URL, Mode: agentcore.ModeFunctions, Traces: store, LLM: fake})
+		require.NoError(t, err)
+		assert.Contains(t, promptOf(t, a, store), "(goldrush)")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go a.Monitor(ctx, 10*time.Millisecond)
+		mcp.mu.Lock()
+		mcp.down = false
+		mcp.mu.Unlock()
+
+		assert.Eventually(t, func() bool {
+			return !strings.Contains(promptOf(t, a, store), "unavailable")
+		}, 2*time.Second, 20*time.Millisecond)
+		assert.Equal(t, 1, lists(mcp))
+	})
+	t.Run("a slow server does not hold up other requests while its tools are listed", func(t *testing.T) {
+		slow, wallet := &fakeMCP{hold: make(chan struct{})}, &fakeMCP{}
+		a, err := agentcore.NewAgent(agentcore.Config{
+			GoldRushMCP: slow.server(t).URL,
+			WalletMCP:   wallet.server(t).URL,
+			Mode:        agentcore.ModeFunctions,
+			LLM:         fake,
+		})
+		require.NoError(t, err)
+
+		warmed := make(chan struct{})
+		go func() {
+			_ = a.Warm()
+			close(warmed)
+		}()
+		require.Eventually(t, func() bool { return lists(slow) == 1 }, 2*time.Second, 5*time.Millisecond)
+
+		ran := make(chan struct{})
+		go func() {
+			_, _ = a.Run(context.Background(), types.AgentRequest{Question: "hi", TwitterID: "7"})
+			close(ran)
+		}()
+		assert.Eventually(t, func() bool { return len(callNames(wallet)) > 0 }, 2*time.Second, 5*time.Millisecond)
+
+		close(slow.hold)
+		<-warmed
+		<-ran
+	})
+}
@@ -0,0 +1,334 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"cg-mentions-bot/internal/agentcore"
+	"cg-mentions-bot/internal/traces"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMCP is a streamable HTTP MCP server with tools/list and tools/call. It
+// records the calls, the sessions it opened and the session of every request.
+type fakeMCP struct {
+	mu       sync.Mutex
+	calls    []map[string]any
+	inits    int
+	sessions []string                  // Mcp-Session-Id of each tools/* request
+	expired  bool                      // answer the next session request with 404
+	results  map[string]map[string]any // tools/call result by tool name
+	tools    []any                     // tools/list override
+	lists    int                       // tools/list requests served
+	down     bool                      // answer every request with 503
+	notify   bool                      // send tools/list_changed with every tools/call result
+	hold     chan struct{}             // tools/list waits until it is closed
+	push     chan struct{}             // sends tools/list_changed on the GET stream; nil answers GET with 405
+	streams  int                       // GET streams opened
+}
+
+func (f *fakeMCP) server(t *testing.T) *httptest.Server {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			f.listen(w, r, done)
+			return
+		}
+		if r.Method != http.MethodPost {
+			return
+		}
+		var req struct {
+			ID     any            `json:"id"`
+			Method string         `json:"method"`
+			Params map[string]any `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		f.mu.Lock()
+		if f.down {
+			f.mu.Unlock()
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		session := r.Header.Get("Mcp-Session-Id")
+		if session != "" && f.expired {
+			f.expired = false
+			f.mu.Unlock()
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
+		}
+		if req.Method == "initialize" {
+			f.inits++
+			session = fmt.Sprintf("session-%d", f.inits)
+		} else {
+			f.sessions = append(f.sessions, session)
+		}
+		f.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Mcp-Session-Id", session)
+		if req.Method == "tools/call" && req.Params["name"] == "broken" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID,
+				"error": map[string]any{"code": -32603, "message": "backend unavailable"}})
+			return
+		}
+		var result any
+		switch req.Method {
+		case "initialize":
+			result = map[string]any{
+				"protocolVersion": "2025-06-18",
+				"capabilities":    map[string]any{"tools": map[string]any{}},
+				"serverInfo":      map[string]any{"name": "fake", "version": "1"},
+			}
+		case "tools/list":
+			f.mu.Lock()
+			f.lists++
+			hold := f.hold
+			f.mu.Unlock()
+			if hold != nil {
+				<-hold
+			}
+			if f.tools != nil {
+				result = map[string]any{"tools": f.tools}
+				break
+			}
+			result = map[string]any{"tools": []any{map[string]any{
+				"name":        "get_wallet_balance",
+				"description": "Native balance of a user's wallet.",
+				"inputSchema": map[string]any{
+					"type":       "object",
+					"properties": map[string]any{"twitter_id": map[string]any{"type": "string"}, "chain_id": map[string]any{"type": "string"}},
+					"required":   []any{"twitter_id"},
+				},
+			}, map[string]any{
+				"name":        "sign_transaction",
+				"description": "Sign a raw transaction.",
+				"inputSchema": map[string]any{"type": "object"},
+			}, map[string]any{
+				"name":        "transfer_asset",
+				"description": "Send native tokens.",
+				"inputSchema": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"twitter_id": map[string]any{"type": "string"},
+						"to_address": map[string]any{"type": "string"},
+						"amount":     map[string]any{"type": "string", "description": "wei"},
+						"chain_id":   map[string]any{"type": "integer"},
+						"dry_run":    map[string]any{"type": "boolean"},
+					},
+					"required": []any{"twitter_id", "to_address", "amount", "chain_id"},
+				},
+			}}}
+		case "tools/call":
+			f.mu.Lock()
+			f.calls = append(f.calls, req.Params)
+			f.mu.Unlock()
+			result = map[string]any{"content": []any{map[string]any{"type": "text", "text": "1250000000000000000"}}}
+			if r, ok := f.results[req.Params["name"].(string)]; ok {
+				result = r
+			}
+		default:
+			result = map[string]any{}
+		}
+		if req.Method == "tools/call" && f.notify {
+			w.Header().Set("Content-Type", "text/event-stream")
+			note, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "method": "notifications/tools/list_changed"})
+			res, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+			_, _ = fmt.Fprintf(w, "event: message\ndata: %s\n\nevent: message\ndata: %s\n\n", note, res)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(done) }) // runs first, ending open GET streams
+	return srv
+}
+
+// listen serves the GET stream a client opens for server notifications.
+func (f *fakeMCP) listen(w http.ResponseWriter, r *http.Request, done chan struct{}) {
+	if f.push == nil {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	f.mu.Lock()
+	f.streams++
+	f.mu.Unlock()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+	note, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "method": "notifications/tools/list_changed"})
+	for {
+		select {
+		case <-f.push:
+			_, _ = fmt.Fprintf(w, "event: message\ndata: %s\n\n", note)
+			w.(http.Flusher).Flush()
+		case <-r.Context().Done():
+			return
+		case <-done:
+			return
+		}
+	}
+}
+
+func TestFunctionMode(t *testing.T) {
+	t.Run("Tool calls are validated against the schema before they reach the server", func(t *testing.T) {
+		mcp := &fakeMCP{}
+		store := traces.NewMemoryStore()
+		a, err := agentcore.NewAgent(agentcore.Config{
+			WalletMCP: mcp.server(t).URL,
+			Mode:      agentcore.ModeFunctions,
+			Traces:    store,
+			LLM: agentcore.LLMConfig{Provider: agentcore.ProviderFake, Responses: []string{
+				`call:get_wallet_balance {"chain_id":56}`,
+				`call:get_wallet_balance {"twitter_id":"7","chain_id":56}`,
+				"Your balance is 1.25 BNB.",
+			}},
+		})
+		require.NoError(t, err)
+
+		res, err := a.Run(context.Background(), types.AgentRequest{Question: "balance?", TwitterID: "7", ReplyTo: "100"})
+		// No X server is configured, so posting fails after the answer is ready.
+		assert.ErrorContains(t, err, "X_MCP_HTTP is required")
+		assert.Equal(t, "Your balance is 1.25 BNB.", res.Answer)
+		assert.Equal(t, []string{"get_wallet_balance"}, res.ToolsUsed)
+
+		mcp.mu.Lock()
+		defer mcp.mu.Unlock()
+		// create_wallet from Run, then the single valid balance call.
+		require.Len(t, mcp.calls, 2)
+		assert.Equal(t, "get_wallet_balance", mcp.calls[1]["name"])
+		assert.Equal(t, map[string]any{"twitter_id": "7", "chain_id": "56"}, mcp.calls[1]["arguments"])
+
+		tr, _ := store.Get(context.Background(), "100")
+		require.NotNil(t, tr)
+		require.Len(t, tr.Steps, 2)
+		assert.Contains(t, tr.Steps[0].Output, `"error":"invalid_arguments"`)
+		assert.Contains(t, tr.Steps[0].Output, `{"field":"twitter_id","problem":"is required"}`)
+		assert.Equal(t, "1250000000000000000", tr.Steps[1].Output)
+	})
+
+	t.Run("Amounts, chain ids, flags and addresses are coerced to what the schema expects", func(t *testing.T) {
+		mcp := &fakeMCP{}
+		a, err := agentcore.NewAgent(agentcore.Config{
+			WalletMCP: mcp.server(t).URL,
+			Mode:      agentcore.ModeFunctions,
+			LLM: agentcore.LLMConfig{Provider: agentcore.ProviderFake, Responses: []string{
+				`call:transfer_asset {"twitter_id":"7","to_address":" 52908400098527886E0F7030069857D2E4169EE7 ","amount":1e18,"chain_id":"56","dry_run":"false"}`,
+				"Sent.",
+			}},
+		})
+		require.NoError(t, err)
+
+		_, err = a.Run(context.Background(), types.AgentRequest{Question: "send 1 bnb", TwitterID: "7"})
+		require.NoError(t, err)
+
+		mcp.mu.Lock()
+		defer mcp.mu.Unlock()
+		require.Len(t, mcp.calls, 2)
+		assert.Equal(t, map[string]any{
+			"twitter_id": "7",
+			"to_address": "0x52908400098527886E0F7030069857D2E4169EE7",
+			"amount":     "1000000000000000000",
+			"chain_id":   float64(56),
+			"dry_run":    false,
+		}, mcp.calls[1]["arguments"])
+	})
+
+	t.Run("Malformed input and wrong types come back as corrective observations", func(t *testing.T) {
+		mcp := &fakeMCP{}
+		store := traces.NewMemoryStore()
+		a, err := agentcore.NewAgent(agentcore.Config{
+			WalletMCP: mcp.server(t).URL,
+			Mode:      agentcore.ModeFunctions,
+			Traces:    store,
+			LLM: agentcore.LLMConfig{Provider: agentcore.ProviderFake, Responses: []string{
+				`call:transfer_asset {"twitter_id":"7","to_address":"bob","amount":"1","chain_id":"bsc"}`,
+				`call:transfer_asset not json`,
+				"Could not send.",
+			}},
+		})
+		require.NoError(t, err)
+
+		res, err := a.Run(context.Background(), types.AgentRequest{Question: "send", TwitterID: "7", ReplyTo: "200"})
+		assert.Error(t, err)
+		assert.Equal(t, "Could not send.", res.Answer)
+
+		mcp.mu.Lock()
+		assert.Len(t, mcp.calls, 1, "only create_wallet reached the server")
+		mcp.mu.Unlock()
+
+		tr, _ := store.Get(context.Background(), "200")
+		require.NotNil(t, tr)
+		require.Len(t, tr.Steps, 2)
+		assert.Contains(t, tr.Steps[0].Output, `{"field":"chain_id","problem":"must be an integer","got":"bsc"}`)
+		assert.NotContains(t, tr.Steps[0].Output, `"field":"to_address"`, "addresses are not judged by field name")
+		assert.Contains(t, tr.Steps[1].Output, `{"field":"(input)","problem":"must be a JSON object: `)
+	})
+	t.Run("Xpubs, bech32 and ENS names pass through, declared address formats are enforced", func(t *testing.T) {
+		mcp := &fakeMCP{tools: []any{map[string]any{
+			"name":        "get_bitcoin_balances_for_HD_address",
+			"description": "Balances of an HD wallet.",
+			"inputSchema": map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"wallet_address": map[string]any{"type": "string"}},
+				"required":   []any{"wallet_address"},
+			},
+		}, map[string]any{
+			"name":        "get_token_balance_for_address",
+			"description": "Token balances of an address.",
+			"inputSchema": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"walletAddress": map[string]any{"type": "string"},
+					"owner":         map[string]any{"type": "string", "format": "address"},
+				},
+			},
+		}}}
+		store := traces.NewMemoryStore()
+		xpub := "xpub6CUGRUonZSQ4TWtTMmzXdrXDtypWKiKrhko4egpiMZbpiaQL2jkwSB1icqYh2cfDfVxdx4df189oLKnC5fSwqPfgyP3hooxujYzAu3fDVmz"
+		a, err := agentcore.NewAgent(agentcore.Config{
+			WalletMCP: mcp.server(t).URL,
+			Mode:      agentcore.ModeFunctions,
+			Traces:    store,
+			LLM: agentcore.LLMConfig{Provider: agentcore.ProviderFake, Responses: []string{
+				`call:get_bitcoin_balances_for_HD_address {"wallet_address":"` + xpub + `"}`,
+				`call:get_token_balance_for_address {"walletAddress":"bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"}`,
+				`call:get_token_balance_for_address {"walletAddress":"vitalik.eth","owner":" 52908400098527886E0F7030069857D2E4169EE7"}`,
+				`call:get_token_balance_for_address {"owner":"vitalik.eth"}`,
+				"Done.",
+			}},
+		})
+		require.NoError(t, err)
+
+		_, _ = a.Run(context.Background(), types.AgentRequest{Question: "balances?", TwitterID: "7", ReplyTo: "300"})
+
+		mcp.mu.Lock()
+		defer mcp.mu.Unlock()
+		var args []any
+		for _, c := range mcp.calls {
+			if c["name"] != "create_wallet" {
+				args = append(args, c["arguments"])
+			}
+		}
+		assert.Equal(t, []any{
+			map[string]any{"wallet_address": xpub},
+			map[string]any{"walletAddress": "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"},
+			map[string]any{"walletAddress": "vitalik.eth", "owner": "0x52908400098527886E0F7030069857D2E4169EE7"},
+		}, args)
+
+		tr, _ := store.Get(context.Background(), "300")
+		require.NotNil(t, tr)
+		require.Len(t, tr.Steps, 4)
+		assert.Contains(t, tr.Steps[3].Output, `{"field":"owner","problem":"must be a 0x-prefixed 40 hex character address","got":"vitalik.eth"}`)
+	})
+}
@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"cg-mentions-bot/internal/agentcore"
+	"cg-mentions-bot/internal/traces"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// goldrushMCP serves get_token_balance_for_address with the given Covalent body.
+func goldrushMCP(body map[string]any) *fakeMCP {
+	raw, _ := json.Marshal(body)
+	return &fakeMCP{
+		tools: []any{map[string]any{
+			"name":        "get_token_balance_for_address",
+			"description": "Get ERC20 token balances for a wallet address on a specific chain",
+			"inputSchema": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"chain_name":     map[string]any{"type": "string"},
+					"wallet_address": map[string]any{"type": "string"},
+				},
+				"required": []any{"chain_name", "wallet_address"},
+			},
+		}},
+		results: map[string]map[string]any{
+			"get_token_balance_for_address": {"content": []any{text(string(raw))}},
+		},
+	}
+}
+
+func balances(n int) map[string]any {
+	items := make([]any, 0, n+1)
+	for i := 0; i < n; i++ {
+		items = append(items, map[string]any{
+			"contract_ticker_symbol": fmt.Sprintf("TK%d", i),
+			"contract_address":       fmt.Sprintf("0x%040d", i),
+			"contract_decimals":      18,
+			"balance":                "1000000000000000000",
+			"quote":                  1.5,
+			"logo_url":               "https://logos.covalenthq.com/" + strings.Repeat("x", 800),
+			"is_spam":                false,
+		})
+	}
+	items = append(items, map[string]any{"contract_ticker_symbol": "SCAM", "balance": "1", "is_spam": true})
+	return map[string]any{
+		"data":  map[string]any{"address": "0xabc", "chain_name": "bsc-mainnet", "updated_at": "2026-10-17T00:00:00Z", "items": items},
+		"error": false,
+	}
+}
+
+// runGoldRush replays calls through a function-mode agent and returns the trace steps.
+func runGoldRush(t *testing.T, mcp *fakeMCP, calls ...string) []traces.Step {
+	store := traces.NewMemoryStore()
+	a, err := agentcore.NewAgent(agentcore.Config{
+		GoldRushMCP: mcp.server(t).URL,
+		Mode:        agentcore.ModeFunctions,
+		Traces:      store,
+		LLM:         agentcore.LLMConfig{Provider: agentcore.ProviderFake, Responses: append(calls, "Done.")},
+	})
+	require.NoError(t, err)
+	_, _ = a.Run(context.Background(), types.AgentRequest{Question: "my tokens?", TwitterID: "7", ReplyTo: "500"})
+	tr, _ := store.Get(context.Background(), "500")
+	require.NotNil(t, tr)
+	return tr.Steps
+}
+
+func TestGoldRushShaping(t *testing.T) {
+	args := `"chain_name":"bsc-mainnet","wallet_address":"0x52908400098527886E0F7030069857D2E4169EE7"`
+
+	t.Run("items are projected, spam dropped and the list paged with a more marker", func(t *testing.T) {
+		mcp := goldrushMCP(balances(25))
+
+		steps := runGoldRush(t, mcp,
+			`call:get_token_balance_for_address {`+args+`}`,
+			`call:get_token_balance_for_address {`+args+`,"offset":20}`,
+		)
+
+		require.Len(t, steps, 2)
+		var first, second struct {
+			Address    string           `json:"address"`
+			Offset     int              `json:"offset"`
+			TotalItems int              `json:"total_items"`
+			Items      []map[string]any `json:"items"`
+			More       *struct {
+				Remaining  int `json:"remaining"`
+				NextOffset int `json:"next_offset"`
+			} `json:"more"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(steps[0].Output), &first))
+		assert.Equal(t, "0xabc", first.Address)
+		assert.Equal(t, 25, first.TotalItems)
+		require.Len(t, first.Items, 10)
+		assert.Equal(t, "TK0", first.Items[0]["contract_ticker_symbol"])
+		assert.NotContains(t, first.Items[0], "logo_url")
+		require.NotNil(t, first.More)
+		assert.Equal(t, 10, first.More.NextOffset)
+		assert.Equal(t, 15, first.More.Remaining)
+		assert.Contains(t, steps[0].Output, `\"offset\":10`)
+
+		require.NoError(t, json.Unmarshal([]byte(steps[1].Output), &second))
+		assert.Equal(t, 20, second.Offset)
+		require.Len(t, second.Items, 5)
+		assert.Equal(t, "TK20", second.Items[0]["contract_ticker_symbol"])
+		assert.Nil(t, second.More)
+		assert.NotContains(t, steps[1].Output, "SCAM")
+
+		mcp.mu.Lock()
+		defer mcp.mu.Unlock()
+		require.Len(t, mcp.calls, 2)
+		// Paging arguments never reach the server.
+		assert.Equal(t, map[string]any{"chain_name": "bsc-mainnet", "wallet_address": "0x52908400098527886E0F7030069857D2E4169EE7"}, mcp.calls[1]["arguments"])
+	})
+
+	t.Run("extra fields can be requested and pages shrink to the token cap", func(t *testing.T) {
+		steps := runGoldRush(t, goldrushMCP(balances(25)),
+			`call:get_token_balance_for_address {`+args+`,"fields":["logo_url"]}`,
+		)
+
+		require.Len(t, steps, 1)
+		out := steps[0].Output
+		assert.LessOrEqual(t, len(out), 6000)
+		assert.Contains(t, out, "logo_url")
+		var page struct {
+			Items []map[string]any `json:"items"`
+			More  map[string]any   `json:"more"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(out), &page))
+		assert.Less(t, len(page.Items), 10)
+		assert.EqualValues(t, len(page.Items), page.More["next_offset"])
+	})
+
+	t.Run("Covalent errors reach the model as tool errors", func(t *testing.T) {
+		steps := runGoldRush(t, goldrushMCP(map[string]any{"data": nil, "error": true, "error_message": "Invalid chain name"}),
+			`call:get_token_balance_for_address {`+args+`}`,
+		)
+
+		require.Len(t, steps, 1)
+		assert.Contains(t, steps[0].Output, `"error":"tool_error"`)
+		assert.Contains(t, steps[0].Output, "Invalid chain name")
+		assert.NotEmpty(t, steps[0].Error)
+	})
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"cg-mentions-bot/internal/agentcore"
+	"cg-mentions-bot/internal/shadow"
+	"cg-mentions-bot/internal/traces"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviders(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("The fake provider answers offline and the run is traced", func(t *testing.T) {
+		store := traces.NewMemoryStore()
+		calls := shadow.NewMemoryStore(0)
+		a, err := agentcore.NewAgent(agentcore.Config{
+			LLM:    agentcore.LLMConfig{Provider: agentcore.ProviderFake, Responses: []string{"Final Answer: gm"}},
+			Shadow: &shadow.Recorder{Store: calls},
+			Traces: store,
+		})
+		require.NoError(t, err)
+
+		res, err := a.Run(ctx, types.AgentRequest{Question: "gm?", TwitterID: "7", ReplyTo: "100"})
+		require.NoError(t, err)
+		assert.Equal(t, "gm", res.Answer)
+		assert.True(t, res.Posted)
+
+		tr, err := store.Get(ctx, "100")
+		require.NoError(t, err)
+		require.NotNil(t, tr)
+		assert.Equal(t, "fake/fake", tr.Model)
+		assert.Equal(t, 1, tr.Usage.LLMCalls)
+
+		posted, _ := calls.List(ctx, shadow.Filter{TweetID: "100"})
+		require.Len(t, posted, 1)
+		assert.Equal(t, "gm", posted[0].Reply)
+	})
+
+	t.Run("A failing provider falls back to the secondary", func(t *testing.T) {
+		a, err := agentcore.NewAgent(agentcore.Config{
+			LLM:      agentcore.LLMConfig{Provider: agentcore.ProviderOllama, BaseURL: "http://127.0.0.1:1"},
+			Fallback: &agentcore.LLMConfig{Provider: agentcore.ProviderFake},
+		})
+		require.NoError(t, err)
+
+		res, err := a.Run(ctx, types.AgentRequest{Question: "gm?", TwitterID: "7"})
+		require.NoError(t, err)
+		assert.Equal(t, "This is an offline answer from the fake LLM.", res.Answer)
+	})
+
+	t.Run("Unknown providers are rejected", func(t *testing.T) {
+		_, err := agentcore.NewAgent(agentcore.Config{LLM: agentcore.LLMConfig{Provider: "nope"}})
+		assert.ErrorContains(t, err, `unknown LLM provider "nope"`)
+	})
+}
@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"cg-mentions-bot/internal/agentcore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMCPClient(t *testing.T) {
+	ctx := context.Background()
+	args := map[string]any{"twitter_id": "42"}
+
+	t.Run("one session is shared by every client of an endpoint", func(t *testing.T) {
+		mcp := &fakeMCP{}
+		srv := mcp.server(t)
+
+		for i := 0; i < 3; i++ {
+			out, err := agentcore.NewToolClient(srv.URL).Call(ctx, "get_wallet_balance", args)
+			require.NoError(t, err)
+			assert.Equal(t, "1250000000000000000", out)
+		}
+
+		assert.Equal(t, 1, mcp.inits)
+		assert.Equal(t, []string{"session-1", "session-1", "session-1"}, mcp.sessions)
+	})
+
+	t.Run("a terminated session is re-initialized and the call retried", func(t *testing.T) {
+		mcp := &fakeMCP{}
+		srv := mcp.server(t)
+		c := agentcore.NewToolClient(srv.URL)
+		_, err := c.Call(ctx, "get_wallet_balance", args)
+		require.NoError(t, err)
+
+		mcp.mu.Lock()
+		mcp.expired = true
+		mcp.mu.Unlock()
+		out, err := c.Call(ctx, "get_wallet_balance", args)
+
+		require.NoError(t, err)
+		assert.Equal(t, "1250000000000000000", out)
+		assert.Equal(t, 2, mcp.inits)
+		assert.Equal(t, []string{"session-1", "session-2"}, mcp.sessions)
+	})
+
+	t.Run("JSON-RPC error objects are returned as errors", func(t *testing.T) {
+		mcp := &fakeMCP{}
+		srv := mcp.server(t)
+
+		_, err := agentcore.NewToolClient(srv.URL).Call(ctx, "broken", nil)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "broken")
+		assert.Contains(t, err.Error(), "backend unavailable")
+	})
+
+	t.Run("a failed handshake fails the call and is retried next time", func(t *testing.T) {
+		var down atomic.Bool
+		down.Store(true)
+		mcp := &fakeMCP{}
+		up := mcp.server(t)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if down.Load() {
+				http.Error(w, "starting", http.StatusServiceUnavailable)
+				return
+			}
+			proxy, _ := http.NewRequestWithContext(r.Context(), r.Method, up.URL, r.Body)
+			proxy.Header = r.Header
+			resp, err := http.DefaultClient.Do(proxy)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			defer resp.Body.Close()
+			for k, v := range resp.Header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(resp.StatusCode)
+			_, _ = io.Copy(w, resp.Body)
+		}))
+		t.Cleanup(srv.Close)
+		c := agentcore.NewToolClient(srv.URL)
+
+		_, err := c.Call(ctx, "get_wallet_balance", args)
+		require.Error(t, err)
+		assert.Equal(t, 0, mcp.inits)
+
+		down.Store(false)
+		out, err := c.Call(ctx, "get_wallet_balance", args)
+		require.NoError(t, err)
+		assert.Equal(t, "1250000000000000000", out)
+		assert.Equal(t, 1, mcp.inits)
+	})
+	t.Run("a server client calls the registry entry of its name and follows reloads", func(t *testing.T) {
+		fromEnv, fromRegistry, moved := &fakeMCP{}, &fakeMCP{}, &fakeMCP{}
+		file := filepath.Join(t.TempDir(), "servers.yaml")
+		writeFile(t, file, "servers:\n  - name: wallet\n    url: "+fromRegistry.server(t).URL+"\n")
+		r, err := agentcore.LoadRegistry(file)
+		require.NoError(t, err)
+		cfg := agentcore.Config{WalletMCP: fromEnv.server(t).URL, Registry: r}
+
+		c, ok := agentcore.NewServerClient(cfg, agentcore.ServerWallet)
+		require.True(t, ok)
+		_, err = c.Call(ctx, "read_wallet", args)
+		require.NoError(t, err)
+		assert.Empty(t, callNames(fromEnv))
+		assert.Equal(t, []string{"read_wallet"}, callNames(fromRegistry))
+
+		writeFile(t, file, "servers:\n  - name: wallet\n    url: "+moved.server(t).URL+"\n")
+		_, err = r.Reload()
+		require.NoError(t, err)
+		_, err = c.Call(ctx, "read_wallet", args)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"read_wallet"}, callNames(moved))
+
+		_, ok = agentcore.NewServerClient(agentcore.Config{}, agentcore.ServerWallet)
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cg-mentions-bot/internal/agentcore"
+	"cg-mentions-bot/internal/shadow"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy(t *testing.T) {
+	t.Run("The default policy keeps signing and transfer tools off public mentions", func(t *testing.T) {
+		p := agentcore.DefaultPolicy()
+		assert.False(t, p.Allows(agentcore.ContextMention, "wallet", "transfer_asset"))
+		assert.True(t, p.Allows(agentcore.ContextShadow, "wallet", "transfer_asset"))
+		assert.True(t, p.Allows(agentcore.ContextAPI, "wallet", "transfer_asset"))
+		assert.False(t, p.Allows(agentcore.ContextMention, "wallet", "sign_transaction"))
+		assert.False(t, p.Allows(agentcore.ContextMention, "solana", "solana_agent"), "mentions only get read-only tools")
+		assert.False(t, p.Allows(agentcore.ContextMention, "bnb", "transfer_native_token"))
+		assert.True(t, p.Allows(agentcore.ContextMention, "goldrush", "get_multichain_balances"))
+		assert.True(t, p.Allows(agentcore.ContextMention, "x", "twitter.post_reply"))
+		assert.True(t, p.Allows(agentcore.ContextShadow, "wallet", "sign_transaction"))
+		assert.True(t, p.Allows(agentcore.ContextShadow, "goldrush", "get_transaction"))
+		assert.False(t, p.Allows(agentcore.ContextShadow, "solana", "solana_agent"), "shadow runs only get read-only and simulated tools")
+		assert.False(t, p.Allows(agentcore.ContextShadow, "bnb", "transfer_native_token"))
+		assert.False(t, p.Allows(agentcore.ContextShadow, "solana", "export_private_key"))
+		assert.True(t, p.Allows(agentcore.ContextAPI, "wallet", "sign_transaction"))
+		assert.False(t, p.Allows("unknown", "x", "twitter.post_reply"), "contexts without rules get no tools")
+
+		var none agentcore.Policy
+		assert.True(t, none.Allows(agentcore.ContextMention, "wallet", "sign_transaction"))
+	})
+
+	t.Run("Policies load from JSON and bad patterns are rejected", func(t *testing.T) {
+		dir := t.TempDir()
+		good := filepath.Join(dir, "policy.json")
+		require.NoError(t, os.WriteFile(good, []byte(`{"mention":{"allow":["x:*","wallet:get_*"]}}`), 0o600))
+		p, err := agentcore.LoadPolicy(good)
+		require.NoError(t, err)
+		assert.True(t, p.Allows(agentcore.ContextMention, "wallet", "get_wallet_balance"))
+		assert.False(t, p.Allows(agentcore.ContextMention, "wallet", "transfer_asset"))
+
+		bad := filepath.Join(dir, "bad.json")
+		require.NoError(t, os.WriteFile(bad, []byte(`{"mention":{"allow":["wallet:["]}}`), 0o600))
+		_, err = agentcore.LoadPolicy(bad)
+		assert.ErrorContains(t, err, "bad pattern")
+	})
+
+	t.Run("Denied tools are hidden from mentions but usable from the API", func(t *testing.T) {
+		for _, tc := range []struct {
+			source string
+			calls  int
+		}{{"", 1}, {agentcore.ContextAPI, 2}} {
+			mcp := &fakeMCP{}
+			a, err := agentcore.NewAgent(agentcore.Config{
+				WalletMCP: mcp.server(t).URL,
+				Mode:      agentcore.ModeFunctions,
+				Policy:    agentcore.DefaultPolicy(),
+				LLM: agentcore.LLMConfig{Provider: agentcore.ProviderFake, Responses: []string{
+					`call:sign_transaction {"tx":"0x01"}`,
+					"Done.",
+				}},
+			})
+			require.NoError(t, err)
+
+			_, err = a.Run(context.Background(), types.AgentRequest{Question: "sign", TwitterID: "7", Source: tc.source})
+			require.NoError(t, err)
+			mcp.mu.Lock()
+			assert.Len(t, mcp.calls, tc.calls, "source %q", tc.source)
+			mcp.mu.Unlock()
+		}
+	})
+	t.Run("A free-form transfer from a mention never reaches the wallet", func(t *testing.T) {
+		mcp := &fakeMCP{}
+		a, err := agentcore.NewAgent(agentcore.Config{
+			WalletMCP: mcp.server(t).URL,
+			Mode:      agentcore.ModeFunctions,
+			Policy:    agentcore.DefaultPolicy(),
+			LLM: agentcore.LLMConfig{Provider: agentcore.ProviderFake, Responses: []string{
+				`call:transfer_asset {"twitter_id":"7","to_address":"0x52908400098527886E0F7030069857D2E4169EE7","amount":"5000000000000000000","chain_id":56}`,
+				"Done.",
+			}},
+		})
+		require.NoError(t, err)
+
+		res, err := a.Run(context.Background(), types.AgentRequest{Question: "please send 5 BNB to @bob", TwitterID: "7"})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"create_wallet"}, callNames(mcp))
+		assert.NotContains(t, res.ToolsUsed, "transfer_asset")
+		assert.Empty(t, res.TxHashes)
+	})
+	t.Run("A transfer that fails still counts as a side effect, a lookup does not", func(t *testing.T) {
+		for _, tc := range []struct {
+			call string
+			acts bool
+		}{
+			{`call:transfer_asset {"twitter_id":"7","to_address":"0x52908400098527886E0F7030069857D2E4169EE7","amount":"1","chain_id":56}`, true},
+			{`call:get_wallet_balance {"twitter_id":"7"}`, false},
+		} {
+			mcp := &fakeMCP{results: map[string]map[string]any{"transfer_asset": {
+				"isError": true,
+				"content": []any{map[string]any{"type": "text", "text": "timed out waiting for receipt"}},
+			}}}
+			a, err := agentcore.NewAgent(agentcore.Config{
+				WalletMCP: mcp.server(t).URL,
+				Mode:      agentcore.ModeFunctions,
+				Policy:    agentcore.DefaultPolicy(),
+				LLM:       agentcore.LLMConfig{Provider: agentcore.ProviderFake, Responses: []string{tc.call, "Done."}},
+			})
+			require.NoError(t, err)
+
+			res, err := a.Run(context.Background(), types.AgentRequest{Question: "go", TwitterID: "7", Source: agentcore.ContextAPI})
+			require.NoError(t, err)
+			assert.Equal(t, tc.acts, res.SideEffects, tc.call)
+		}
+	})
+
+	t.Run("Shadow runs may sign because the signature is simulated", func(t *testing.T) {
+		mcp := &fakeMCP{}
+		calls := shadow.NewMemoryStore(0)
+		a, err := agentcore.NewAgent(agentcore.Config{
+			WalletMCP: mcp.server(t).URL,
+			Mode:      agentcore.ModeFunctions,
+			Policy:    agentcore.DefaultPolicy(),
+			Shadow:    &shadow.Recorder{Store: calls},
+			LLM: agentcore.LLMConfig{Provider: agentcore.ProviderFake, Responses: []string{
+				`call:sign_transaction {"tx":"0x01"}`,
+				"Done.",
+			}},
+		})
+		require.NoError(t, err)
+
+		_, err = a.Run(context.Background(), types.AgentRequest{Question: "sign", TwitterID: "7", ReplyTo: "100"})
+		require.NoError(t, err)
+
+		assert.NotContains(t, callNames(mcp), "sign_transaction")
+		signed, _ := calls.List(context.Background(), shadow.Filter{Tool: "sign_transaction"})
+		assert.Len(t, signed, 1)
+	})
+
+	t.Run("A transfer tool shadow mode does not simulate is refused in shadow runs", func(t *testing.T) {
+		bnb := &fakeMCP{tools: []any{map[string]any{
+			"name":        "transfer_native_token",
+			"description": "Send BNB from the server's key.",
+			"inputSchema": map[string]any{"type": "object"},
+		}}}
+		file := filepath.Join(t.TempDir(), "servers.json")
+		writeFile(t, file, `{"servers":[{"name":"bnb","url":"`+bnb.server(t).URL+`"}]}`)
+		r, err := agentcore.LoadRegistry(file)
+		require.NoError(t, err)
+		a, err := agentcore.NewAgent(agentcore.Config{
+			Registry: r,
+			Mode:     agentcore.ModeFunctions,
+			Policy:   agentcore.DefaultPolicy(),
+			Shadow:   &shadow.Recorder{Store: shadow.NewMemoryStore(0)},
+			LLM: agentcore.LLMConfig{Provider: agentcore.ProviderFake, Responses: []string{
+				`call:transfer_native_token {"to":"0x52908400098527886E0F7030069857D2E4169EE7","amount":"1"}`,
+				"Done.",
+			}},
+		})
+		require.NoError(t, err)
+
+		_, err = a.Run(context.Background(), types.AgentRequest{Question: "send 1 bnb", TwitterID: "7", ReplyTo: "100"})
+		require.NoError(t, err)
+
+		assert.Empty(t, callNames(bnb))
+	})
+
+	t.Run("Rules for X match the server's own twitter.post_reply", func(t *testing.T) {
+		x := &fakeMCP{}
+		a, err := agentcore.NewAgent(agentcore.Config{
+			XMCP:   x.server(t).URL,
+			Mode:   agentcore.ModeFunctions,
+			Policy: agentcore.Policy{agentcore.ContextMention: {Allow: []string{"x:twitter.post_reply"}}},
+			LLM: agentcore.LLMConfig{Provider: agentcore.ProviderFake, Responses: []string{
+				`call:x_post_reply {"in_reply_to_tweet_id":"100","text":"gm"}`,
+				"gm",
+			}},
+		})
+		require.NoError(t, err)
+
+		res, err := a.Run(context.Background(), types.AgentRequest{Question: "gm", TwitterID: "7", ReplyTo: "100"})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"x_post_reply"}, res.ToolsUsed)
+		assert.Equal(t, []string{"twitter.post_reply"}, callNames(x))
+	})
+}
@@ -0,0 +1,177 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cg-mentions-bot/internal/agentcore"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, file, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+}
+
+// askWith runs one function-mode request that makes the given tool calls.
+func askWith(t *testing.T, cfg agentcore.Config, calls ...string) {
+	t.Helper()
+	cfg.Mode = agentcore.ModeFunctions
+	cfg.LLM = agentcore.LLMConfig{Provider: agentcore.ProviderFake, Responses: append(calls, "Done.")}
+	a, err := agentcore.NewAgent(cfg)
+	require.NoError(t, err)
+	_, err = a.Run(context.Background(), types.AgentRequest{Question: "q", TwitterID: "7"})
+	require.NoError(t, err)
+}
+
+func callNames(f *fakeMCP) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for _, c := range f.calls {
+		out = append(out, c["name"].(string))
+	}
+	return out
+}
+
+func TestRegistry(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("YAML and JSON files load and disabled servers are skipped", func(t *testing.T) {
+		yml := filepath.Join(dir, "servers.yaml")
+		writeFile(t, yml, `
+servers:
+  - name: goldrush
+    url: http://localhost:8083/mcp
+    headers: {Authorization: "Bearer ${GOLDRUSH_TOKEN}"}
+    tool_prefix: gr_
+  - name: coingecko
+    transport: stdio
+    command: npx
+    args: ["-y", "@coingecko/coingecko-mcp"]
+    enabled: false
+`)
+		r, err := agentcore.LoadRegistry(yml)
+		require.NoError(t, err)
+		servers, version := r.Servers()
+		assert.Equal(t, 1, version)
+		require.Len(t, servers, 1)
+		assert.Equal(t, "goldrush", servers[0].Name)
+		assert.Equal(t, "gr_", servers[0].ToolPrefix)
+		assert.Equal(t, "Bearer ${GOLDRUSH_TOKEN}", servers[0].Headers["Authorization"])
+
+		js := filepath.Join(dir, "servers.json")
+		writeFile(t, js, `{"servers":[{"name":"bsc","transport":"sse","url":"http://localhost:8084/sse"}]}`)
+		r, err = agentcore.LoadRegistry(js)
+		require.NoError(t, err)
+		servers, _ = r.Servers()
+		require.Len(t, servers, 1)
+		assert.Equal(t, agentcore.TransportSSE, servers[0].Transport)
+	})
+
+	t.Run("invalid entries are rejected", func(t *testing.T) {
+		for content, problem := range map[string]string{
+			`{"servers":[{"name":"a"}]}`:                                  "url is required",
+			`{"servers":[{"name":"a","transport":"stdio"}]}`:              "command is required",
+			`{"servers":[{"name":"a","transport":"grpc","url":"x"}]}`:     "unknown transport",
+			`{"servers":[{"name":"a b","url":"x"}]}`:                      "must be letters",
+			`{"servers":[{"name":"a","url":"x"},{"name":"a","url":"y"}]}`: "listed twice",
+			`{"servers":[{"name":"a","url":"x","tool_prefix":"a.b"}]}`:    "tool_prefix",
+		} {
+			file := filepath.Join(dir, "bad.json")
+			writeFile(t, file, content)
+			_, err := agentcore.LoadRegistry(file)
+			assert.ErrorContains(t, err, problem, content)
+		}
+	})
+
+	t.Run("prefixed tools are called by the server's own names", func(t *testing.T) {
+		wallet, bsc := &fakeMCP{}, &fakeMCP{}
+		file := filepath.Join(dir, "prefix.json")
+		writeFile(t, file, `{"servers":[{"name":"bsc","url":"`+bsc.server(t).URL+`","tool_prefix":"bsc_"}]}`)
+		r, err := agentcore.LoadRegistry(file)
+		require.NoError(t, err)
+
+		askWith(t, agentcore.Config{WalletMCP: wallet.server(t).URL, Registry: r},
+			`call:bsc_get_wallet_balance {"twitter_id":"7"}`,
+			`call:get_wallet_balance {"twitter_id":"7"}`,
+		)
+
+		assert.Equal(t, []string{"get_wallet_balance"}, callNames(bsc))
+		assert.Equal(t, []string{"create_wallet", "get_wallet_balance"}, callNames(wallet))
+	})
+
+	t.Run("colliding tool names are namespaced by server", func(t *testing.T) {
+		wallet, extra := &fakeMCP{}, &fakeMCP{}
+		file := filepath.Join(dir, "collide.json")
+		writeFile(t, file, `{"servers":[{"name":"extra","url":"`+extra.server(t).URL+`"}]}`)
+		r, err := agentcore.LoadRegistry(file)
+		require.NoError(t, err)
+
+		askWith(t, agentcore.Config{WalletMCP: wallet.server(t).URL, Registry: r},
+			`call:extra_get_wallet_balance {"twitter_id":"7"}`,
+		)
+
+		assert.Equal(t, []string{"get_wallet_balance"}, callNames(extra))
+		assert.Equal(t, []string{"create_wallet"}, callNames(wallet))
+	})
+
+	t.Run("policies match the server's tool name whatever the prefix", func(t *testing.T) {
+		bsc := &fakeMCP{}
+		file := filepath.Join(dir, "policy.json")
+		writeFile(t, file, `{"servers":[{"name":"bsc","url":"`+bsc.server(t).URL+`","tool_prefix":"bsc_"}]}`)
+		r, err := agentcore.LoadRegistry(file)
+		require.NoError(t, err)
+
+		askWith(t, agentcore.Config{Registry: r, Policy: agentcore.DefaultPolicy()},
+			`call:bsc_sign_transaction {"tx":"0x01"}`,
+		)
+
+		assert.Empty(t, callNames(bsc))
+	})
+
+	t.Run("edits to the file are picked up by a running agent", func(t *testing.T) {
+		first, second := &fakeMCP{}, &fakeMCP{}
+		file := filepath.Join(dir, "reload.yaml")
+		writeFile(t, file, "servers:\n  - name: wallet\n    url: "+first.server(t).URL+"\n")
+		r, err := agentcore.LoadRegistry(file)
+		require.NoError(t, err)
+		a, err := agentcore.NewAgent(agentcore.Config{
+			Registry: r,
+			Mode:     agentcore.ModeFunctions,
+			LLM: agentcore.LLMConfig{Provider: agentcore.ProviderFake, Responses: []string{
+				`call:get_wallet_balance {"twitter_id":"7"}`,
+				"Done.",
+			}},
+		})
+		require.NoError(t, err)
+		run := func() {
+			_, err := a.Run(context.Background(), types.AgentRequest{Question: "q", TwitterID: "7"})
+			require.NoError(t, err)
+		}
+
+		run()
+		secondURL := second.server(t).URL
+		writeFile(t, file, "servers:\n  - name: wallet\n    url: "+secondURL+"\n")
+		changed, err := r.Reload()
+		require.NoError(t, err)
+		assert.True(t, changed)
+		run()
+
+		assert.Equal(t, []string{"create_wallet", "get_wallet_balance"}, callNames(first))
+		assert.Equal(t, []string{"create_wallet", "get_wallet_balance"}, callNames(second))
+
+		writeFile(t, file, "servers: [")
+		_, err = r.Reload()
+		assert.Error(t, err)
+		servers, version := r.Servers()
+		assert.Equal(t, 2, version)
+		require.Len(t, servers, 1)
+		assert.Equal(t, secondURL, servers[0].URL)
+	})
+}
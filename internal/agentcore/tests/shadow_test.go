@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"cg-mentions-bot/internal/agentcore"
+	"cg-mentions-bot/internal/shadow"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShadowRun(t *testing.T) {
+	run := func(t *testing.T, mcp *fakeMCP) *shadow.MemoryStore {
+		calls := shadow.NewMemoryStore(0)
+		a, err := agentcore.NewAgent(agentcore.Config{
+			WalletMCP: mcp.server(t).URL,
+			Mode:      agentcore.ModeFunctions,
+			Shadow:    &shadow.Recorder{Store: calls},
+			LLM:       agentcore.LLMConfig{Provider: agentcore.ProviderFake, Responses: []string{"gm"}},
+		})
+		require.NoError(t, err)
+		_, err = a.Run(context.Background(), types.AgentRequest{Question: "gm", TwitterID: "7", ReplyTo: "100"})
+		require.NoError(t, err)
+		return calls
+	}
+
+	t.Run("The author's existing wallet is read instead of created", func(t *testing.T) {
+		mcp := &fakeMCP{}
+		calls := run(t, mcp)
+
+		assert.Equal(t, []string{"read_wallet"}, callNames(mcp))
+		created, _ := calls.List(context.Background(), shadow.Filter{Tool: "create_wallet"})
+		assert.Empty(t, created)
+	})
+
+	t.Run("A missing wallet is recorded as a would-be creation", func(t *testing.T) {
+		mcp := &fakeMCP{results: map[string]map[string]any{"read_wallet": {
+			"isError": true,
+			"content": []any{map[string]any{"type": "text", "text": "failed to read wallet: user not found"}},
+		}}}
+		calls := run(t, mcp)
+
+		assert.Equal(t, []string{"read_wallet"}, callNames(mcp))
+		created, _ := calls.List(context.Background(), shadow.Filter{Tool: "create_wallet"})
+		require.Len(t, created, 1)
+		assert.Equal(t, "100", created[0].TweetID)
+	})
+}
@@ -0,0 +1,34 @@
+package agentcore
+
+import (
+	"context"
+
+	"cg-mentions-bot/internal/traces"
+
+	"github.com/tmc/langchaingo/callbacks"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// tracer forwards agent callbacks to the trace attached to the context. Token
+// usage is reported by tracedModel, which sees every provider.
+type tracer struct {
+	callbacks.SimpleHandler
+}
+
+func (tracer) HandleAgentAction(ctx context.Context, action schema.AgentAction) {
+	if run := traces.FromContext(ctx); run != nil {
+		run.Thought(action.Tool, action.Log)
+	}
+}
+
+func intInfo(info map[string]any, key string) int {
+	switch v := info[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
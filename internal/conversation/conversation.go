@@ -0,0 +1,242 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"cg-mentions-bot/internal/types"
+)
+
+// Service builds bounded thread history for a mention and records new turns.
+type Service struct {
+	Store Store
+	// Fetcher, if set, seeds the history of conversations the store has not seen yet.
+	Fetcher *XFetcher
+
+	MaxTurns int // default 8
+	MaxChars int // total text budget for the history; default 2000
+}
+
+// History returns earlier turns of the mention's conversation, oldest first,
+// trimmed to MaxTurns and MaxChars (dropping the oldest turns first; a newest
+// turn longer than MaxChars is cut instead).
+func (s *Service) History(ctx context.Context, m types.Mention) []types.Turn {
+	convID := conversationID(m)
+	turns, err := s.Store.Recent(ctx, convID, s.maxTurns()+1)
+	if err != nil {
+		log.Printf("conversation %s: load history failed: %v", convID, err)
+	}
+	if len(turns) == 0 && s.Fetcher != nil && convID != m.TweetID {
+		fetched, err := s.Fetcher.Thread(ctx, convID)
+		if err != nil {
+			log.Printf("conversation %s: fetch thread failed: %v", convID, err)
+		} else if len(fetched) > 0 {
+			// The mention itself is stored by Record, after the answer.
+			seed := make([]types.Turn, 0, len(fetched))
+			for _, t := range fetched {
+				if t.TweetID != m.TweetID {
+					seed = append(seed, t)
+				}
+			}
+			if err := s.Store.Append(ctx, convID, seed...); err != nil {
+				log.Printf("conversation %s: store fetched thread failed: %v", convID, err)
+			}
+			turns = fetched
+		}
+	}
+
+	prior := make([]types.Turn, 0, len(turns))
+	for _, t := range turns {
+		if t.TweetID != "" && t.TweetID == m.TweetID {
+			continue
+		}
+		prior = append(prior, t)
+	}
+	return s.bound(prior)
+}
+
+// Record stores the mention and the bot's answer to it as the latest turns of the conversation.
+func (s *Service) Record(ctx context.Context, m types.Mention, answer string) {
+	turns := []types.Turn{{
+		TweetID:        m.TweetID,
+		AuthorID:       m.AuthorID,
+		AuthorUsername: m.AuthorUsername,
+		Text:           m.Text,
+		CreatedAt:      m.CreatedAt,
+	}}
+	if answer = strings.TrimSpace(answer); answer != "" {
+		turns = append(turns, types.Turn{Text: answer, FromBot: true, CreatedAt: time.Now().UTC().Format(time.RFC3339)})
+	}
+	if err := s.Store.Append(ctx, conversationID(m), turns...); err != nil {
+		log.Printf("conversation %s: record failed: %v", conversationID(m), err)
+	}
+}
+
+func (s *Service) bound(turns []types.Turn) []types.Turn {
+	if len(turns) > s.maxTurns() {
+		turns = turns[len(turns)-s.maxTurns():]
+	}
+	budget := s.MaxChars
+	if budget <= 0 {
+		budget = 2000
+	}
+	start := len(turns)
+	for start > 0 && len(turns[start-1].Text) <= budget {
+		budget -= len(turns[start-1].Text)
+		start--
+	}
+	if start == len(turns) && start > 0 {
+		last := turns[start-1]
+		last.Text = cut(last.Text, budget)
+		return []types.Turn{last}
+	}
+	return turns[start:]
+}
+
+// cut shortens text to at most limit bytes, ending with an ellipsis.
+func cut(text string, limit int) string {
+	const ellipsis = "…"
+	n := limit - len(ellipsis)
+	if n <= 0 {
+		return ""
+	}
+	// Avoid splitting a multi-byte character.
+	for n > 0 && text[n]&0xC0 == 0x80 {
+		n--
+	}
+	return strings.TrimSpace(text[:n]) + ellipsis
+}
+
+func (s *Service) maxTurns() int {
+	if s.MaxTurns <= 0 {
+		return 8
+	}
+	return s.MaxTurns
+}
+
+// conversationID falls back to the tweet itself, which is the root of a new thread.
+func conversationID(m types.Mention) string {
+	if m.ConversationID != "" {
+		return m.ConversationID
+	}
+	return m.TweetID
+}
+
+// XFetcher loads a thread from the X API v2 recent search.
+type XFetcher struct {
+	BaseURL   string // e.g. https://api.twitter.com/2
+	Bearer    string
+	BotUserID string
+	HTTP      *http.Client
+}
+
+// Thread returns the tweets of a conversation from the last seven days, oldest first.
+func (f *XFetcher) Thread(ctx context.Context, conversationID string) ([]types.Turn, error) {
+	q := url.Values{}
+	q.Set("query", "conversation_id:"+conversationID)
+	q.Set("max_results", "50")
+	q.Set("tweet.fields", "author_id,created_at")
+	q.Set("expansions", "author_id")
+	q.Set("user.fields", "username")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.BaseURL+"/tweets/search/recent?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+f.Bearer)
+
+	hc := f.HTTP
+	if hc == nil {
+		hc = &http.Client{Timeout: 15 * time.Second}
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("thread search failed: status %d: %s", resp.StatusCode, string(b))
+	}
+
+	var out struct {
+		Data []struct {
+			ID        string `json:"id"`
+			Text      string `json:"text"`
+			AuthorID  string `json:"author_id"`
+			CreatedAt string `json:"created_at"`
+		} `json:"data"`
+		Includes struct {
+			Users []struct {
+				ID       string `json:"id"`
+				Username string `json:"username"`
+			} `json:"users"`
+		} `json:"includes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+
+	usernames := make(map[string]string, len(out.Includes.Users))
+	for _, u := range out.Includes.Users {
+		usernames[u.ID] = u.Username
+	}
+	turns := make([]types.Turn, 0, len(out.Data))
+	for _, t := range out.Data {
+		turns = append(turns, types.Turn{
+			TweetID:        t.ID,
+			AuthorID:       t.AuthorID,
+			AuthorUsername: usernames[t.AuthorID],
+			Text:           t.Text,
+			FromBot:        f.BotUserID != "" && t.AuthorID == f.BotUserID,
+			CreatedAt:      t.CreatedAt,
+		})
+	}
+	sort.SliceStable(turns, func(i, j int) bool { return turns[i].CreatedAt < turns[j].CreatedAt })
+	return turns, nil
+}
+
+// ConversationOf returns the conversation id of a tweet, the id of its thread's root.
+func (f *XFetcher) ConversationOf(ctx context.Context, tweetID string) (string, error) {
+	q := url.Values{}
+	q.Set("tweet.fields", "conversation_id")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.BaseURL+"/tweets/"+url.PathEscape(tweetID)+"?"+q.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+f.Bearer)
+
+	hc := f.HTTP
+	if hc == nil {
+		hc = &http.Client{Timeout: 15 * time.Second}
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("tweet lookup failed: status %d: %s", resp.StatusCode, string(b))
+	}
+
+	var out struct {
+		Data struct {
+			ConversationID string `json:"conversation_id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	if out.Data.ConversationID == "" {
+		return "", fmt.Errorf("tweet lookup: no conversation_id for %s", tweetID)
+	}
+	return out.Data.ConversationID, nil
+}
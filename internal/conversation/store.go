@@ -0,0 +1,169 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"cg-mentions-bot/internal/types"
+	"cg-mentions-bot/internal/utils/db"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Store keeps the tweets of each conversation the bot took part in.
+type Store interface {
+	// Append adds turns to the conversation, skipping tweets it already holds.
+	Append(ctx context.Context, conversationID string, turns ...types.Turn) error
+	// Recent returns up to limit of the latest turns, oldest first.
+	Recent(ctx context.Context, conversationID string, limit int) ([]types.Turn, error)
+	// DeleteAuthor removes the tweets authorID wrote, in every conversation.
+	DeleteAuthor(ctx context.Context, authorID string) error
+}
+
+// MemoryStore keeps conversations in process.
+type MemoryStore struct {
+	mu    sync.Mutex
+	turns map[string][]types.Turn
+}
+
+// NewMemoryStore returns an empty in-memory conversation store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{turns: make(map[string][]types.Turn)}
+}
+
+func (s *MemoryStore) Append(ctx context.Context, conversationID string, turns ...types.Turn) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := make(map[string]bool)
+	for _, t := range s.turns[conversationID] {
+		seen[t.TweetID] = true
+	}
+	for _, t := range turns {
+		if t.TweetID != "" && seen[t.TweetID] {
+			continue
+		}
+		seen[t.TweetID] = true
+		s.turns[conversationID] = append(s.turns[conversationID], t)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Recent(ctx context.Context, conversationID string, limit int) ([]types.Turn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all := s.turns[conversationID]
+	if limit > 0 && len(all) > limit {
+		all = all[len(all)-limit:]
+	}
+	return append([]types.Turn(nil), all...), nil
+}
+
+func (s *MemoryStore) DeleteAuthor(ctx context.Context, authorID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, turns := range s.turns {
+		kept := turns[:0]
+		for _, t := range turns {
+			if t.AuthorID != authorID {
+				kept = append(kept, t)
+			}
+		}
+		s.turns[id] = kept
+	}
+	return nil
+}
+
+// MongoStore persists turns in xreplyagent.conversation_turns, one document per turn.
+type MongoStore struct {
+	coll *mongo.Collection
+}
+
+type turnDoc struct {
+	ConversationID string     `bson:"conversation_id"`
+	Turn           types.Turn `bson:"turn"`
+	StoredAt       time.Time  `bson:"stored_at"`
+}
+
+// NewMongoStore returns a Mongo conversation store and ensures its index.
+func NewMongoStore(client *mongo.Client) (*MongoStore, error) {
+	mg := db.MongoDB{
+		Database:   "xreplyagent",
+		Collection: "conversation_turns",
+	}
+	coll := client.Database(mg.Database).Collection(mg.Collection)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "conversation_id", Value: 1}, {Key: "stored_at", Value: -1}}},
+		// A tweet is stored once per conversation; the bot's answers have no tweet id.
+		{
+			Keys: bson.D{{Key: "conversation_id", Value: 1}, {Key: "turn.tweet_id", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"turn.tweet_id": bson.M{"$exists": true}}),
+		},
+	}); err != nil {
+		return nil, err
+	}
+	return &MongoStore{coll: coll}, nil
+}
+
+func (s *MongoStore) Append(ctx context.Context, conversationID string, turns ...types.Turn) error {
+	if len(turns) == 0 {
+		return nil
+	}
+	now := time.Now()
+	docs := make([]any, 0, len(turns))
+	for i, t := range turns {
+		// Keep insertion order stable for turns appended in one call.
+		docs = append(docs, turnDoc{ConversationID: conversationID, Turn: t, StoredAt: now.Add(time.Duration(i) * time.Microsecond)})
+	}
+	_, err := s.coll.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	if onlyDuplicates(err) {
+		return nil
+	}
+	return err
+}
+
+// onlyDuplicates reports whether every failed insert was a tweet already stored.
+func onlyDuplicates(err error) bool {
+	var bwe mongo.BulkWriteException
+	if !errors.As(err, &bwe) || bwe.WriteConcernError != nil || len(bwe.WriteErrors) == 0 {
+		return false
+	}
+	for _, we := range bwe.WriteErrors {
+		if !mongo.IsDuplicateKeyError(we) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *MongoStore) Recent(ctx context.Context, conversationID string, limit int) ([]types.Turn, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "stored_at", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cur, err := s.coll.Find(ctx, bson.M{"conversation_id": conversationID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []turnDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make([]types.Turn, len(docs))
+	for i, d := range docs {
+		out[len(docs)-1-i] = d.Turn
+	}
+	return out, nil
+}
+
+func (s *MongoStore) DeleteAuthor(ctx context.Context, authorID string) error {
+	_, err := s.coll.DeleteMany(ctx, bson.M{"turn.author_id": authorID})
+	return err
+}
@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cg-mentions-bot/internal/conversation"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService(t *testing.T) {
+	t.Run("Earlier turns of the thread are returned, including the bot's replies", func(t *testing.T) {
+		svc := &conversation.Service{Store: conversation.NewMemoryStore()}
+		ctx := context.Background()
+
+		first := types.Mention{TweetID: "10", ConversationID: "10", Text: "@bot send 0.01 BNB to @bob", AuthorUsername: "alice"}
+		svc.Record(ctx, first, "Sent, tx 0xabc")
+
+		follow := types.Mention{TweetID: "12", ConversationID: "10", Text: "@bot send it again"}
+		history := svc.History(ctx, follow)
+
+		if assert.Len(t, history, 2) {
+			assert.Equal(t, "alice", history[0].AuthorUsername)
+			assert.True(t, history[1].FromBot)
+			assert.Equal(t, "Sent, tx 0xabc", history[1].Text)
+		}
+	})
+
+	t.Run("History is bounded by turns and characters, dropping the oldest first", func(t *testing.T) {
+		svc := &conversation.Service{Store: conversation.NewMemoryStore(), MaxTurns: 3, MaxChars: 10}
+		ctx := context.Background()
+		for _, text := range []string{"aaaa", "bbbb", "cccc", "dddd"} {
+			_ = svc.Store.Append(ctx, "1", types.Turn{Text: text})
+		}
+
+		history := svc.History(ctx, types.Mention{TweetID: "9", ConversationID: "1"})
+
+		assert.Equal(t, []types.Turn{{Text: "cccc"}, {Text: "dddd"}}, history)
+	})
+	t.Run("A newest turn longer than the budget is cut instead of dropped", func(t *testing.T) {
+		svc := &conversation.Service{Store: conversation.NewMemoryStore(), MaxChars: 10}
+		ctx := context.Background()
+		_ = svc.Store.Append(ctx, "1", types.Turn{Text: "old"}, types.Turn{Text: "héllo wörld, how are you"})
+
+		history := svc.History(ctx, types.Mention{TweetID: "9", ConversationID: "1"})
+
+		assert.Equal(t, []types.Turn{{Text: "héllo…"}}, history)
+	})
+
+	t.Run("A fetched thread and the recorded mention store each tweet once", func(t *testing.T) {
+		x := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"data":[
+				{"id":"10","text":"gm","author_id":"1","created_at":"2025-01-01T00:00:00Z"},
+				{"id":"11","text":"@bot price?","author_id":"2","created_at":"2025-01-01T00:01:00Z"}]}`))
+		}))
+		defer x.Close()
+		store := conversation.NewMemoryStore()
+		svc := &conversation.Service{Store: store, Fetcher: &conversation.XFetcher{BaseURL: x.URL}}
+		ctx := context.Background()
+
+		mention := types.Mention{TweetID: "11", ConversationID: "10", Text: "@bot price?"}
+		assert.Len(t, svc.History(ctx, mention), 1)
+		svc.Record(ctx, mention, "About $600.")
+		svc.Record(ctx, mention, "About $600.")
+
+		turns, err := store.Recent(ctx, "10", 0)
+		assert.NoError(t, err)
+		var ids []string
+		for _, turn := range turns {
+			ids = append(ids, turn.TweetID)
+		}
+		assert.Equal(t, []string{"10", "11", "", ""}, ids)
+	})
+}
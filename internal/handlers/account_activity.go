@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"cg-mentions-bot/internal/types"
+)
+
+// accountActivityEvent is the subset of an X Account Activity API delivery we use.
+type accountActivityEvent struct {
+	ForUserID         string          `json:"for_user_id"`
+	TweetCreateEvents []activityTweet `json:"tweet_create_events"`
+}
+
+// activityTweet is a v1.1 tweet object as delivered in tweet_create_events.
+type activityTweet struct {
+	IDStr     string `json:"id_str"`
+	Text      string `json:"text"`
+	CreatedAt string `json:"created_at"`
+	User      struct {
+		IDStr      string `json:"id_str"`
+		ScreenName string `json:"screen_name"`
+	} `json:"user"`
+	ExtendedTweet *struct {
+		FullText string           `json:"full_text"`
+		Entities activityEntities `json:"entities"`
+	} `json:"extended_tweet,omitempty"`
+	Entities activityEntities `json:"entities"`
+	// Set on replies: the tweet answered, not the root of the thread.
+	InReplyToStatusIDStr string `json:"in_reply_to_status_id_str"`
+}
+
+type activityEntities struct {
+	UserMentions []struct {
+		IDStr      string `json:"id_str"`
+		ScreenName string `json:"screen_name"`
+		Indices    []int  `json:"indices"`
+	} `json:"user_mentions"`
+}
+
+// activityLookupTimeout bounds the thread lookup of a webhook reply, since X
+// expects deliveries to be answered quickly.
+const activityLookupTimeout = 3 * time.Second
+
+// accountActivitySignature returns "sha256=" + base64(HMAC-SHA256(consumer secret, msg)),
+// the format X uses both for CRC responses and for x-twitter-webhooks-signature.
+func accountActivitySignature(secret string, msg []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(msg)
+	return "sha256=" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// AccountActivityCRC handles GET /webhooks/x, answering X's challenge-response check.
+func (h MentionsHandler) AccountActivityCRC(w http.ResponseWriter, r *http.Request) {
+	if h.ConsumerSecret == "" {
+		http.Error(w, "account activity disabled", http.StatusNotFound)
+		return
+	}
+	crcToken := r.URL.Query().Get("crc_token")
+	if crcToken == "" {
+		http.Error(w, "crc_token is required", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"response_token": accountActivitySignature(h.ConsumerSecret, []byte(crcToken)),
+	})
+}
+
+// AccountActivity handles POST /webhooks/x. It verifies the delivery signature,
+// turns tweet_create_events that mention the subscribed account into mentions
+// and submits them like n8n payloads.
+func (h MentionsHandler) AccountActivity(w http.ResponseWriter, r *http.Request) {
+	if h.ConsumerSecret == "" {
+		http.Error(w, "account activity disabled", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	want := accountActivitySignature(h.ConsumerSecret, body)
+	if !hmac.Equal([]byte(r.Header.Get("x-twitter-webhooks-signature")), []byte(want)) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var ev accountActivityEvent
+	if err := json.Unmarshal(body, &ev); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	mentions := make([]types.Mention, 0, len(ev.TweetCreateEvents))
+	for _, t := range ev.TweetCreateEvents {
+		if m, ok := activityMention(ev.ForUserID, t); ok {
+			m.ConversationID = h.activityConversation(r.Context(), m.ConversationID, t)
+			mentions = append(mentions, m)
+		}
+	}
+	if len(mentions) > 0 {
+		if err := h.Submit(r.Context(), mentions); err != nil {
+			log.Printf("account activity: submit %d mention(s) failed: %v", len(mentions), err)
+			http.Error(w, "failed to accept mentions", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// activityConversation returns the conversation id of t. v1.1 payloads only
+// name the tweet a reply answers, so the thread root is looked up on X; when
+// that is not possible the parent stands in, which still marks the mention as
+// a reply.
+func (h MentionsHandler) activityConversation(ctx context.Context, fallback string, t activityTweet) string {
+	if t.InReplyToStatusIDStr == "" || h.Conversations == nil || h.Conversations.Fetcher == nil {
+		return fallback
+	}
+	ctx, cancel := context.WithTimeout(ctx, activityLookupTimeout)
+	defer cancel()
+	id, err := h.Conversations.Fetcher.ConversationOf(ctx, t.InReplyToStatusIDStr)
+	if err != nil {
+		log.Printf("account activity: conversation of %s: %v", t.IDStr, err)
+		return fallback
+	}
+	return id
+}
+
+// activityMention maps a tweet_create_event to a Mention, skipping the
+// account's own tweets and tweets that do not mention it.
+func activityMention(forUserID string, t activityTweet) (types.Mention, bool) {
+	if t.IDStr == "" || t.User.IDStr == forUserID {
+		return types.Mention{}, false
+	}
+	// Tweets over 140 characters carry their full text and entities in
+	// extended_tweet; the top-level ones stop at the truncation.
+	text, entities := t.Text, t.Entities
+	if t.ExtendedTweet != nil && t.ExtendedTweet.FullText != "" {
+		text, entities = t.ExtendedTweet.FullText, t.ExtendedTweet.Entities
+	}
+	mentioned := false
+	for _, um := range entities.UserMentions {
+		if um.IDStr == forUserID {
+			mentioned = true
+			break
+		}
+	}
+	if !mentioned {
+		return types.Mention{}, false
+	}
+
+	var mentions []types.MentionedUser
+	for _, um := range entities.UserMentions {
+		u := types.MentionedUser{ID: um.IDStr, Username: um.ScreenName}
+		if len(um.Indices) == 2 {
+			u.Start, u.End = um.Indices[0], um.Indices[1]
+		}
+		mentions = append(mentions, u)
+	}
+	conversationID := t.IDStr
+	if t.InReplyToStatusIDStr != "" {
+		conversationID = t.InReplyToStatusIDStr
+	}
+	createdAt := t.CreatedAt
+	if ts, err := time.Parse(time.RubyDate, t.CreatedAt); err == nil {
+		createdAt = ts.UTC().Format(time.RFC3339)
+	}
+	return types.Mention{
+		TweetID:        t.IDStr,
+		Text:           text,
+		AuthorID:       t.User.IDStr,
+		AuthorUsername: t.User.ScreenName,
+		ConversationID: conversationID,
+		CreatedAt:      createdAt,
+		Entities:       &types.MentionEntities{Mentions: mentions},
+	}, true
+}
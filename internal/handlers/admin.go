@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"cg-mentions-bot/internal/types"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// adminAuthorized reports whether the request carries the admin token. Admin
+// endpoints are closed when no token is configured.
+func (h MentionsHandler) adminAuthorized(r *http.Request) bool {
+	if h.AdminToken == "" {
+		return false
+	}
+	got := r.Header.Get("X-Admin-Token")
+	return subtle.ConstantTimeCompare([]byte(got), []byte(h.AdminToken)) == 1
+}
+
+// Reprocess handles POST /admin/mentions/{tweet_id}/reprocess. It clears the
+// ledger entry for the tweet and runs the stored mention again, bypassing dedup.
+func (h MentionsHandler) Reprocess(w http.ResponseWriter, r *http.Request) {
+	if !h.adminAuthorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.Ledger == nil {
+		http.Error(w, "ledger disabled", http.StatusNotFound)
+		return
+	}
+
+	tweetID := chi.URLParam(r, "tweet_id")
+	entry, err := h.Ledger.Get(r.Context(), tweetID)
+	if err != nil {
+		log.Printf("read ledger entry failed: %v", err)
+		http.Error(w, "failed to read ledger", http.StatusInternalServerError)
+		return
+	}
+	if entry == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.Ledger.Delete(r.Context(), tweetID); err != nil {
+		log.Printf("delete ledger entry failed: %v", err)
+		http.Error(w, "failed to reset ledger", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("admin reprocess of tweet %s (was %s)", tweetID, entry.State)
+
+	mentions, _ := h.dedup(r.Context(), []types.Mention{entry.Mention})
+	if h.Queue != nil {
+		h.enqueue(w, r, 1, mentions, nil)
+		return
+	}
+
+	res := mentionResult{TweetID: tweetID, Posted: true}
+	if err := h.Process(r.Context(), entry.Mention); err != nil {
+		res = mentionResult{TweetID: tweetID, Posted: false, Error: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+
+	"cg-mentions-bot/internal/types"
+)
+
+// defaultConcurrency bounds how many authors are processed at once when
+// MentionsHandler.Concurrency is not set.
+const defaultConcurrency = 4
+
+// processBatch runs mentions in parallel across authors while keeping each
+// author's mentions strictly in order, so two transfers from one user never race
+// on the wallet nonce. Results are returned in input order.
+func (h MentionsHandler) processBatch(ctx context.Context, mentions []types.Mention) []mentionResult {
+	results := make([]mentionResult, len(mentions))
+
+	// Group indexes by author, preserving arrival order within each group.
+	var order []string
+	groups := make(map[string][]int)
+	for i, m := range mentions {
+		key := m.AuthorID
+		if key == "" {
+			// Unknown author: nothing to serialize against.
+			key = "tweet:" + m.TweetID
+		}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	limit := h.Concurrency
+	if limit <= 0 {
+		limit = defaultConcurrency
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for _, key := range order {
+		idxs := groups[key]
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			for _, i := range idxs {
+				results[i] = h.processOne(ctx, mentions[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// processOne runs a single mention under the per-mention timeout, if configured.
+func (h MentionsHandler) processOne(ctx context.Context, m types.Mention) mentionResult {
+	if h.MentionTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.MentionTimeout)
+		defer cancel()
+	}
+	if err := h.Process(ctx, m); err != nil {
+		return mentionResult{TweetID: m.TweetID, Posted: false, Error: err.Error()}
+	}
+	return mentionResult{TweetID: m.TweetID, Posted: true}
+}
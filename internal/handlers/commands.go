@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"cg-mentions-bot/internal/queue"
+	"cg-mentions-bot/internal/types"
+)
+
+// WalletTools runs tools on the wallet MCP server (create_wallet, transfer_asset,
+// get_wallet_balance, ...). Tool-reported failures must come back as errors.
+type WalletTools interface {
+	Call(ctx context.Context, tool string, args map[string]any) (string, error)
+}
+
+// commandKind is one of the wallet intents the grammar understands.
+type commandKind int
+
+const (
+	cmdSend commandKind = iota + 1
+	cmdBalance
+	cmdAddress
+	cmdConfirm
+)
+
+// command is a parsed wallet intent.
+//
+// Grammar (case-insensitive, leading @handles and trailing punctuation ignored):
+//
+//	send|transfer|tip|pay <amount> [<unit>] to <recipients> [on <chain>]
+//	split <amount> [<unit>] between|among <recipients> [on <chain>]
+//	[my] balance|bal [on <chain>]
+//	[my] address|wallet, what is my address|wallet
+//	confirm <code>
+//
+// where a unit is the chain's native token, gwei or wei, and recipients are
+// @handles or 0x addresses separated by commas, "and" or "&".
+type command struct {
+	kind       commandKind
+	amount     *big.Int // per recipient, in wei
+	display    string   // per-recipient amount as written, e.g. "0.01 BNB"
+	chain      chainInfo
+	recipients []string // "@handle" or 0x address
+	code       string   // confirmation code of a pending intent
+}
+
+type chainInfo struct {
+	ID     string
+	Name   string
+	Native string
+}
+
+// chains maps the names users write to chain ids. Commands only run on the
+// wallet's chain (see onWalletChain); the others are named to refuse them clearly.
+var chains = map[string]chainInfo{
+	"bnb":         {ID: "56", Name: "BNB Chain", Native: "bnb"},
+	"bsc":         {ID: "56", Name: "BNB Chain", Native: "bnb"},
+	"bsc-testnet": {ID: "97", Name: "BNB Chain testnet", Native: "tbnb"},
+	"eth":         {ID: "1", Name: "Ethereum", Native: "eth"},
+	"ethereum":    {ID: "1", Name: "Ethereum", Native: "eth"},
+	"base":        {ID: "8453", Name: "Base", Native: "eth"},
+}
+
+// nativeChains picks the chain implied by a native token when none is named.
+var nativeChains = map[string]string{"bnb": "bnb", "tbnb": "bsc-testnet", "eth": "ethereum"}
+
+var (
+	sendRe      = regexp.MustCompile(`^(?:send|transfer|tip|pay)\s+(\d+(?:\.\d+)?)\s*([a-z]+)?\s+to\s+(.+?)(?:\s+on\s+([a-z-]+))?$`)
+	splitRe     = regexp.MustCompile(`^split\s+(\d+(?:\.\d+)?)\s*([a-z]+)?\s+(?:between|among)\s+(.+?)(?:\s+on\s+([a-z-]+))?$`)
+	balanceRe   = regexp.MustCompile(`^(?:my\s+|what\s+is\s+my\s+|what's\s+my\s+)?(?:balance|bal)(?:\s+on\s+([a-z-]+))?$`)
+	addressRe   = regexp.MustCompile(`^(?:my\s+|what\s+is\s+my\s+|what's\s+my\s+)?(?:wallet\s+)?(?:address|wallet)$`)
+	confirmRe   = regexp.MustCompile(`^confirm\s+([a-z0-9]{4,12})$`)
+	recipientRe = regexp.MustCompile(`^(@\w+|0x[0-9a-f]{40})$`)
+	separatorRe = regexp.MustCompile(`\s*(?:,|\band\b|&)\s*|\s+`)
+	urlRe       = regexp.MustCompile(`https?://\S+`)
+)
+
+// errCommand is a user-facing problem with a recognized command.
+type errCommand struct{ msg string }
+
+func (e errCommand) Error() string { return e.msg }
+
+// commandText strips what X adds around the words of a command: URLs, the
+// leading run of @handles and trailing punctuation.
+func commandText(s string) string {
+	s = urlRe.ReplaceAllString(s, " ")
+	fields := strings.Fields(strings.ToLower(s))
+	i := 0
+	for i < len(fields) && strings.HasPrefix(fields[i], "@") {
+		i++
+	}
+	return strings.TrimRight(strings.Join(fields[i:], " "), "?!. ")
+}
+
+// parseCommand recognizes a wallet command. ok is false for free-form text,
+// which goes to the agent; err explains a recognized but invalid command.
+func parseCommand(text string, defaultChain string) (cmd command, ok bool, err error) {
+	if m := balanceRe.FindStringSubmatch(text); m != nil {
+		c, err := resolveChain(m[1], "", defaultChain)
+		if err == nil {
+			err = onWalletChain(c, defaultChain)
+		}
+		return command{kind: cmdBalance, chain: c}, true, err
+	}
+	if addressRe.MatchString(text) {
+		return command{kind: cmdAddress}, true, nil
+	}
+	if m := confirmRe.FindStringSubmatch(text); m != nil {
+		return command{kind: cmdConfirm, code: strings.ToUpper(m[1])}, true, nil
+	}
+
+	split := false
+	m := sendRe.FindStringSubmatch(text)
+	if m == nil {
+		if m = splitRe.FindStringSubmatch(text); m == nil {
+			return command{}, false, nil
+		}
+		split = true
+	}
+	amountStr, unit, recipientsStr, chainName := m[1], m[2], m[3], m[4]
+
+	var recipients []string
+	for _, r := range separatorRe.Split(recipientsStr, -1) {
+		if r == "" {
+			continue
+		}
+		if !recipientRe.MatchString(r) {
+			// "send 1 BNB to my friend" is not a command; let the agent handle it.
+			return command{}, false, nil
+		}
+		recipients = append(recipients, r)
+	}
+	if len(recipients) == 0 {
+		return command{}, false, nil
+	}
+
+	var decimals int
+	switch unit {
+	case "wei":
+		decimals = 0
+	case "gwei":
+		decimals = 9
+	case "", "bnb", "tbnb", "eth":
+		decimals = 18
+	default:
+		// Tokens other than the native asset are not supported by the wallet tools.
+		return command{}, false, nil
+	}
+	c, err := resolveChain(chainName, unit, defaultChain)
+	if err == nil {
+		err = onWalletChain(c, defaultChain)
+	}
+	if err != nil {
+		return command{}, true, err
+	}
+
+	total, err := toWei(amountStr, decimals)
+	if err != nil {
+		return command{}, true, err
+	}
+	per := new(big.Int).Set(total)
+	if split {
+		per.Quo(total, big.NewInt(int64(len(recipients))))
+	}
+	if per.Sign() <= 0 {
+		return command{}, true, errCommand{"The amount must be greater than zero."}
+	}
+	return command{
+		kind:       cmdSend,
+		amount:     per,
+		display:    fromWei(per) + " " + strings.ToUpper(c.Native),
+		chain:      c,
+		recipients: recipients,
+	}, true, nil
+}
+
+// resolveChain picks the named chain, else the one implied by the token, else the default.
+func resolveChain(name string, unit string, defaultChain string) (chainInfo, error) {
+	if name == "" {
+		name = nativeChains[unit]
+	}
+	if name == "" {
+		name = defaultChain
+	}
+	if name == "" {
+		name = "bnb"
+	}
+	c, ok := chains[name]
+	if !ok {
+		return chainInfo{}, errCommand{fmt.Sprintf("I don't support the %q chain yet.", name)}
+	}
+	if native, isNative := nativeChains[unit]; isNative && chains[native].Native != c.Native {
+		return chainInfo{}, errCommand{fmt.Sprintf("%s is not the native token of %s.", strings.ToUpper(unit), c.Name)}
+	}
+	return c, nil
+}
+
+// onWalletChain refuses chains other than the wallet's: the wallet server signs
+// and reads balances on the one chain of its RPC, whatever chain_id it is given.
+func onWalletChain(c chainInfo, defaultChain string) error {
+	wallet, err := resolveChain("", "", defaultChain)
+	if err != nil || wallet.ID == c.ID {
+		return err
+	}
+	return errCommand{fmt.Sprintf("I can only use %s for now, not %s.", wallet.Name, c.Name)}
+}
+
+// toWei converts a decimal amount with the given number of decimals to an integer.
+func toWei(amount string, decimals int) (*big.Int, error) {
+	whole, frac, _ := strings.Cut(amount, ".")
+	if len(frac) > decimals {
+		return nil, errCommand{fmt.Sprintf("%s has too many decimal places.", amount)}
+	}
+	v, ok := new(big.Int).SetString(whole+frac+strings.Repeat("0", decimals-len(frac)), 10)
+	if !ok {
+		return nil, errCommand{fmt.Sprintf("%s is not a valid amount.", amount)}
+	}
+	return v, nil
+}
+
+// fromWei renders wei as a decimal amount of an 18-decimal token.
+func fromWei(wei *big.Int) string {
+	s := wei.String()
+	if len(s) <= 18 {
+		s = strings.Repeat("0", 19-len(s)) + s
+	}
+	whole, frac := s[:len(s)-18], strings.TrimRight(s[len(s)-18:], "0")
+	if frac == "" {
+		return whole
+	}
+	return whole + "." + frac
+}
+
+// rejectCommand replies to a recognized but invalid command with what was wrong.
+func (h MentionsHandler) rejectCommand(ctx context.Context, m types.Mention, cmdErr error) (types.AgentResult, error) {
+	res := types.AgentResult{Answer: cmdErr.Error()}
+	if h.Reply == nil {
+		return res, queue.Permanent(errors.New("no reply poster configured"))
+	}
+	if err := h.Reply(ctx, ReplyIn{InReplyTo: m.TweetID, Text: res.Answer}); err != nil {
+		return res, err
+	}
+	res.Posted = true
+	return res, nil
+}
+
+// runCommand executes a wallet command directly against the wallet tools and
+// posts the reply. Errors are permanent: a retry could repeat a transfer.
+func (h MentionsHandler) runCommand(ctx context.Context, m types.Mention, cmd command) (types.AgentResult, error) {
+	res := types.AgentResult{}
+	var runErr error
+
+	switch cmd.kind {
+	case cmdAddress:
+		res.ToolsUsed = append(res.ToolsUsed, "create_wallet")
+		addr, err := h.Wallet.Call(ctx, "create_wallet", map[string]any{"twitter_id": m.AuthorID})
+		if err != nil {
+			runErr = err
+			res.Answer = "Sorry, I couldn't load your wallet right now. Please try again later."
+			break
+		}
+		res.Answer = "Your wallet address: " + strings.TrimSpace(addr)
+
+	case cmdBalance:
+		res.ToolsUsed = append(res.ToolsUsed, "create_wallet", "get_wallet_balance")
+		if _, err := h.Wallet.Call(ctx, "create_wallet", map[string]any{"twitter_id": m.AuthorID}); err != nil {
+			runErr = err
+			res.Answer = "Sorry, I couldn't load your wallet right now. Please try again later."
+			break
+		}
+		bal, err := h.Wallet.Call(ctx, "get_wallet_balance", map[string]any{"twitter_id": m.AuthorID})
+		wei, ok := new(big.Int).SetString(strings.TrimSpace(bal), 10)
+		if err != nil || !ok {
+			runErr = err
+			if runErr == nil {
+				runErr = fmt.Errorf("unexpected balance %q", bal)
+			}
+			res.Answer = "Sorry, I couldn't read your balance right now. Please try again later."
+			break
+		}
+		res.Answer = fmt.Sprintf("Your balance on %s: %s %s", cmd.chain.Name, fromWei(wei), strings.ToUpper(cmd.chain.Native))
+
+	case cmdSend:
+		if h.needsConfirmation(cmd) {
+			res.Answer, runErr = h.propose(ctx, m, cmd)
+			break
+		}
+		res.ToolsUsed = append(res.ToolsUsed, "create_wallet", "transfer_asset")
+		res.Answer, runErr = h.send(ctx, m.AuthorID, cmd, h.recipientIDs(ctx, m), &res)
+
+	case cmdConfirm:
+		res.Answer, runErr = h.confirm(ctx, m, cmd.code, &res)
+	}
+
+	if h.Reply == nil {
+		return res, queue.Permanent(errors.Join(runErr, errors.New("no reply poster configured")))
+	}
+	if err := h.Reply(ctx, ReplyIn{InReplyTo: m.TweetID, Text: res.Answer}); err != nil {
+		log.Printf("command reply for tweet %s failed: %v", m.TweetID, err)
+		runErr = errors.Join(runErr, err)
+	} else {
+		res.Posted = true
+	}
+	if runErr != nil {
+		return res, queue.Permanent(runErr)
+	}
+	return res, nil
+}
+
+// recipientIDs maps "@handle" to the user id of everyone the mention names.
+func (h MentionsHandler) recipientIDs(ctx context.Context, m types.Mention) map[string]string {
+	ids := map[string]string{}
+	for _, u := range h.mentionedPeople(ctx, m) {
+		ids["@"+strings.ToLower(u.Username)] = u.ID
+	}
+	return ids
+}
+
+// send transfers cmd.amount from authorID to each recipient in turn and
+// describes the outcome, including partial success.
+func (h MentionsHandler) send(ctx context.Context, authorID string, cmd command, ids map[string]string, res *types.AgentResult) (string, error) {
+	if _, err := h.Wallet.Call(ctx, "create_wallet", map[string]any{"twitter_id": authorID}); err != nil {
+		return "Sorry, I couldn't load your wallet right now. Please try again later.", err
+	}
+
+	var sent, failed []string
+	var errs []error
+	for _, r := range cmd.recipients {
+		to := r
+		if strings.HasPrefix(r, "@") {
+			id := ids[r]
+			if id == "" {
+				failed = append(failed, r+" (unknown user)")
+				continue
+			}
+			// create_wallet returns the existing wallet or makes one, so every user can receive.
+			addr, err := h.Wallet.Call(ctx, "create_wallet", map[string]any{"twitter_id": id})
+			if err != nil {
+				failed = append(failed, r)
+				errs = append(errs, err)
+				continue
+			}
+			to = strings.TrimSpace(addr)
+		}
+		tx, err := h.Wallet.Call(ctx, "transfer_asset", map[string]any{
+			"chain_id":   cmd.chain.ID,
+			"to_address": to,
+			"amount":     cmd.amount.String(),
+			"twitter_id": authorID,
+		})
+		if err != nil {
+			failed = append(failed, r)
+			errs = append(errs, err)
+			continue
+		}
+		tx = strings.TrimSpace(tx)
+		res.TxHashes = append(res.TxHashes, tx)
+		sent = append(sent, r+" (tx "+tx+")")
+	}
+
+	var b strings.Builder
+	if len(sent) > 0 {
+		each := ""
+		if len(cmd.recipients) > 1 {
+			each = " each"
+		}
+		b.WriteString(fmt.Sprintf("Sent %s%s on %s to %s.", cmd.display, each, cmd.chain.Name, strings.Join(sent, ", ")))
+	}
+	if len(failed) > 0 {
+		if b.Len() > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString("Couldn't send to " + strings.Join(failed, ", ") + ".")
+		if len(errs) == 0 {
+			errs = append(errs, fmt.Errorf("unresolved recipients: %s", strings.Join(failed, ", ")))
+		}
+	}
+	return b.String(), errors.Join(errs...)
+}
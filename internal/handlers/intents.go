@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"cg-mentions-bot/internal/intents"
+	"cg-mentions-bot/internal/types"
+)
+
+var nativeAmountRe = regexp.MustCompile(`^\d+(?:\.\d+)?$`)
+
+// needsConfirmation reports whether a send moves more than ConfirmAbove in total.
+func (h MentionsHandler) needsConfirmation(cmd command) bool {
+	if h.Intents == nil || h.ConfirmAbove == nil {
+		return false
+	}
+	total := new(big.Int).Mul(cmd.amount, big.NewInt(int64(len(cmd.recipients))))
+	return total.Cmp(h.ConfirmAbove) > 0
+}
+
+// propose stores the send as a pending intent and asks the author to confirm it.
+// Recipients are resolved now, since the confirming tweet will not mention them.
+func (h MentionsHandler) propose(ctx context.Context, m types.Mention, cmd command) (string, error) {
+	window := h.ConfirmWindow
+	if window <= 0 {
+		window = intents.DefaultWindow
+	}
+	code, err := intents.NewCode()
+	if err != nil {
+		return "Sorry, I couldn't prepare that transfer. Please try again later.", err
+	}
+
+	each := ""
+	if len(cmd.recipients) > 1 {
+		each = " each"
+	}
+	summary := fmt.Sprintf("%s%s on %s to %s", cmd.display, each, cmd.chain.Name, strings.Join(cmd.recipients, ", "))
+	now := time.Now()
+	in := intents.Intent{
+		Code:         code,
+		AuthorID:     m.AuthorID,
+		TweetID:      m.TweetID,
+		ChainID:      cmd.chain.ID,
+		ChainName:    cmd.chain.Name,
+		Native:       cmd.chain.Native,
+		AmountWei:    cmd.amount.String(),
+		Display:      cmd.display,
+		Recipients:   cmd.recipients,
+		RecipientIDs: h.recipientIDs(ctx, m),
+		Summary:      summary,
+		Status:       intents.StatusPending,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(window),
+	}
+	if err := h.Intents.Create(ctx, in); err != nil {
+		return "Sorry, I couldn't prepare that transfer. Please try again later.", err
+	}
+	log.Printf("intent %s: %s wants to send %s (tweet %s)", code, m.AuthorID, summary, m.TweetID)
+	return fmt.Sprintf("You're about to send %s. Reply \"confirm %s\" within %s to go ahead.", summary, code, formatWindow(window)), nil
+}
+
+// confirm executes a pending intent for its author. Unknown, foreign and
+// expired codes are rejected and logged.
+func (h MentionsHandler) confirm(ctx context.Context, m types.Mention, code string, res *types.AgentResult) (string, error) {
+	if h.Intents == nil {
+		return "There is nothing to confirm.", nil
+	}
+	in, err := h.Intents.Confirm(ctx, code, m.AuthorID, time.Now())
+	switch {
+	case errors.Is(err, intents.ErrExpired):
+		log.Printf("intent %s: rejected expired confirmation from %s (tweet %s)", code, m.AuthorID, m.TweetID)
+		return "That confirmation code has expired. Send the command again to get a new one.", nil
+	case errors.Is(err, intents.ErrUsed):
+		log.Printf("intent %s: rejected repeated confirmation from %s (tweet %s)", code, m.AuthorID, m.TweetID)
+		return "That transfer was already confirmed.", nil
+	case errors.Is(err, intents.ErrNotFound), errors.Is(err, intents.ErrWrongAuthor):
+		// Same answer for both so codes of other users cannot be probed.
+		log.Printf("intent %s: rejected confirmation from %s (tweet %s): %v", code, m.AuthorID, m.TweetID, err)
+		return "I couldn't find a pending transfer for that code.", nil
+	case err != nil:
+		return "Sorry, I couldn't check that code right now. Please try again later.", err
+	}
+
+	amount, ok := new(big.Int).SetString(in.AmountWei, 10)
+	if !ok {
+		return "Sorry, that transfer could not be executed.", fmt.Errorf("intent %s: bad amount %q", code, in.AmountWei)
+	}
+	log.Printf("intent %s: confirmed by %s (tweet %s)", code, m.AuthorID, m.TweetID)
+	cmd := command{
+		kind:       cmdSend,
+		amount:     amount,
+		display:    in.Display,
+		chain:      chainInfo{ID: in.ChainID, Name: in.ChainName, Native: in.Native},
+		recipients: in.Recipients,
+	}
+	res.ToolsUsed = append(res.ToolsUsed, "create_wallet", "transfer_asset")
+	return h.send(ctx, in.AuthorID, cmd, in.RecipientIDs, res)
+}
+
+func formatWindow(d time.Duration) string {
+	if d%time.Minute == 0 {
+		return fmt.Sprintf("%d minutes", int(d/time.Minute))
+	}
+	return d.String()
+}
+
+// ParseNativeAmount converts a decimal amount of an 18-decimal native token
+// (e.g. "0.05") to wei, for thresholds such as ConfirmAbove.
+func ParseNativeAmount(s string) (*big.Int, error) {
+	s = strings.TrimSpace(s)
+	if !nativeAmountRe.MatchString(s) {
+		return nil, fmt.Errorf("%q is not a decimal amount", s)
+	}
+	return toWei(s, 18)
+}
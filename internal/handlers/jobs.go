@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"cg-mentions-bot/internal/types"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// enqueue stores mentions as jobs and replies 202 with their ids.
+func (h MentionsHandler) enqueue(w http.ResponseWriter, r *http.Request, received int, mentions []types.Mention, duplicates []mentionResult) {
+	jobs, err := h.Queue.Enqueue(r.Context(), mentions)
+	if err != nil {
+		log.Printf("enqueue mentions failed: %v", err)
+		h.forget(mentions)
+		http.Error(w, "failed to enqueue mentions", http.StatusServiceUnavailable)
+		return
+	}
+
+	type queued struct {
+		TweetID string `json:"tweet_id"`
+		JobID   string `json:"job_id"`
+	}
+	out := make([]queued, 0, len(jobs))
+	for _, j := range jobs {
+		out = append(out, queued{TweetID: j.Mention.TweetID, JobID: j.ID})
+	}
+
+	summary := struct {
+		Received   int             `json:"received"`
+		Queued     int             `json:"queued"`
+		Jobs       []queued        `json:"jobs"`
+		Duplicates []mentionResult `json:"duplicates,omitempty"`
+	}{
+		Received:   received,
+		Queued:     len(out),
+		Jobs:       out,
+		Duplicates: duplicates,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(summary)
+}
+
+// JobStatus handles GET /mentions/jobs/{id} and returns the stored job.
+func (h MentionsHandler) JobStatus(w http.ResponseWriter, r *http.Request) {
+	// Job status is guarded like /mentions; a signed GET signs an empty body.
+	if err := h.verify(r, nil); err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.Queue == nil {
+		http.Error(w, "queue disabled", http.StatusNotFound)
+		return
+	}
+
+	job, err := h.Queue.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		log.Printf("read job failed: %v", err)
+		http.Error(w, "failed to read job", http.StatusInternalServerError)
+		return
+	}
+	if job == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(job)
+}
+
+// forget drops ledger entries for mentions that were never queued so a retried
+// webhook is not mistaken for a replay.
+func (h MentionsHandler) forget(mentions []types.Mention) {
+	if h.Ledger == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, m := range mentions {
+		if err := h.Ledger.Delete(ctx, m.TweetID); err != nil {
+			log.Printf("ledger delete for tweet %s failed: %v", m.TweetID, err)
+		}
+	}
+}
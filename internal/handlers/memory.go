@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// UserMemory handles GET /users/{twitter_id}/memory and returns what the bot
+// remembers about a user.
+func (h MentionsHandler) UserMemory(w http.ResponseWriter, r *http.Request) {
+	if !h.adminAuthorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.Memory == nil {
+		http.Error(w, "memory disabled", http.StatusNotFound)
+		return
+	}
+
+	twitterID := chi.URLParam(r, "twitter_id")
+	p, err := h.Memory.Store.Get(r.Context(), twitterID)
+	if err != nil {
+		log.Printf("read user memory failed: %v", err)
+		http.Error(w, "failed to read memory", http.StatusInternalServerError)
+		return
+	}
+	if p == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// ForgetUser handles DELETE /users/{twitter_id}/memory, wiping everything the
+// bot keeps about a user (privacy requests): the memory profile, the user's
+// tweets in stored conversations and the traces of their questions. The bot's
+// own replies stay in the threads, and shadow records and the dedup ledger
+// expire on their own.
+func (h MentionsHandler) ForgetUser(w http.ResponseWriter, r *http.Request) {
+	if !h.adminAuthorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.Memory == nil && h.Conversations == nil && h.Traces == nil {
+		http.Error(w, "memory disabled", http.StatusNotFound)
+		return
+	}
+
+	twitterID := chi.URLParam(r, "twitter_id")
+	ctx := r.Context()
+	var errs []error
+	if h.Memory != nil {
+		errs = append(errs, h.Memory.Store.Delete(ctx, twitterID))
+	}
+	if h.Conversations != nil {
+		errs = append(errs, h.Conversations.Store.DeleteAuthor(ctx, twitterID))
+	}
+	if h.Traces != nil {
+		errs = append(errs, h.Traces.DeleteUser(ctx, twitterID))
+	}
+	if err := errors.Join(errs...); err != nil {
+		log.Printf("delete user memory failed: %v", err)
+		http.Error(w, "failed to delete memory", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("admin wiped memory of user %s", twitterID)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,15 +2,29 @@ package handlers
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
+	"math/big"
 	"net/http"
-	"os"
 	"regexp"
 	"strings"
-
+	"time"
+
+	"cg-mentions-bot/internal/conversation"
+	"cg-mentions-bot/internal/intents"
+	"cg-mentions-bot/internal/ledger"
+	"cg-mentions-bot/internal/memory"
+	"cg-mentions-bot/internal/queue"
+	"cg-mentions-bot/internal/ratelimit"
+	"cg-mentions-bot/internal/shadow"
+	"cg-mentions-bot/internal/traces"
 	"cg-mentions-bot/internal/types"
+	"cg-mentions-bot/internal/users"
+	"cg-mentions-bot/internal/webhook"
 )
 
 // MentionsHandler handles POST /mentions events.
@@ -18,40 +32,48 @@ type MentionsHandler struct {
 	Secret string
 	Ask    func(ctx context.Context, text string, twitterId string) (string, error)
 	Reply  func(ctx context.Context, in ReplyIn) error
-	// If set, uses the agent binary to both answer and post per mention.
-	AgentRun func(ctx context.Context, question string, replyTo string, twitterId string, mentionedPeople []string) (string, error)
-}
-
-// XIdResponse used for x id of the user
-type XIdResponse struct {
-	Data *struct {
-		CreatedAt string `json:"created_at"`
-		ID        string `json:"id"`
-		Name      string `json:"name"`
-		Protected bool   `json:"protected"`
-		Username  string `json:"username"`
-	} `json:"data,omitempty"`
-	Errors []struct {
-		Detail string `json:"detail"`
-		Status int    `json:"status"`
-		Title  string `json:"title"`
-		Type   string `json:"type"`
-	} `json:"errors,omitempty"`
-	Includes *struct {
-		Users []struct {
-			CreatedAt string `json:"created_at"`
-			ID        string `json:"id"`
-			Name      string `json:"name"`
-			Protected bool   `json:"protected"`
-			Username  string `json:"username"`
-		} `json:"users,omitempty"`
-
-		Tweets []struct {
-			AuthorID string `json:"author_id"`
-			ID       string `json:"id"`
-			Text     string `json:"text"`
-		} `json:"tweets,omitempty"`
-	} `json:"includes,omitempty"`
+	// If set, uses the agent (in-process or the agent binary) to both answer and post per mention.
+	AgentRun func(ctx context.Context, req types.AgentRequest) (types.AgentResult, error)
+	// If set, earlier tweets of the thread are passed to the agent and each answer is recorded.
+	Conversations *conversation.Service
+	// If set, wallet commands ("send 0.01 BNB to @bob", "balance", "my address") run
+	// directly against these tools; everything else goes to the agent.
+	Wallet WalletTools
+	// Chain the wallet server signs and reads balances on (that of its RPC, default
+	// "bnb"). Commands use it when they name no chain and are refused on any other.
+	DefaultChain string
+	// Sends above ConfirmAbove (wei, total) become pending intents that run only after
+	// the author replies "confirm <code>" within ConfirmWindow (default 10m).
+	Intents       intents.Store
+	ConfirmAbove  *big.Int
+	ConfirmWindow time.Duration
+	// Shadow holds what a dry-run bot would have posted or sent (GET /shadow/replies).
+	Shadow shadow.Store
+	// Traces holds what the agent did per tweet (GET /traces/{tweet_id}).
+	Traces traces.Store
+	// Resolves @handles to user ids; without it only ids from the payload's entities are used.
+	Users *users.Resolver
+	// If set, a per-user memory summary is passed to the agent and updated after each answer.
+	Memory *memory.Service
+	// If set, mentions are enqueued and processed by a worker pool instead of inside the request.
+	Queue queue.Store
+	// If set, tweets are deduplicated so webhook replays never re-run the agent.
+	Ledger ledger.Store
+	// If set, /mentions and job status require HMAC-signed requests with replay protection.
+	Verifier *webhook.Verifier
+	// X app consumer secret; enables the Account Activity API webhook when set.
+	ConsumerSecret string
+	// Guards admin endpoints (X-Admin-Token header); admin endpoints are disabled when empty.
+	AdminToken string
+	// Max authors processed in parallel for inline batches (default 4). Mentions from the
+	// same author always run one after another, in order.
+	Concurrency int
+	// Per-mention deadline derived from the request context; no extra limit when zero.
+	MentionTimeout time.Duration
+	// If set, mentions pass per-user and global rate limits and the block/allow list
+	// before reaching the agent. Throttled users get ThrottleMessage via Reply.
+	Limiter         *ratelimit.Limiter
+	ThrottleMessage string
 }
 
 // ReplyIn contains minimal info to reply to a tweet.
@@ -62,17 +84,18 @@ type ReplyIn struct {
 
 // Handle verifies secret (if configured), processes mentions, and returns a summary.
 func (h MentionsHandler) Handle(w http.ResponseWriter, r *http.Request) {
-	if h.Secret != "" && r.Header.Get("X-Webhook-Secret") != h.Secret {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
-		return
-	}
-
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
 		return
 	}
 
+	if err := h.verify(r, body); err != nil {
+		log.Printf("rejected /mentions request: %v", err)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	// Accept either a single payload object or an array of payloads
 	var payload types.MentionsPayload
 	var payloads []types.MentionsPayload
@@ -100,44 +123,21 @@ func (h MentionsHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		received = len(mentions)
 	}
 
-	type res struct {
-		TweetID string `json:"tweet_id"`
-		Posted  bool   `json:"posted"`
-		Error   string `json:"error,omitempty"`
-	}
-
-	results := make([]res, 0, len(mentions))
-
-	for _, m := range mentions {
-		q := normalizeTweetText(m.Text)
-		mentionedUsers := handleMentions(m.Text)
-		if h.AgentRun != nil {
-			if _, err := h.AgentRun(r.Context(), q, m.TweetID, m.AuthorID, mentionedUsers); err != nil {
-				results = append(results, res{TweetID: m.TweetID, Posted: false, Error: err.Error()})
-			} else {
-				results = append(results, res{TweetID: m.TweetID, Posted: true})
-			}
-			continue
-		}
-
-		ans, err := h.Ask(r.Context(), q, m.AuthorID)
-		if err != nil {
-			results = append(results, res{TweetID: m.TweetID, Posted: false, Error: err.Error()})
-			continue
-		}
-
-		if postErr := h.Reply(r.Context(), ReplyIn{InReplyTo: m.TweetID, Text: ans}); postErr != nil {
-			results = append(results, res{TweetID: m.TweetID, Posted: false, Error: postErr.Error()})
-			continue
-		}
+	mentions, duplicates := h.dedup(r.Context(), mentions)
 
-		results = append(results, res{TweetID: m.TweetID, Posted: true})
+	if h.Queue != nil {
+		h.enqueue(w, r, received, mentions, duplicates)
+		return
 	}
 
+	results := make([]mentionResult, 0, len(mentions)+len(duplicates))
+	results = append(results, duplicates...)
+	results = append(results, h.processBatch(r.Context(), mentions)...)
+
 	summary := struct {
-		Received  int   `json:"received"`
-		Processed int   `json:"processed"`
-		Results   []res `json:"results"`
+		Received  int             `json:"received"`
+		Processed int             `json:"processed"`
+		Results   []mentionResult `json:"results"`
 	}{
 		Received:  received,
 		Processed: len(mentions),
@@ -149,96 +149,238 @@ func (h MentionsHandler) Handle(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(summary)
 }
 
-// handleMentions returns mentioned users from a tweet
-func handleMentions(s string) []string {
-	// Regex: @ followed by word characters (letters, numbers, underscore)
-	re := regexp.MustCompile(`@(\w+)`)
-
-	// Find all matches (only capture group 1, without "@")
-	matches := re.FindAllStringSubmatch(s, -1)
-
-	var users []string
-	for _, match := range matches {
-		if len(match) > 1 {
-			xId := getXId(match[1])
-			users = append(users, xId)
+// Submit feeds mentions from a non-webhook source (e.g. the X poller) into the same
+// pipeline as POST /mentions: dedup, then enqueue or process inline.
+func (h MentionsHandler) Submit(ctx context.Context, mentions []types.Mention) error {
+	mentions, _ = h.dedup(ctx, mentions)
+	if len(mentions) == 0 {
+		return nil
+	}
+	if h.Queue != nil {
+		if _, err := h.Queue.Enqueue(ctx, mentions); err != nil {
+			h.forget(mentions)
+			return err
+		}
+		return nil
+	}
+	for _, res := range h.processBatch(ctx, mentions) {
+		if res.Error != "" {
+			log.Printf("processing tweet %s failed: %s", res.TweetID, res.Error)
 		}
 	}
+	return nil
+}
 
-	return users
+// mentionResult is the per-tweet outcome reported back to the webhook caller.
+type mentionResult struct {
+	TweetID   string `json:"tweet_id"`
+	Posted    bool   `json:"posted"`
+	Error     string `json:"error,omitempty"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+	State     string `json:"state,omitempty"`
+	Answer    string `json:"answer,omitempty"`
 }
 
-// normalizeTweetText removes handles and URLs and trims whitespace to form a concise question input.
-func normalizeTweetText(s string) string {
-	// Remove URLs
-	urlRe := regexp.MustCompile(`https?://\S+`)
-	s = urlRe.ReplaceAllString(s, " ")
-	// Remove @handles
-	handleRe := regexp.MustCompile(`@[A-Za-z0-9_]+`)
-	s = handleRe.ReplaceAllString(s, " ")
-	// Collapse whitespace
-	s = strings.TrimSpace(strings.Join(strings.Fields(s), " "))
-	return s
+// dedup records mentions in the ledger (if configured) and splits them into new
+// mentions and replays, which are answered from their stored result.
+func (h MentionsHandler) dedup(ctx context.Context, mentions []types.Mention) ([]types.Mention, []mentionResult) {
+	if h.Ledger == nil {
+		return mentions, nil
+	}
+	fresh := make([]types.Mention, 0, len(mentions))
+	var replays []mentionResult
+	for _, m := range mentions {
+		entry, isNew, err := h.Ledger.Begin(ctx, m)
+		if err != nil {
+			// Without the ledger we cannot tell a replay apart; process rather than drop it.
+			log.Printf("ledger begin for tweet %s failed: %v", m.TweetID, err)
+			fresh = append(fresh, m)
+			continue
+		}
+		if isNew {
+			fresh = append(fresh, m)
+			continue
+		}
+		log.Printf("tweet %s already %s; skipping replay", m.TweetID, entry.State)
+		replays = append(replays, mentionResult{
+			TweetID:   entry.TweetID,
+			Posted:    entry.State == ledger.StatePosted,
+			Error:     entry.Error,
+			Duplicate: true,
+			State:     string(entry.State),
+			Answer:    entry.Answer,
+		})
+	}
+	return fresh, replays
 }
 
-func getXId(username string) string {
-	url := "https://api.x.com/2/users/by/username/" + username
-	log.Printf("url is : %s", url)
-	req, err := http.NewRequest("GET", url, nil)
+// Process answers a single mention and posts the reply, recording progress in
+// the ledger when one is configured. Running it again for the same tweet (a
+// queue retry or an expired lease) never repeats side effects: posted tweets
+// are done, and tweets an earlier attempt left mid-answer or failed after
+// posting or transferring are failed permanently.
+func (h MentionsHandler) Process(ctx context.Context, m types.Mention) error {
+	if done, err := h.resume(ctx, m); done {
+		return err
+	}
+	if err := h.admit(ctx, m); err != nil {
+		h.mark(m.TweetID, ledger.StateFailed, "", err)
+		return err
+	}
+	h.mark(m.TweetID, ledger.StateAnswering, "", nil)
+	res, err := h.answer(ctx, m)
 	if err != nil {
-		log.Printf("Error creating request for %s: %s", url, err)
-		return ""
+		h.mark(m.TweetID, ledger.StateFailed, res.Answer, err)
+		if res.Posted || len(res.TxHashes) > 0 || res.SideEffects {
+			// Running it again could post or transfer a second time.
+			return queue.Permanent(err)
+		}
+		return err
 	}
-	req.Header.Add("Authorization", "Bearer "+os.Getenv("XAUTH_TOKEN"))
+	if len(res.ToolsUsed) > 0 || len(res.TxHashes) > 0 {
+		log.Printf("tweet %s answered using %v (tx: %v)", m.TweetID, res.ToolsUsed, res.TxHashes)
+	}
+	h.mark(m.TweetID, ledger.StatePosted, res.Answer, nil)
+	if h.Conversations != nil {
+		h.Conversations.Record(ctx, m, res.Answer)
+	}
+	if h.Memory != nil {
+		h.Memory.Remember(ctx, m.AuthorID, normalizeTweetText(m.Text), res.Answer)
+	}
+	return nil
+}
 
-	res, err := http.DefaultClient.Do(req)
+// resume looks up the ledger before a run. done is true when the tweet must not
+// be answered again; err is then nil for a tweet already posted and a permanent
+// error for one an earlier attempt stopped answering, whose side effects are unknown.
+func (h MentionsHandler) resume(ctx context.Context, m types.Mention) (done bool, err error) {
+	if h.Ledger == nil {
+		return false, nil
+	}
+	entry, err := h.Ledger.Get(ctx, m.TweetID)
 	if err != nil {
-		log.Println(err)
-		return ""
-	}
-	if res == nil {
-		log.Println("response is nil")
-		return ""
-	}
-	defer func() {
-		if res.Body != nil {
-			if err := res.Body.Close(); err != nil {
-				log.Printf("Error closing response body: %v", err)
-			}
-		}
-	}()
+		log.Printf("ledger get for tweet %s failed: %v", m.TweetID, err)
+		return false, nil
+	}
+	if entry == nil {
+		return false, nil
+	}
+	switch entry.State {
+	case ledger.StatePosted:
+		log.Printf("tweet %s already posted; not answering it again", m.TweetID)
+		return true, nil
+	case ledger.StateAnswering:
+		err := fmt.Errorf("an earlier attempt stopped while answering tweet %s; not running it again", m.TweetID)
+		h.mark(m.TweetID, ledger.StateFailed, "", err)
+		return true, queue.Permanent(err)
+	}
+	return false, nil
+}
 
-	// Check HTTP status code
-	if res.StatusCode != http.StatusOK {
-		log.Printf("Error fetching user id: %s", res.Status)
-		return ""
+// answer runs a wallet command directly, or either the agent or Ask + Reply for
+// free-form mentions.
+func (h MentionsHandler) answer(ctx context.Context, m types.Mention) (types.AgentResult, error) {
+	if h.Wallet != nil {
+		cmd, ok, err := parseCommand(commandText(m.Text), h.DefaultChain)
+		switch {
+		case ok && err != nil:
+			return h.rejectCommand(ctx, m, err)
+		case ok:
+			return h.runCommand(ctx, m, cmd)
+		}
+	}
+	q := normalizeTweetText(m.Text)
+	mentionedUsers := h.mentionedPeople(ctx, m)
+	if h.AgentRun != nil {
+		req := types.AgentRequest{
+			Question:        q,
+			ReplyTo:         m.TweetID,
+			TwitterID:       m.AuthorID,
+			MentionedPeople: mentionedUsers,
+		}
+		if h.Conversations != nil {
+			req.History = h.Conversations.History(ctx, m)
+		}
+		if h.Memory != nil {
+			req.Memory = h.Memory.Block(ctx, m.AuthorID)
+		}
+		return h.AgentRun(ctx, req)
 	}
 
-	body, err := io.ReadAll(res.Body)
+	ans, err := h.Ask(ctx, q, m.AuthorID)
 	if err != nil {
-		log.Printf("Error reading response body: %v", err)
-		return ""
+		return types.AgentResult{}, err
 	}
+	if err := h.Reply(ctx, ReplyIn{InReplyTo: m.TweetID, Text: ans}); err != nil {
+		return types.AgentResult{Answer: ans}, err
+	}
+	return types.AgentResult{Answer: ans, Posted: true}, nil
+}
 
-	var resp XIdResponse
-	if err := json.Unmarshal(body, &resp); err != nil {
-		log.Printf("Error unmarshaling X API response for user %s: %v", username, err)
-		return ""
+func (h MentionsHandler) mark(tweetID string, state ledger.State, answer string, err error) {
+	if h.Ledger == nil {
+		return
 	}
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+	}
+	// Detached from the request so the outcome is recorded even if the caller went away.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if mErr := h.Ledger.Mark(ctx, tweetID, state, answer, errMsg); mErr != nil {
+		log.Printf("ledger mark %s for tweet %s failed: %v", state, tweetID, mErr)
+	}
+}
 
-	// Check if resp.Data is nil before accessing its fields
-	if resp.Data == nil {
-		log.Printf("No user data found for username: %s", username)
-		return ""
+// authorized reports whether the request carries the shared webhook secret (if configured).
+func (h MentionsHandler) authorized(r *http.Request) bool {
+	if h.Secret == "" {
+		return true
 	}
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Webhook-Secret")), []byte(h.Secret)) == 1
+}
 
-	// Check if ID is empty
-	if resp.Data.ID == "" {
-		log.Printf("Empty user ID for username: %s", username)
-		return ""
+// verify authenticates a /mentions request. Once a Verifier is configured every
+// request must be signed; otherwise the shared secret, if any, is checked.
+func (h MentionsHandler) verify(r *http.Request, body []byte) error {
+	if h.Verifier != nil {
+		return h.Verifier.Verify(r.Context(), r, body)
+	}
+	if !h.authorized(r) {
+		return errors.New("bad X-Webhook-Secret")
 	}
+	return nil
+}
 
-	println(resp.Data.ID)
+// mentionedPeople returns the users a mention talks about. With a resolver,
+// @handles in the text are resolved to user ids (skipping the bot and the
+// reply-chain prefix); without one only ids in the payload's entities count.
+func (h MentionsHandler) mentionedPeople(ctx context.Context, m types.Mention) []types.MentionedUser {
+	if h.Users != nil {
+		return h.Users.Mentioned(ctx, m)
+	}
+	if m.Entities == nil {
+		return nil
+	}
+	var out []types.MentionedUser
+	for _, u := range m.Entities.Mentions {
+		if u.ID != "" {
+			out = append(out, u)
+		}
+	}
+	return out
+}
 
-	return resp.Data.ID
+// normalizeTweetText removes handles and URLs and trims whitespace to form a concise question input.
+func normalizeTweetText(s string) string {
+	// Remove URLs
+	urlRe := regexp.MustCompile(`https?://\S+`)
+	s = urlRe.ReplaceAllString(s, " ")
+	// Remove @handles
+	handleRe := regexp.MustCompile(`@[A-Za-z0-9_]+`)
+	s = handleRe.ReplaceAllString(s, " ")
+	// Collapse whitespace
+	s = strings.TrimSpace(strings.Join(strings.Fields(s), " "))
+	return s
 }
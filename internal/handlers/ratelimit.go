@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"cg-mentions-bot/internal/queue"
+	"cg-mentions-bot/internal/ratelimit"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// DefaultThrottleMessage is posted to users who hit the rate limit.
+const DefaultThrottleMessage = "You're sending requests faster than I can keep up with. Please try again in a little while 🙏"
+
+var (
+	ErrRateLimited = errors.New("rate limited")
+	ErrBlocked     = errors.New("author is blocked")
+)
+
+// admit runs the rate limiter for a mention. Rejections are permanent so the
+// queue does not retry them; throttled users get a polite reply at most once
+// per cooldown, blocked users and mentions over the global budget get nothing.
+func (h MentionsHandler) admit(ctx context.Context, m types.Mention) error {
+	if h.Limiter == nil {
+		return nil
+	}
+	switch h.Limiter.Check(ctx, m.AuthorID) {
+	case ratelimit.Allow:
+		return nil
+	case ratelimit.Blocked:
+		log.Printf("dropping tweet %s from blocked user %s", m.TweetID, m.AuthorID)
+		return queue.Permanent(ErrBlocked)
+	case ratelimit.Exhausted:
+		// Replying would spend more of the budget that just ran out.
+		log.Printf("global budget spent, dropping tweet %s from user %s", m.TweetID, m.AuthorID)
+		return queue.Permanent(ErrRateLimited)
+	default:
+		log.Printf("rate limited tweet %s from user %s", m.TweetID, m.AuthorID)
+		if h.Reply != nil && h.Limiter.ShouldNotify(m.AuthorID) {
+			msg := h.ThrottleMessage
+			if msg == "" {
+				msg = DefaultThrottleMessage
+			}
+			if err := h.Reply(ctx, ReplyIn{InReplyTo: m.TweetID, Text: msg}); err != nil {
+				log.Printf("throttle notice for tweet %s failed: %v", m.TweetID, err)
+			}
+		}
+		return queue.Permanent(ErrRateLimited)
+	}
+}
+
+// SetUserAccess handles PUT /admin/users/{twitter_id}/access with a body of
+// {"access":"blocked"|"allowed","reason":"..."}.
+func (h MentionsHandler) SetUserAccess(w http.ResponseWriter, r *http.Request) {
+	if !h.adminAuthorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.Limiter == nil || h.Limiter.Lists == nil {
+		http.Error(w, "rate limiting disabled", http.StatusNotFound)
+		return
+	}
+
+	var in struct {
+		Access ratelimit.Access `json:"access"`
+		Reason string           `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil || (in.Access != ratelimit.AccessBlocked && in.Access != ratelimit.AccessAllowed) {
+		http.Error(w, `access must be "blocked" or "allowed"`, http.StatusBadRequest)
+		return
+	}
+	entry := ratelimit.ListEntry{
+		TwitterID: chi.URLParam(r, "twitter_id"),
+		Access:    in.Access,
+		Reason:    in.Reason,
+		UpdatedAt: time.Now(),
+	}
+	if err := h.Limiter.Lists.Set(r.Context(), entry); err != nil {
+		log.Printf("set user access failed: %v", err)
+		http.Error(w, "failed to save access", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("admin set user %s to %s", entry.TwitterID, entry.Access)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(entry)
+}
+
+// ClearUserAccess handles DELETE /admin/users/{twitter_id}/access, taking the
+// user off both lists.
+func (h MentionsHandler) ClearUserAccess(w http.ResponseWriter, r *http.Request) {
+	if !h.adminAuthorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.Limiter == nil || h.Limiter.Lists == nil {
+		http.Error(w, "rate limiting disabled", http.StatusNotFound)
+		return
+	}
+	if err := h.Limiter.Lists.Delete(r.Context(), chi.URLParam(r, "twitter_id")); err != nil {
+		log.Printf("clear user access failed: %v", err)
+		http.Error(w, "failed to clear access", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
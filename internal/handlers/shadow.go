@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"cg-mentions-bot/internal/shadow"
+)
+
+// ShadowReply returns a reply poster that records would-be replies instead of
+// posting them.
+func ShadowReply(rec *shadow.Recorder) func(ctx context.Context, in ReplyIn) error {
+	return func(ctx context.Context, in ReplyIn) error {
+		_, err := rec.Intercept(ctx, in.InReplyTo, "", "twitter.post_reply", map[string]any{
+			"in_reply_to_tweet_id": in.InReplyTo,
+			"text":                 in.Text,
+		})
+		if err != nil {
+			log.Printf("shadow: failed to record reply to %s: %v", in.InReplyTo, err)
+		}
+		return nil
+	}
+}
+
+// ShadowWallet passes read-only wallet calls through and simulates the ones that
+// move funds or create wallets. create_wallet returns the existing wallet when
+// there is one.
+type ShadowWallet struct {
+	Tools    WalletTools
+	Recorder *shadow.Recorder
+}
+
+func (w ShadowWallet) Call(ctx context.Context, tool string, args map[string]any) (string, error) {
+	if !shadow.Intercepts(tool) {
+		return w.Tools.Call(ctx, tool, args)
+	}
+	if tool == "create_wallet" {
+		if addr, err := w.Tools.Call(ctx, "read_wallet", args); err == nil {
+			return addr, nil
+		}
+	}
+	out, err := w.Recorder.Intercept(ctx, "", fmt.Sprint(args["twitter_id"]), tool, args)
+	if err != nil {
+		log.Printf("shadow: failed to record %s: %v", tool, err)
+	}
+	return out, nil
+}
+
+// ShadowReplies handles GET /shadow/replies and lists intercepted calls, newest
+// first. Optional query parameters: tweet_id, tool and limit.
+func (h MentionsHandler) ShadowReplies(w http.ResponseWriter, r *http.Request) {
+	if !h.adminAuthorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.Shadow == nil {
+		http.Error(w, "shadow mode disabled", http.StatusNotFound)
+		return
+	}
+
+	q := r.URL.Query()
+	f := shadow.Filter{TweetID: q.Get("tweet_id"), Tool: q.Get("tool")}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		f.Limit = n
+	}
+	entries, err := h.Shadow.List(r.Context(), f)
+	if err != nil {
+		log.Printf("list shadow calls failed: %v", err)
+		http.Error(w, "failed to list shadow calls", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{"entries": entries})
+}
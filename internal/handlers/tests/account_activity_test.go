@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cg-mentions-bot/internal/conversation"
+	"cg-mentions-bot/internal/handlers"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sign(secret, msg string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(msg))
+	return "sha256=" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestAccountActivity(t *testing.T) {
+	const secret = "consumer-secret"
+
+	t.Run("CRC challenge is answered with the HMAC of the token", func(t *testing.T) {
+		h := handlers.MentionsHandler{ConsumerSecret: secret}
+		rec := httptest.NewRecorder()
+		h.AccountActivityCRC(rec, httptest.NewRequest(http.MethodGet, "/webhooks/x?crc_token=abc", nil))
+
+		var out map[string]string
+		_ = json.Unmarshal(rec.Body.Bytes(), &out)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, sign(secret, "abc"), out["response_token"])
+	})
+
+	t.Run("Signed deliveries are mapped to mentions and unsigned ones rejected", func(t *testing.T) {
+		var questions []string
+		h := handlers.MentionsHandler{
+			ConsumerSecret: secret,
+			AgentRun: func(ctx context.Context, req types.AgentRequest) (types.AgentResult, error) {
+				questions = append(questions, req.ReplyTo+":"+req.TwitterID+":"+req.Question)
+				return types.AgentResult{Answer: "ok"}, nil
+			},
+		}
+		body := `{"for_user_id":"99","tweet_create_events":[
+			{"id_str":"5","text":"@bot what is BNB","user":{"id_str":"7","screen_name":"alice"},"entities":{"user_mentions":[{"id_str":"99","screen_name":"bot"}]}},
+			{"id_str":"6","text":"my own tweet","user":{"id_str":"99","screen_name":"bot"}},
+			{"id_str":"8","text":"unrelated","user":{"id_str":"7","screen_name":"alice"}}
+		]}`
+
+		bad := httptest.NewRequest(http.MethodPost, "/webhooks/x", strings.NewReader(body))
+		bad.Header.Set("x-twitter-webhooks-signature", sign("wrong", body))
+		rec := httptest.NewRecorder()
+		h.AccountActivity(rec, bad)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		good := httptest.NewRequest(http.MethodPost, "/webhooks/x", strings.NewReader(body))
+		good.Header.Set("x-twitter-webhooks-signature", sign(secret, body))
+		rec = httptest.NewRecorder()
+		h.AccountActivity(rec, good)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []string{"5:7:what is BNB"}, questions)
+	})
+	t.Run("A bot mention past the truncation of a long tweet is found in extended_tweet", func(t *testing.T) {
+		var asked []types.AgentRequest
+		h := handlers.MentionsHandler{
+			ConsumerSecret: secret,
+			AgentRun: func(ctx context.Context, req types.AgentRequest) (types.AgentResult, error) {
+				asked = append(asked, req)
+				return types.AgentResult{Answer: "ok"}, nil
+			},
+		}
+		long := strings.Repeat("gm ", 50) + "@bot what is BNB"
+		body := `{"for_user_id":"99","tweet_create_events":[
+			{"id_str":"9","text":"` + long[:137] + `…","truncated":true,"user":{"id_str":"7","screen_name":"alice"},"entities":{"user_mentions":[]},
+			 "extended_tweet":{"full_text":"` + long + `","entities":{"user_mentions":[{"id_str":"99","screen_name":"bot","indices":[150,154]}]}}}
+		]}`
+
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/x", strings.NewReader(body))
+		req.Header.Set("x-twitter-webhooks-signature", sign(secret, body))
+		rec := httptest.NewRecorder()
+		h.AccountActivity(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		if assert.Len(t, asked, 1) {
+			assert.Equal(t, "9", asked[0].ReplyTo)
+			assert.Contains(t, asked[0].Question, "what is BNB")
+		}
+	})
+	t.Run("A reply is filed under the root of its thread and gets the thread history", func(t *testing.T) {
+		x := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/tweets/11" {
+				http.NotFound(w, r)
+				return
+			}
+			assert.Equal(t, "conversation_id", r.URL.Query().Get("tweet.fields"))
+			_, _ = w.Write([]byte(`{"data":{"id":"11","conversation_id":"10"}}`))
+		}))
+		defer x.Close()
+		store := conversation.NewMemoryStore()
+		ctx := context.Background()
+		_ = store.Append(ctx, "10", types.Turn{TweetID: "10", Text: "gm, what should I buy?"})
+
+		var history [][]types.Turn
+		h := handlers.MentionsHandler{
+			ConsumerSecret: secret,
+			Conversations:  &conversation.Service{Store: store, Fetcher: &conversation.XFetcher{BaseURL: x.URL}},
+			AgentRun: func(ctx context.Context, req types.AgentRequest) (types.AgentResult, error) {
+				history = append(history, req.History)
+				return types.AgentResult{Answer: "ok"}, nil
+			},
+		}
+		deliver := func(body string) {
+			req := httptest.NewRequest(http.MethodPost, "/webhooks/x", strings.NewReader(body))
+			req.Header.Set("x-twitter-webhooks-signature", sign(secret, body))
+			rec := httptest.NewRecorder()
+			h.AccountActivity(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+
+		deliver(`{"for_user_id":"99","tweet_create_events":[
+			{"id_str":"12","text":"@bob @bot thoughts?","in_reply_to_status_id_str":"11","user":{"id_str":"7","screen_name":"alice"},
+			 "entities":{"user_mentions":[{"id_str":"8","screen_name":"bob"},{"id_str":"99","screen_name":"bot"}]}}]}`)
+		// The lookup fails for this parent, which then stands in for the root.
+		deliver(`{"for_user_id":"99","tweet_create_events":[
+			{"id_str":"14","text":"@bot and this?","in_reply_to_status_id_str":"13","user":{"id_str":"7","screen_name":"alice"},
+			 "entities":{"user_mentions":[{"id_str":"99","screen_name":"bot"}]}}]}`)
+
+		if assert.Len(t, history, 2) {
+			assert.Equal(t, []types.Turn{{TweetID: "10", Text: "gm, what should I buy?"}}, history[0])
+		}
+		root, _ := store.Recent(ctx, "10", 0)
+		if assert.Len(t, root, 3) {
+			assert.Equal(t, "12", root[1].TweetID)
+		}
+		parent, _ := store.Recent(ctx, "13", 0)
+		if assert.Len(t, parent, 2) {
+			assert.Equal(t, "14", parent[0].TweetID)
+		}
+	})
+}
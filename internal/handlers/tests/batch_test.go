@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cg-mentions-bot/internal/handlers"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMentionsBatch(t *testing.T) {
+	t.Run("Different authors run in parallel while one author's mentions stay in order", func(t *testing.T) {
+		var mu sync.Mutex
+		order := map[string][]string{}
+		var running, peak, aliceRunning int32
+		h := handlers.MentionsHandler{
+			Concurrency: 4,
+			AgentRun: func(ctx context.Context, req types.AgentRequest) (types.AgentResult, error) {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				if req.TwitterID == "1" {
+					assert.Equal(t, int32(1), atomic.AddInt32(&aliceRunning, 1), "same author ran concurrently")
+					defer atomic.AddInt32(&aliceRunning, -1)
+				}
+				time.Sleep(20 * time.Millisecond)
+				mu.Lock()
+				order[req.TwitterID] = append(order[req.TwitterID], req.ReplyTo)
+				mu.Unlock()
+				atomic.AddInt32(&running, -1)
+				return types.AgentResult{Answer: "ok"}, nil
+			},
+		}
+
+		body := `{"mentions":[
+			{"tweet_id":"1","text":"a","twitter_id":"1"},
+			{"tweet_id":"2","text":"b","twitter_id":"2"},
+			{"tweet_id":"3","text":"c","twitter_id":"1"},
+			{"tweet_id":"4","text":"d","twitter_id":"3"},
+			{"tweet_id":"5","text":"e","twitter_id":"1"}
+		]}`
+		out := post(h, body)
+
+		assert.Equal(t, []string{"1", "3", "5"}, order["1"])
+		assert.Greater(t, atomic.LoadInt32(&peak), int32(1))
+		results := out["results"].([]any)
+		if assert.Len(t, results, 5) {
+			assert.Equal(t, "1", results[0].(map[string]any)["tweet_id"])
+			assert.Equal(t, "5", results[4].(map[string]any)["tweet_id"])
+		}
+	})
+
+	t.Run("Each mention gets its own timeout", func(t *testing.T) {
+		h := handlers.MentionsHandler{
+			MentionTimeout: 10 * time.Millisecond,
+			AgentRun: func(ctx context.Context, req types.AgentRequest) (types.AgentResult, error) {
+				<-ctx.Done()
+				return types.AgentResult{}, ctx.Err()
+			},
+		}
+
+		out := post(h, `{"mentions":[{"tweet_id":"1","text":"a","twitter_id":"1"},{"tweet_id":"2","text":"b","twitter_id":"1"}]}`)
+
+		for _, r := range out["results"].([]any) {
+			assert.Equal(t, context.DeadlineExceeded.Error(), r.(map[string]any)["error"])
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"cg-mentions-bot/internal/handlers"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeWallet struct {
+	calls []string
+	fail  map[string]error
+}
+
+func (w *fakeWallet) Call(ctx context.Context, tool string, args map[string]any) (string, error) {
+	call := tool
+	switch tool {
+	case "create_wallet", "read_wallet":
+		call += ":" + fmt.Sprint(args["twitter_id"])
+	case "transfer_asset":
+		call += ":" + fmt.Sprint(args["chain_id"], ",", args["to_address"], ",", args["amount"])
+	}
+	w.calls = append(w.calls, call)
+	if err := w.fail[call]; err != nil {
+		return "", err
+	}
+	switch tool {
+	case "create_wallet", "read_wallet":
+		return fmt.Sprintf("0x%040s", args["twitter_id"]), nil
+	case "get_wallet_balance":
+		return "1250000000000000000", nil
+	case "transfer_asset":
+		return "0xtx" + fmt.Sprint(len(w.calls)), nil
+	}
+	return "", errors.New("unknown tool")
+}
+
+func commandHandler(w *fakeWallet, replies *[]string, agentRuns *int) handlers.MentionsHandler {
+	return handlers.MentionsHandler{
+		Wallet: w,
+		AgentRun: func(ctx context.Context, req types.AgentRequest) (types.AgentResult, error) {
+			*agentRuns++
+			return types.AgentResult{Answer: "agent"}, nil
+		},
+		Reply: func(ctx context.Context, in handlers.ReplyIn) error {
+			*replies = append(*replies, in.Text)
+			return nil
+		},
+	}
+}
+
+func mentionWith(text string, mentioned ...types.MentionedUser) types.Mention {
+	return types.Mention{TweetID: "1", AuthorID: "7", Text: text, Entities: &types.MentionEntities{Mentions: mentioned}}
+}
+
+func TestWalletCommands(t *testing.T) {
+	bob := types.MentionedUser{ID: "2", Username: "bob"}
+	carol := types.MentionedUser{ID: "3", Username: "carol"}
+
+	t.Run("A send command transfers directly without the agent", func(t *testing.T) {
+		w := &fakeWallet{}
+		var replies []string
+		var runs int
+		h := commandHandler(w, &replies, &runs)
+
+		err := h.Process(context.Background(), mentionWith("@bot send 0.01 BNB to @bob!", bob))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, runs)
+		assert.Equal(t, []string{
+			"create_wallet:7",
+			"create_wallet:2",
+			"transfer_asset:56,0x0000000000000000000000000000000000000002,10000000000000000",
+		}, w.calls)
+		assert.Equal(t, []string{"Sent 0.01 BNB on BNB Chain to @bob (tx 0xtx3)."}, replies)
+	})
+
+	t.Run("Split divides the amount and reports partial failures without retrying", func(t *testing.T) {
+		w := &fakeWallet{fail: map[string]error{
+			"transfer_asset:56,0x0000000000000000000000000000000000000003,50000000000000000": errors.New("insufficient funds"),
+		}}
+		var replies []string
+		var runs int
+		h := commandHandler(w, &replies, &runs)
+
+		err := h.Process(context.Background(), mentionWith("@bot split 0.1 bnb between @bob and @carol", bob, carol))
+
+		assert.ErrorContains(t, err, "insufficient funds")
+		assert.Equal(t, []string{"Sent 0.05 BNB each on BNB Chain to @bob (tx 0xtx3). Couldn't send to @carol."}, replies)
+	})
+
+	t.Run("Balance and address are answered from the wallet tools", func(t *testing.T) {
+		w := &fakeWallet{}
+		var replies []string
+		var runs int
+		h := commandHandler(w, &replies, &runs)
+
+		assert.NoError(t, h.Process(context.Background(), mentionWith("@bot what's my balance?")))
+		assert.NoError(t, h.Process(context.Background(), mentionWith("@bot my address")))
+
+		assert.Equal(t, []string{
+			"Your balance on BNB Chain: 1.25 BNB",
+			"Your wallet address: 0x0000000000000000000000000000000000000007",
+		}, replies)
+	})
+
+	t.Run("Invalid commands are explained and free-form text goes to the agent", func(t *testing.T) {
+		w := &fakeWallet{}
+		var replies []string
+		var runs int
+		h := commandHandler(w, &replies, &runs)
+
+		assert.NoError(t, h.Process(context.Background(), mentionWith("@bot send 1 ETH to @bob on bnb", bob)))
+		assert.NoError(t, h.Process(context.Background(), mentionWith("@bot send 1.5 USDT to @bob", bob)))
+		assert.NoError(t, h.Process(context.Background(), mentionWith("@bot what is the price of BNB?")))
+
+		assert.Equal(t, []string{"ETH is not the native token of BNB Chain."}, replies)
+		assert.Empty(t, w.calls)
+		assert.Equal(t, 2, runs)
+	})
+	t.Run("Chains the wallet does not serve are refused instead of mislabeled", func(t *testing.T) {
+		w := &fakeWallet{}
+		var replies []string
+		var runs int
+		h := commandHandler(w, &replies, &runs)
+
+		assert.NoError(t, h.Process(context.Background(), mentionWith("@bot balance on eth")))
+		assert.NoError(t, h.Process(context.Background(), mentionWith("@bot send 0.1 eth to @bob on base", bob)))
+
+		assert.Equal(t, []string{
+			"I can only use BNB Chain for now, not Ethereum.",
+			"I can only use BNB Chain for now, not Base.",
+		}, replies)
+		assert.Empty(t, w.calls)
+		assert.Equal(t, 0, runs)
+	})
+}
@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"cg-mentions-bot/internal/handlers"
+	"cg-mentions-bot/internal/intents"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var confirmCodeRe = regexp.MustCompile(`confirm ([A-Z0-9]{6})`)
+
+func TestConfirmations(t *testing.T) {
+	bob := types.MentionedUser{ID: "2", Username: "bob"}
+	carol := types.MentionedUser{ID: "3", Username: "carol"}
+	threshold, err := handlers.ParseNativeAmount("0.05")
+	require.NoError(t, err)
+
+	setup := func(window time.Duration) (handlers.MentionsHandler, *fakeWallet, *[]string) {
+		w := &fakeWallet{}
+		var replies []string
+		var runs int
+		h := commandHandler(w, &replies, &runs)
+		h.Intents = intents.NewMemoryStore()
+		h.ConfirmAbove = threshold
+		h.ConfirmWindow = window
+		return h, w, &replies
+	}
+	codeFrom := func(t *testing.T, reply string) string {
+		m := confirmCodeRe.FindStringSubmatch(reply)
+		require.NotNil(t, m, reply)
+		return m[1]
+	}
+
+	t.Run("Small sends go through without a confirmation", func(t *testing.T) {
+		h, w, replies := setup(0)
+
+		assert.NoError(t, h.Process(context.Background(), mentionWith("@bot send 0.05 bnb to @bob", bob)))
+		assert.Contains(t, w.calls, "transfer_asset:56,0x0000000000000000000000000000000000000002,50000000000000000")
+		assert.Contains(t, (*replies)[0], "Sent 0.05 BNB")
+	})
+
+	t.Run("A large send waits for the author to confirm its code", func(t *testing.T) {
+		h, w, replies := setup(0)
+		ctx := context.Background()
+
+		require.NoError(t, h.Process(ctx, mentionWith("@bot split 0.1 bnb between @bob and @carol", bob, carol)))
+		assert.Empty(t, w.calls)
+		require.Len(t, *replies, 1)
+		assert.Contains(t, (*replies)[0], "0.05 BNB each on BNB Chain to @bob, @carol")
+		assert.Contains(t, (*replies)[0], "within 10 minutes")
+
+		code := codeFrom(t, (*replies)[0])
+		confirm := types.Mention{TweetID: "2", AuthorID: "7", Text: "@bot confirm " + code}
+		require.NoError(t, h.Process(ctx, confirm))
+		assert.Equal(t, []string{
+			"create_wallet:7",
+			"create_wallet:2",
+			"transfer_asset:56,0x0000000000000000000000000000000000000002,50000000000000000",
+			"create_wallet:3",
+			"transfer_asset:56,0x0000000000000000000000000000000000000003,50000000000000000",
+		}, w.calls)
+		assert.Contains(t, (*replies)[1], "Sent 0.05 BNB each")
+
+		confirm.TweetID = "3"
+		require.NoError(t, h.Process(ctx, confirm))
+		assert.Equal(t, "That transfer was already confirmed.", (*replies)[2])
+		assert.Len(t, w.calls, 5)
+	})
+
+	t.Run("Another user cannot confirm someone else's code", func(t *testing.T) {
+		h, w, replies := setup(0)
+		ctx := context.Background()
+
+		require.NoError(t, h.Process(ctx, mentionWith("@bot send 1 bnb to @bob", bob)))
+		code := codeFrom(t, (*replies)[0])
+
+		require.NoError(t, h.Process(ctx, types.Mention{TweetID: "2", AuthorID: "8", Text: "@bot confirm " + code}))
+		assert.Empty(t, w.calls)
+		assert.Equal(t, "I couldn't find a pending transfer for that code.", (*replies)[1])
+	})
+
+	t.Run("Expired codes are rejected", func(t *testing.T) {
+		h, w, replies := setup(time.Nanosecond)
+		ctx := context.Background()
+
+		require.NoError(t, h.Process(ctx, mentionWith("@bot send 1 bnb to @bob", bob)))
+		code := codeFrom(t, (*replies)[0])
+		time.Sleep(time.Millisecond)
+
+		require.NoError(t, h.Process(ctx, types.Mention{TweetID: "2", AuthorID: "7", Text: "@bot confirm " + code}))
+		assert.Empty(t, w.calls)
+		assert.Contains(t, (*replies)[1], "expired")
+	})
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cg-mentions-bot/internal/conversation"
+	"cg-mentions-bot/internal/handlers"
+	"cg-mentions-bot/internal/memory"
+	"cg-mentions-bot/internal/traces"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForgetUser(t *testing.T) {
+	t.Run("Wiping a user removes their profile, tweets and traces", func(t *testing.T) {
+		ctx := context.Background()
+		mem := &memory.Service{Store: memory.NewMemoryStore()}
+		convs := &conversation.Service{Store: conversation.NewMemoryStore()}
+		store := traces.NewMemoryStore()
+		h := handlers.MentionsHandler{Memory: mem, Conversations: convs, Traces: store, AdminToken: "admin"}
+
+		mem.Remember(ctx, "7", "my balance?", "1 BNB")
+		convs.Record(ctx, types.Mention{TweetID: "10", ConversationID: "10", AuthorID: "7", Text: "@bot my balance?"}, "1 BNB")
+		convs.Record(ctx, types.Mention{TweetID: "11", ConversationID: "10", AuthorID: "8", Text: "@bot mine?"}, "2 BNB")
+		require.NoError(t, store.Save(ctx, traces.Trace{TweetID: "10", TwitterID: "7"}))
+		require.NoError(t, store.Save(ctx, traces.Trace{TweetID: "11", TwitterID: "8"}))
+
+		r := chi.NewRouter()
+		r.Delete("/users/{twitter_id}/memory", h.ForgetUser)
+		req := httptest.NewRequest(http.MethodDelete, "/users/7/memory", nil)
+		req.Header.Set("X-Admin-Token", "admin")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusNoContent, rr.Code)
+
+		p, _ := mem.Store.Get(ctx, "7")
+		assert.Nil(t, p)
+		turns, _ := convs.Store.Recent(ctx, "10", 0)
+		var authors []string
+		for _, turn := range turns {
+			authors = append(authors, turn.AuthorID)
+		}
+		assert.Equal(t, []string{"", "8", ""}, authors)
+		gone, _ := store.Get(ctx, "10")
+		kept, _ := store.Get(ctx, "11")
+		assert.Nil(t, gone)
+		assert.NotNil(t, kept)
+	})
+}
@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"cg-mentions-bot/internal/handlers"
+	"cg-mentions-bot/internal/ledger"
+	"cg-mentions-bot/internal/queue"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const payload = `{"count":1,"mentions":[{"tweet_id":"100","text":"what is my balance","twitter_id":"42"}]}`
+
+func post(h handlers.MentionsHandler, body string) map[string]any {
+	req := httptest.NewRequest(http.MethodPost, "/mentions", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.Handle(rec, req)
+	var out map[string]any
+	_ = json.Unmarshal(rec.Body.Bytes(), &out)
+	return out
+}
+
+func TestMentionsDedup(t *testing.T) {
+	t.Run("A replayed webhook returns the stored result without re-running the agent", func(t *testing.T) {
+		var runs int32
+		h := handlers.MentionsHandler{
+			Ledger: ledger.NewMemoryStore(0),
+			AgentRun: func(ctx context.Context, req types.AgentRequest) (types.AgentResult, error) {
+				atomic.AddInt32(&runs, 1)
+				return types.AgentResult{Answer: "0.5 BNB"}, nil
+			},
+		}
+
+		first := post(h, payload)
+		second := post(h, payload)
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+		assert.Equal(t, float64(1), first["processed"])
+		assert.Equal(t, float64(0), second["processed"])
+		res := second["results"].([]any)[0].(map[string]any)
+		assert.Equal(t, true, res["duplicate"])
+		assert.Equal(t, string(ledger.StatePosted), res["state"])
+		assert.Equal(t, "0.5 BNB", res["answer"])
+	})
+}
+
+func TestMentionsRetry(t *testing.T) {
+	mention := types.Mention{TweetID: "200", Text: "send it", AuthorID: "42"}
+
+	t.Run("A tweet left mid-answer by an earlier attempt is failed instead of run again", func(t *testing.T) {
+		var runs int32
+		store := ledger.NewMemoryStore(0)
+		h := handlers.MentionsHandler{
+			Ledger: store,
+			AgentRun: func(ctx context.Context, req types.AgentRequest) (types.AgentResult, error) {
+				atomic.AddInt32(&runs, 1)
+				return types.AgentResult{Answer: "sent"}, nil
+			},
+		}
+		_, _, err := store.Begin(context.Background(), mention)
+		require.NoError(t, err)
+		require.NoError(t, store.Mark(context.Background(), mention.TweetID, ledger.StateAnswering, "", ""))
+
+		err = h.Process(context.Background(), mention)
+
+		assert.ErrorIs(t, err, queue.ErrPermanent)
+		assert.Equal(t, int32(0), atomic.LoadInt32(&runs))
+		entry, _ := store.Get(context.Background(), mention.TweetID)
+		assert.Equal(t, ledger.StateFailed, entry.State)
+	})
+
+	t.Run("A posted tweet is not answered again", func(t *testing.T) {
+		var runs int32
+		store := ledger.NewMemoryStore(0)
+		h := handlers.MentionsHandler{
+			Ledger: store,
+			AgentRun: func(ctx context.Context, req types.AgentRequest) (types.AgentResult, error) {
+				atomic.AddInt32(&runs, 1)
+				return types.AgentResult{Answer: "sent", Posted: true}, nil
+			},
+		}
+		_, _, _ = store.Begin(context.Background(), mention)
+
+		assert.NoError(t, h.Process(context.Background(), mention))
+		assert.NoError(t, h.Process(context.Background(), mention))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+	})
+
+	t.Run("A failure after a transfer is not retried", func(t *testing.T) {
+		store := ledger.NewMemoryStore(0)
+		h := handlers.MentionsHandler{
+			Ledger: store,
+			AgentRun: func(ctx context.Context, req types.AgentRequest) (types.AgentResult, error) {
+				return types.AgentResult{TxHashes: []string{"0x01"}}, errors.New("post failed")
+			},
+		}
+		_, _, _ = store.Begin(context.Background(), mention)
+
+		assert.ErrorIs(t, h.Process(context.Background(), mention), queue.ErrPermanent)
+	})
+
+	t.Run("A failure after an attempted post or transfer is not retried, whatever its outcome", func(t *testing.T) {
+		for _, res := range []types.AgentResult{
+			{ToolsUsed: []string{"transfer_asset"}, SideEffects: true},
+			{},
+		} {
+			store := ledger.NewMemoryStore(0)
+			h := handlers.MentionsHandler{
+				Ledger: store,
+				AgentRun: func(ctx context.Context, req types.AgentRequest) (types.AgentResult, error) {
+					return res, errors.New("timed out")
+				},
+			}
+			_, _, _ = store.Begin(context.Background(), mention)
+
+			err := h.Process(context.Background(), mention)
+			assert.Equal(t, res.SideEffects, errors.Is(err, queue.ErrPermanent), "%+v", res)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cg-mentions-bot/internal/handlers"
+	"cg-mentions-bot/internal/ratelimit"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+)
+
+func TestMentionsRateLimit(t *testing.T) {
+	t.Run("Throttled mentions skip the agent and get one polite reply", func(t *testing.T) {
+		var runs int
+		var replies []handlers.ReplyIn
+		h := handlers.MentionsHandler{
+			Limiter:         &ratelimit.Limiter{PerUser: rate.Every(time.Hour), Burst: 1},
+			ThrottleMessage: "slow down",
+			AgentRun: func(ctx context.Context, req types.AgentRequest) (types.AgentResult, error) {
+				runs++
+				return types.AgentResult{Answer: "ok"}, nil
+			},
+			Reply: func(ctx context.Context, in handlers.ReplyIn) error {
+				replies = append(replies, in)
+				return nil
+			},
+		}
+
+		out := post(h, `{"mentions":[
+			{"tweet_id":"1","text":"a","twitter_id":"7"},
+			{"tweet_id":"2","text":"b","twitter_id":"7"},
+			{"tweet_id":"3","text":"c","twitter_id":"7"}
+		]}`)
+
+		assert.Equal(t, 1, runs)
+		assert.Equal(t, []handlers.ReplyIn{{InReplyTo: "2", Text: "slow down"}}, replies)
+		results := out["results"].([]any)
+		assert.Equal(t, handlers.ErrRateLimited.Error(), results[2].(map[string]any)["error"])
+	})
+	t.Run("Mentions over the global budget get no throttle reply", func(t *testing.T) {
+		var replies []handlers.ReplyIn
+		h := handlers.MentionsHandler{
+			Limiter: &ratelimit.Limiter{PerUser: rate.Every(time.Hour), Burst: 5, Global: rate.NewLimiter(rate.Every(time.Hour), 1)},
+			AgentRun: func(ctx context.Context, req types.AgentRequest) (types.AgentResult, error) {
+				return types.AgentResult{Answer: "ok"}, nil
+			},
+			Reply: func(ctx context.Context, in handlers.ReplyIn) error {
+				replies = append(replies, in)
+				return nil
+			},
+		}
+
+		out := post(h, `{"mentions":[
+			{"tweet_id":"1","text":"a","twitter_id":"7"},
+			{"tweet_id":"2","text":"b","twitter_id":"8"}
+		]}`)
+
+		assert.Empty(t, replies)
+		var limited int
+		for _, r := range out["results"].([]any) {
+			if r.(map[string]any)["error"] == handlers.ErrRateLimited.Error() {
+				limited++
+			}
+		}
+		assert.Equal(t, 1, limited)
+	})
+}
@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cg-mentions-bot/internal/handlers"
+	"cg-mentions-bot/internal/shadow"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShadowMode(t *testing.T) {
+	bob := types.MentionedUser{ID: "2", Username: "bob"}
+
+	t.Run("Wallet commands are simulated and the would-be reply is listed", func(t *testing.T) {
+		w := &fakeWallet{}
+		store := shadow.NewMemoryStore(0)
+		rec := &shadow.Recorder{Store: store}
+		h := handlers.MentionsHandler{
+			Wallet:     handlers.ShadowWallet{Tools: w, Recorder: rec},
+			Reply:      handlers.ShadowReply(rec),
+			Shadow:     store,
+			AdminToken: "admin",
+		}
+
+		require.NoError(t, h.Process(context.Background(), mentionWith("@bot send 0.01 bnb to @bob", bob)))
+		assert.Equal(t, []string{"read_wallet:7", "read_wallet:2"}, w.calls, "no wallet is created and no transfer reaches the wallet")
+
+		req := httptest.NewRequest(http.MethodGet, "/shadow/replies?tweet_id=1", nil)
+		req.Header.Set("X-Admin-Token", "admin")
+		rr := httptest.NewRecorder()
+		h.ShadowReplies(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var body struct {
+			Entries []shadow.Entry `json:"entries"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		require.Len(t, body.Entries, 1)
+		assert.Equal(t, "twitter.post_reply", body.Entries[0].Tool)
+		assert.Contains(t, body.Entries[0].Reply, "Sent 0.01 BNB on BNB Chain to @bob (tx 0x0000")
+
+		all, _ := store.List(context.Background(), shadow.Filter{Tool: "transfer_asset"})
+		require.Len(t, all, 1)
+		assert.Equal(t, "10000000000000000", all[0].Args["amount"])
+	})
+
+	t.Run("A wallet that does not exist yet is simulated, not created", func(t *testing.T) {
+		w := &fakeWallet{fail: map[string]error{"read_wallet:7": errors.New("user not found")}}
+		store := shadow.NewMemoryStore(0)
+		rec := &shadow.Recorder{Store: store}
+		var replies []string
+		h := handlers.MentionsHandler{
+			Wallet: handlers.ShadowWallet{Tools: w, Recorder: rec},
+			Reply: func(ctx context.Context, in handlers.ReplyIn) error {
+				replies = append(replies, in.Text)
+				return nil
+			},
+		}
+
+		require.NoError(t, h.Process(context.Background(), mentionWith("@bot my address")))
+
+		assert.Equal(t, []string{"read_wallet:7"}, w.calls)
+		created, _ := store.List(context.Background(), shadow.Filter{Tool: "create_wallet"})
+		require.Len(t, created, 1)
+		assert.Equal(t, "7", created[0].TwitterID)
+		require.Len(t, replies, 1)
+		assert.Contains(t, replies[0], shadow.Simulate("create_wallet", nil))
+	})
+
+	t.Run("The endpoint requires the admin token", func(t *testing.T) {
+		h := handlers.MentionsHandler{Shadow: shadow.NewMemoryStore(0), AdminToken: "admin"}
+		rr := httptest.NewRecorder()
+		h.ShadowReplies(rr, httptest.NewRequest(http.MethodGet, "/shadow/replies", nil))
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+}
@@ -0,0 +1,46 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cg-mentions-bot/internal/handlers"
+	"cg-mentions-bot/internal/traces"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceEndpoint(t *testing.T) {
+	store := traces.NewMemoryStore()
+	require.NoError(t, store.Save(context.Background(), traces.Trace{TweetID: "100", Answer: "gm", Steps: []traces.Step{{Tool: "read_wallet"}}}))
+	h := handlers.MentionsHandler{Traces: store, AdminToken: "admin"}
+	r := chi.NewRouter()
+	r.Get("/traces/{tweet_id}", h.Trace)
+
+	get := func(id, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/traces/"+id, nil)
+		req.Header.Set("X-Admin-Token", token)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("The trace of a tweet is returned to admins", func(t *testing.T) {
+		rr := get("100", "admin")
+		require.Equal(t, http.StatusOK, rr.Code)
+		var got traces.Trace
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+		assert.Equal(t, "gm", got.Answer)
+		assert.Equal(t, "read_wallet", got.Steps[0].Tool)
+	})
+
+	t.Run("Unknown tweets and missing tokens are rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("999", "admin").Code)
+		assert.Equal(t, http.StatusUnauthorized, get("100", "").Code)
+	})
+}
@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"cg-mentions-bot/internal/handlers"
+	"cg-mentions-bot/internal/queue"
+	"cg-mentions-bot/internal/types"
+	"cg-mentions-bot/internal/webhook"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestVerification(t *testing.T) {
+	verifier := func() *webhook.Verifier {
+		return &webhook.Verifier{Secrets: []string{"sign"}, Nonces: webhook.NewMemoryNonces()}
+	}
+	sign := func(req *http.Request, nonce string, body string) {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(webhook.HeaderTimestamp, ts)
+		req.Header.Set(webhook.HeaderNonce, nonce)
+		req.Header.Set(webhook.HeaderSignature, webhook.Sign("sign", ts, nonce, []byte(body)))
+	}
+
+	t.Run("With signing configured the shared secret alone is not enough", func(t *testing.T) {
+		h := handlers.MentionsHandler{
+			Secret:   "shared",
+			Verifier: verifier(),
+			AgentRun: func(ctx context.Context, req types.AgentRequest) (types.AgentResult, error) {
+				return types.AgentResult{Answer: "ok"}, nil
+			},
+		}
+		body := `{"mentions":[{"tweet_id":"1","text":"gm","twitter_id":"7"}]}`
+
+		unsigned := httptest.NewRequest(http.MethodPost, "/mentions", strings.NewReader(body))
+		unsigned.Header.Set("X-Webhook-Secret", "shared")
+		rr := httptest.NewRecorder()
+		h.Handle(rr, unsigned)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+		signed := httptest.NewRequest(http.MethodPost, "/mentions", strings.NewReader(body))
+		sign(signed, "n1", body)
+		rr = httptest.NewRecorder()
+		h.Handle(rr, signed)
+		assert.Equal(t, http.StatusAccepted, rr.Code)
+	})
+
+	t.Run("Job status needs the same signature as /mentions", func(t *testing.T) {
+		store := queue.NewMemoryStore()
+		jobs, err := store.Enqueue(context.Background(), []types.Mention{{TweetID: "1", AuthorID: "7"}})
+		require.NoError(t, err)
+		job := jobs[0]
+		h := handlers.MentionsHandler{Queue: store, Verifier: verifier()}
+		r := chi.NewRouter()
+		r.Get("/mentions/jobs/{id}", h.JobStatus)
+
+		get := func(signed bool) int {
+			req := httptest.NewRequest(http.MethodGet, "/mentions/jobs/"+job.ID, nil)
+			if signed {
+				sign(req, "n2", "")
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			return rr.Code
+		}
+		assert.Equal(t, http.StatusUnauthorized, get(false))
+		assert.Equal(t, http.StatusOK, get(true))
+	})
+}
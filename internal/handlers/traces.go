@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Trace handles GET /traces/{tweet_id} and returns what the agent did when it
+// answered the tweet: prompt, tool calls, parse errors, answer and token usage.
+func (h MentionsHandler) Trace(w http.ResponseWriter, r *http.Request) {
+	if !h.adminAuthorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.Traces == nil {
+		http.Error(w, "traces disabled", http.StatusNotFound)
+		return
+	}
+
+	tweetID := chi.URLParam(r, "tweet_id")
+	t, err := h.Traces.Get(r.Context(), tweetID)
+	if err != nil {
+		log.Printf("read trace failed: %v", err)
+		http.Error(w, "failed to read trace", http.StatusInternalServerError)
+		return
+	}
+	if t == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(t)
+}
@@ -8,7 +8,8 @@ import (
 	"github.com/go-chi/chi/v5"
 )
 
-// NewServer creates a simple HTTP server with health and mentions endpoints.
+// NewServer creates a simple HTTP server with health, mentions, job status and X
+// Account Activity webhook endpoints.
 func NewServer(port string, h handlers.MentionsHandler) *http.Server {
 	r := chi.NewRouter()
 
@@ -19,6 +20,16 @@ func NewServer(port string, h handlers.MentionsHandler) *http.Server {
 	})
 
 	r.Post("/mentions", h.Handle)
+	r.Get("/mentions/jobs/{id}", h.JobStatus)
+	r.Get("/webhooks/x", h.AccountActivityCRC)
+	r.Post("/webhooks/x", h.AccountActivity)
+	r.Post("/admin/mentions/{tweet_id}/reprocess", h.Reprocess)
+	r.Put("/admin/users/{twitter_id}/access", h.SetUserAccess)
+	r.Delete("/admin/users/{twitter_id}/access", h.ClearUserAccess)
+	r.Get("/users/{twitter_id}/memory", h.UserMemory)
+	r.Delete("/users/{twitter_id}/memory", h.ForgetUser)
+	r.Get("/shadow/replies", h.ShadowReplies)
+	r.Get("/traces/{tweet_id}", h.Trace)
 
 	return &http.Server{
 		Addr:    ":" + port,
@@ -0,0 +1,91 @@
+package intents
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"time"
+)
+
+// Status is where a pending intent is in the confirmation flow.
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusConfirmed Status = "confirmed"
+	StatusExpired   Status = "expired"
+)
+
+// DefaultWindow is how long an intent waits for its confirmation.
+const DefaultWindow = 10 * time.Minute
+
+var (
+	ErrNotFound    = errors.New("no pending intent for this code")
+	ErrWrongAuthor = errors.New("intent belongs to another user")
+	ErrExpired     = errors.New("intent expired")
+	ErrUsed        = errors.New("intent already confirmed")
+)
+
+// Intent is a value-moving command waiting for its author to confirm it.
+type Intent struct {
+	Code     string `bson:"_id" json:"code"`
+	AuthorID string `bson:"author_id" json:"author_id"`
+	TweetID  string `bson:"tweet_id" json:"tweet_id"`
+	// What to execute once confirmed.
+	ChainID      string            `bson:"chain_id" json:"chain_id"`
+	ChainName    string            `bson:"chain_name" json:"chain_name"`
+	Native       string            `bson:"native" json:"native"`
+	AmountWei    string            `bson:"amount_wei" json:"amount_wei"` // per recipient
+	Display      string            `bson:"display" json:"display"`
+	Recipients   []string          `bson:"recipients" json:"recipients"`
+	RecipientIDs map[string]string `bson:"recipient_ids,omitempty" json:"recipient_ids,omitempty"`
+	Summary      string            `bson:"summary" json:"summary"`
+
+	Status      Status    `bson:"status" json:"status"`
+	CreatedAt   time.Time `bson:"created_at" json:"created_at"`
+	ExpiresAt   time.Time `bson:"expires_at" json:"expires_at"`
+	ConfirmedBy string    `bson:"confirmed_by,omitempty" json:"confirmed_by,omitempty"`
+	ConfirmedAt time.Time `bson:"confirmed_at,omitempty" json:"confirmed_at,omitempty"`
+}
+
+// Store persists intents keyed by confirmation code.
+type Store interface {
+	Create(ctx context.Context, in Intent) error
+	// Confirm atomically moves a pending intent to confirmed if authorID created
+	// it and it has not expired. Otherwise it returns ErrNotFound, ErrWrongAuthor,
+	// ErrExpired or ErrUsed.
+	Confirm(ctx context.Context, code string, authorID string, now time.Time) (*Intent, error)
+}
+
+// codeAlphabet leaves out characters that are easy to misread (0/O, 1/I/L).
+const codeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// NewCode returns a random six-character confirmation code.
+func NewCode() (string, error) {
+	b := make([]byte, 6)
+	max := big.NewInt(int64(len(codeAlphabet)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = codeAlphabet[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// classify explains why in could not be confirmed by authorID at now.
+func classify(in *Intent, authorID string, now time.Time) error {
+	switch {
+	case in == nil:
+		return ErrNotFound
+	case in.AuthorID != authorID:
+		return ErrWrongAuthor
+	case in.Status == StatusConfirmed:
+		return ErrUsed
+	case in.Status == StatusExpired || !now.Before(in.ExpiresAt):
+		return ErrExpired
+	}
+	return nil
+}
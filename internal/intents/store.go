@@ -0,0 +1,126 @@
+package intents
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"cg-mentions-bot/internal/utils/db"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// retention keeps confirmed and expired intents around for auditing before the
+// TTL index removes them.
+const retention = 7 * 24 * time.Hour
+
+// MemoryStore keeps intents in process.
+type MemoryStore struct {
+	mu      sync.Mutex
+	intents map[string]Intent
+}
+
+// NewMemoryStore returns an empty in-memory intent store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{intents: make(map[string]Intent)}
+}
+
+func (s *MemoryStore) Create(ctx context.Context, in Intent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.intents[in.Code]; ok {
+		return errors.New("duplicate intent code")
+	}
+	s.intents[in.Code] = in
+	return nil
+}
+
+func (s *MemoryStore) Confirm(ctx context.Context, code string, authorID string, now time.Time) (*Intent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	in, ok := s.intents[code]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := classify(&in, authorID, now); err != nil {
+		if errors.Is(err, ErrExpired) {
+			in.Status = StatusExpired
+			s.intents[code] = in
+		}
+		return &in, err
+	}
+	in.Status = StatusConfirmed
+	in.ConfirmedBy = authorID
+	in.ConfirmedAt = now
+	s.intents[code] = in
+	return &in, nil
+}
+
+// MongoStore persists intents in xreplyagent.pending_intents.
+type MongoStore struct {
+	coll *mongo.Collection
+}
+
+// NewMongoStore returns a Mongo-backed intent store.
+func NewMongoStore(client *mongo.Client) (*MongoStore, error) {
+	mg := db.MongoDB{
+		Database:   "xreplyagent",
+		Collection: "pending_intents",
+	}
+	coll := client.Database(mg.Database).Collection(mg.Collection)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(retention.Seconds())),
+	}); err != nil {
+		return nil, err
+	}
+	return &MongoStore{coll: coll}, nil
+}
+
+func (s *MongoStore) Create(ctx context.Context, in Intent) error {
+	_, err := s.coll.InsertOne(ctx, in)
+	return err
+}
+
+func (s *MongoStore) Confirm(ctx context.Context, code string, authorID string, now time.Time) (*Intent, error) {
+	filter := bson.M{
+		"_id":        code,
+		"author_id":  authorID,
+		"status":     StatusPending,
+		"expires_at": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"status": StatusConfirmed, "confirmed_by": authorID, "confirmed_at": now}}
+	var in Intent
+	err := s.coll.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&in)
+	if err == nil {
+		return &in, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+
+	// Not confirmable; load it to say why.
+	var existing Intent
+	if err := s.coll.FindOne(ctx, bson.M{"_id": code}).Decode(&existing); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	cerr := classify(&existing, authorID, now)
+	if errors.Is(cerr, ErrExpired) && existing.Status == StatusPending {
+		_, _ = s.coll.UpdateOne(ctx, bson.M{"_id": code, "status": StatusPending}, bson.M{"$set": bson.M{"status": StatusExpired}})
+		existing.Status = StatusExpired
+	}
+	if cerr == nil {
+		// Raced with another confirmation.
+		cerr = ErrUsed
+	}
+	return &existing, cerr
+}
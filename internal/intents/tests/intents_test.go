@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cg-mentions-bot/internal/intents"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	pending := func(code string) intents.Intent {
+		return intents.Intent{Code: code, AuthorID: "7", Status: intents.StatusPending, CreatedAt: now, ExpiresAt: now.Add(time.Minute)}
+	}
+
+	t.Run("The author confirms a pending intent exactly once", func(t *testing.T) {
+		s := intents.NewMemoryStore()
+		require.NoError(t, s.Create(ctx, pending("ABC234")))
+
+		in, err := s.Confirm(ctx, "ABC234", "7", now)
+		require.NoError(t, err)
+		assert.Equal(t, intents.StatusConfirmed, in.Status)
+		assert.Equal(t, "7", in.ConfirmedBy)
+
+		_, err = s.Confirm(ctx, "ABC234", "7", now)
+		assert.ErrorIs(t, err, intents.ErrUsed)
+	})
+
+	t.Run("Other users, unknown codes and late confirmations are rejected", func(t *testing.T) {
+		s := intents.NewMemoryStore()
+		require.NoError(t, s.Create(ctx, pending("ABC234")))
+
+		_, err := s.Confirm(ctx, "ABC234", "8", now)
+		assert.ErrorIs(t, err, intents.ErrWrongAuthor)
+		_, err = s.Confirm(ctx, "ZZZ999", "7", now)
+		assert.ErrorIs(t, err, intents.ErrNotFound)
+		_, err = s.Confirm(ctx, "ABC234", "7", now.Add(2*time.Minute))
+		assert.ErrorIs(t, err, intents.ErrExpired)
+		_, err = s.Confirm(ctx, "ABC234", "7", now)
+		assert.ErrorIs(t, err, intents.ErrExpired, "an expired intent stays expired")
+	})
+
+	t.Run("Codes are six unambiguous characters", func(t *testing.T) {
+		code, err := intents.NewCode()
+		require.NoError(t, err)
+		assert.Regexp(t, `^[A-HJKMNP-Z2-9]{6}$`, code)
+	})
+}
@@ -0,0 +1,59 @@
+package ledger
+
+import (
+	"context"
+	"time"
+
+	"cg-mentions-bot/internal/types"
+)
+
+// State is where a mention is in its processing lifecycle.
+type State string
+
+const (
+	StateReceived  State = "received"
+	StateAnswering State = "answering"
+	StatePosted    State = "posted"
+	StateFailed    State = "failed"
+)
+
+// Entry records what happened to one tweet so webhook replays can be answered
+// from the ledger instead of re-running the agent.
+type Entry struct {
+	TweetID   string        `bson:"_id" json:"tweet_id"`
+	Mention   types.Mention `bson:"mention" json:"mention"`
+	State     State         `bson:"state" json:"state"`
+	Answer    string        `bson:"answer,omitempty" json:"answer,omitempty"`
+	Error     string        `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt time.Time     `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time     `bson:"updated_at" json:"updated_at"`
+	ExpiresAt time.Time     `bson:"expires_at" json:"expires_at"`
+}
+
+// Store is a dedup ledger keyed by tweet id.
+type Store interface {
+	// Begin records m as received. If a live entry already exists for the tweet it is
+	// returned with fresh=false and nothing is written.
+	Begin(ctx context.Context, m types.Mention) (entry *Entry, fresh bool, err error)
+	// Mark moves a tweet to state, storing the answer or error message.
+	Mark(ctx context.Context, tweetID string, state State, answer string, errMsg string) error
+	// Get returns the live entry for a tweet, or nil.
+	Get(ctx context.Context, tweetID string) (*Entry, error)
+	// Delete forgets a tweet so it can be processed again.
+	Delete(ctx context.Context, tweetID string) error
+}
+
+// DefaultTTL is how long a tweet is remembered when no TTL is configured.
+const DefaultTTL = 72 * time.Hour
+
+func newEntry(m types.Mention, ttl time.Duration) Entry {
+	now := time.Now()
+	return Entry{
+		TweetID:   m.TweetID,
+		Mention:   m,
+		State:     StateReceived,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+}
@@ -0,0 +1,71 @@
+package ledger
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"cg-mentions-bot/internal/types"
+)
+
+// MemoryStore is an in-process ledger; entries do not survive a restart.
+type MemoryStore struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+// NewMemoryStore creates an in-memory ledger that forgets tweets after ttl.
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &MemoryStore{ttl: ttl, entries: make(map[string]Entry)}
+}
+
+func (s *MemoryStore) Begin(ctx context.Context, m types.Mention) (*Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[m.TweetID]; ok && e.ExpiresAt.After(time.Now()) {
+		return &e, false, nil
+	}
+	e := newEntry(m, s.ttl)
+	s.entries[m.TweetID] = e
+	return &e, true, nil
+}
+
+func (s *MemoryStore) Mark(ctx context.Context, tweetID string, state State, answer string, errMsg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[tweetID]
+	if !ok {
+		e = newEntry(types.Mention{TweetID: tweetID}, s.ttl)
+	}
+	e.State = state
+	e.Answer = answer
+	e.Error = errMsg
+	e.UpdatedAt = time.Now()
+	s.entries[tweetID] = e
+	return nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, tweetID string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[tweetID]
+	if !ok || !e.ExpiresAt.After(time.Now()) {
+		return nil, nil
+	}
+	return &e, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, tweetID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, tweetID)
+	return nil
+}
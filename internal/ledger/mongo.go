@@ -0,0 +1,92 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cg-mentions-bot/internal/types"
+	"cg-mentions-bot/internal/utils/db"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MongoStore is a ledger persisted in xreplyagent.mention_ledger. Expired entries
+// are removed by a TTL index and ignored until then.
+type MongoStore struct {
+	ttl  time.Duration
+	coll *mongo.Collection
+}
+
+// NewMongoStore returns a Mongo ledger that forgets tweets after ttl.
+func NewMongoStore(client *mongo.Client, ttl time.Duration) (*MongoStore, error) {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	mg := db.MongoDB{
+		Database:   "xreplyagent",
+		Collection: "mention_ledger",
+	}
+	coll := client.Database(mg.Database).Collection(mg.Collection)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}); err != nil {
+		return nil, err
+	}
+	return &MongoStore{ttl: ttl, coll: coll}, nil
+}
+
+func (s *MongoStore) Begin(ctx context.Context, m types.Mention) (*Entry, bool, error) {
+	e := newEntry(m, s.ttl)
+	// Insert when absent, or take over an entry that has expired but not yet been reaped.
+	filter := bson.M{"_id": m.TweetID, "expires_at": bson.M{"$lte": e.CreatedAt}}
+	res, err := s.coll.ReplaceOne(ctx, filter, e, options.Replace().SetUpsert(true))
+	if err == nil && (res.UpsertedCount > 0 || res.ModifiedCount > 0) {
+		return &e, true, nil
+	}
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return nil, false, err
+	}
+
+	existing, err := s.Get(ctx, m.TweetID)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing == nil {
+		return nil, false, errors.New("ledger entry vanished during begin")
+	}
+	return existing, false, nil
+}
+
+func (s *MongoStore) Mark(ctx context.Context, tweetID string, state State, answer string, errMsg string) error {
+	_, err := s.coll.UpdateOne(ctx, bson.M{"_id": tweetID}, bson.M{"$set": bson.M{
+		"state":      state,
+		"answer":     answer,
+		"error":      errMsg,
+		"updated_at": time.Now(),
+	}})
+	return err
+}
+
+func (s *MongoStore) Get(ctx context.Context, tweetID string) (*Entry, error) {
+	var e Entry
+	err := s.coll.FindOne(ctx, bson.M{"_id": tweetID, "expires_at": bson.M{"$gt": time.Now()}}).Decode(&e)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (s *MongoStore) Delete(ctx context.Context, tweetID string) error {
+	_, err := s.coll.DeleteOne(ctx, bson.M{"_id": tweetID})
+	return err
+}
@@ -0,0 +1,184 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Profile is the compact, summarized memory the bot keeps about one user.
+type Profile struct {
+	TwitterID       string         `bson:"_id" json:"twitter_id"`
+	RecentQuestions []string       `bson:"recent_questions" json:"recent_questions"`
+	LastAddresses   []string       `bson:"last_addresses" json:"last_addresses"`
+	PreferredChain  string         `bson:"preferred_chain,omitempty" json:"preferred_chain,omitempty"`
+	ChainCounts     map[string]int `bson:"chain_counts,omitempty" json:"chain_counts,omitempty"`
+	Interactions    int            `bson:"interactions" json:"interactions"`
+	UpdatedAt       time.Time      `bson:"updated_at" json:"updated_at"`
+}
+
+// Store persists profiles keyed by twitter id.
+type Store interface {
+	// Get returns the profile for a user, or nil if none is stored.
+	Get(ctx context.Context, twitterID string) (*Profile, error)
+	Save(ctx context.Context, p Profile) error
+	Delete(ctx context.Context, twitterID string) error
+}
+
+const (
+	maxQuestions   = 5
+	maxAddresses   = 3
+	maxQuestionLen = 160
+)
+
+var (
+	evmAddressRe    = regexp.MustCompile(`\b0x[0-9a-fA-F]{40}\b`)
+	solanaAddressRe = regexp.MustCompile(`\b[1-9A-HJ-NP-Za-km-z]{32,44}\b`)
+	chainKeywords   = map[string][]string{
+		"bnb":      {"bnb", "bsc", "binance"},
+		"solana":   {"solana", "sol", "spl"},
+		"ethereum": {"ethereum", "eth", "erc20"},
+		"base":     {"base"},
+	}
+)
+
+// Service turns interactions into profiles and renders them for the prompt.
+type Service struct {
+	Store Store
+}
+
+// Remember folds one answered question into the user's profile.
+func (s *Service) Remember(ctx context.Context, twitterID string, question string, answer string) {
+	if strings.TrimSpace(twitterID) == "" {
+		return
+	}
+	p, err := s.Store.Get(ctx, twitterID)
+	if err != nil {
+		log.Printf("memory: load %s failed: %v", twitterID, err)
+		return
+	}
+	if p == nil {
+		p = &Profile{TwitterID: twitterID}
+	}
+
+	if q := strings.TrimSpace(question); q != "" {
+		if r := []rune(q); len(r) > maxQuestionLen {
+			q = string(r[:maxQuestionLen]) + "…"
+		}
+		p.RecentQuestions = appendBounded(p.RecentQuestions, maxQuestions, q)
+	}
+	for _, addr := range extractAddresses(question + "\n" + answer) {
+		p.LastAddresses = appendBounded(removeValue(p.LastAddresses, addr), maxAddresses, addr)
+	}
+	if p.ChainCounts == nil {
+		p.ChainCounts = make(map[string]int)
+	}
+	for _, chain := range detectChains(question) {
+		p.ChainCounts[chain]++
+	}
+	p.PreferredChain = topChain(p.ChainCounts)
+	p.Interactions++
+	p.UpdatedAt = time.Now()
+
+	if err := s.Store.Save(ctx, *p); err != nil {
+		log.Printf("memory: save %s failed: %v", twitterID, err)
+	}
+}
+
+// Block renders the user's profile as a few prompt lines, or "" when there is nothing to say.
+func (s *Service) Block(ctx context.Context, twitterID string) string {
+	p, err := s.Store.Get(ctx, twitterID)
+	if err != nil {
+		log.Printf("memory: load %s failed: %v", twitterID, err)
+		return ""
+	}
+	if p == nil || p.Interactions == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("What you remember about this user (%d earlier interaction(s)):\n", p.Interactions))
+	if p.PreferredChain != "" {
+		b.WriteString("- usually asks about: " + p.PreferredChain + "\n")
+	}
+	if len(p.LastAddresses) > 0 {
+		b.WriteString("- addresses used recently: " + strings.Join(p.LastAddresses, ", ") + "\n")
+	}
+	if len(p.RecentQuestions) > 0 {
+		b.WriteString("- recent questions: " + strings.Join(p.RecentQuestions, " | ") + "\n")
+	}
+	return b.String()
+}
+
+func appendBounded(list []string, limit int, v string) []string {
+	list = append(list, v)
+	if len(list) > limit {
+		list = list[len(list)-limit:]
+	}
+	return list
+}
+
+func removeValue(list []string, v string) []string {
+	out := list[:0:0]
+	for _, x := range list {
+		if x != v {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+// extractAddresses finds EVM and Solana-looking addresses in order of appearance.
+func extractAddresses(s string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, a := range evmAddressRe.FindAllString(s, -1) {
+		if !seen[a] {
+			seen[a] = true
+			out = append(out, a)
+		}
+	}
+	for _, a := range solanaAddressRe.FindAllString(s, -1) {
+		// Base58 never contains 0, so 0x… hashes are not matched here; skip plain words.
+		if !seen[a] && strings.ContainsAny(a, "123456789") {
+			seen[a] = true
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+func detectChains(s string) []string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+	found := map[string]bool{}
+	for _, w := range words {
+		for chain, kws := range chainKeywords {
+			for _, kw := range kws {
+				if w == kw {
+					found[chain] = true
+				}
+			}
+		}
+	}
+	out := make([]string, 0, len(found))
+	for c := range found {
+		out = append(out, c)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func topChain(counts map[string]int) string {
+	best, bestN := "", 0
+	for chain, n := range counts {
+		if n > bestN || (n == bestN && chain < best) {
+			best, bestN = chain, n
+		}
+	}
+	return best
+}
@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"cg-mentions-bot/internal/utils/db"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MemoryStore keeps profiles in process.
+type MemoryStore struct {
+	mu       sync.Mutex
+	profiles map[string]Profile
+}
+
+// NewMemoryStore returns an empty in-memory profile store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{profiles: make(map[string]Profile)}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, twitterID string) (*Profile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.profiles[twitterID]
+	if !ok {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+func (s *MemoryStore) Save(ctx context.Context, p Profile) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profiles[p.TwitterID] = p
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, twitterID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.profiles, twitterID)
+	return nil
+}
+
+// MongoStore persists profiles in xreplyagent.user_memory.
+type MongoStore struct {
+	coll *mongo.Collection
+}
+
+// NewMongoStore returns a Mongo-backed profile store.
+func NewMongoStore(client *mongo.Client) *MongoStore {
+	mg := db.MongoDB{
+		Database:   "xreplyagent",
+		Collection: "user_memory",
+	}
+	return &MongoStore{coll: client.Database(mg.Database).Collection(mg.Collection)}
+}
+
+func (s *MongoStore) Get(ctx context.Context, twitterID string) (*Profile, error) {
+	var p Profile
+	if err := s.coll.FindOne(ctx, bson.M{"_id": twitterID}).Decode(&p); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *MongoStore) Save(ctx context.Context, p Profile) error {
+	_, err := s.coll.ReplaceOne(ctx, bson.M{"_id": p.TwitterID}, p, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s *MongoStore) Delete(ctx context.Context, twitterID string) error {
+	_, err := s.coll.DeleteOne(ctx, bson.M{"_id": twitterID})
+	return err
+}
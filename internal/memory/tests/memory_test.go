@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"cg-mentions-bot/internal/memory"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService(t *testing.T) {
+	t.Run("Questions, addresses and the preferred chain are remembered per user", func(t *testing.T) {
+		svc := &memory.Service{Store: memory.NewMemoryStore()}
+		ctx := context.Background()
+
+		svc.Remember(ctx, "42", "send 0.1 bnb to 0x1111111111111111111111111111111111111111", "Sent on BSC")
+		svc.Remember(ctx, "42", "what is my bsc balance", "1.2 BNB")
+		svc.Remember(ctx, "42", "price of eth", "3000 USD")
+
+		p, err := svc.Store.Get(ctx, "42")
+		if assert.NoError(t, err) && assert.NotNil(t, p) {
+			assert.Equal(t, 3, p.Interactions)
+			assert.Equal(t, "bnb", p.PreferredChain)
+			assert.Equal(t, []string{"0x1111111111111111111111111111111111111111"}, p.LastAddresses)
+			assert.Len(t, p.RecentQuestions, 3)
+		}
+		block := svc.Block(ctx, "42")
+		assert.Contains(t, block, "usually asks about: bnb")
+		assert.Contains(t, block, "0x1111111111111111111111111111111111111111")
+		assert.Empty(t, svc.Block(ctx, "7"))
+	})
+
+	t.Run("Only the most recent questions are kept", func(t *testing.T) {
+		svc := &memory.Service{Store: memory.NewMemoryStore()}
+		ctx := context.Background()
+		for _, q := range []string{"q1", "q2", "q3", "q4", "q5", "q6"} {
+			svc.Remember(ctx, "1", q, "")
+		}
+
+		p, _ := svc.Store.Get(ctx, "1")
+		assert.Equal(t, []string{"q2", "q3", "q4", "q5", "q6"}, p.RecentQuestions)
+	})
+	t.Run("Long questions are shortened without splitting a character", func(t *testing.T) {
+		svc := &memory.Service{Store: memory.NewMemoryStore()}
+		ctx := context.Background()
+		svc.Remember(ctx, "1", "a"+strings.Repeat("é", 200), "")
+
+		p, _ := svc.Store.Get(ctx, "1")
+		q := p.RecentQuestions[0]
+		assert.True(t, utf8.ValidString(q))
+		assert.Equal(t, "a"+strings.Repeat("é", 159)+"…", q)
+	})
+}
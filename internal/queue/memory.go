@@ -0,0 +1,120 @@
+package queue
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"cg-mentions-bot/internal/types"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// MemoryStore is an in-process Store. Jobs are lost on restart, so it is meant
+// for local runs and tests; use MongoStore in production.
+type MemoryStore struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// NewMemoryStore creates an empty in-memory job store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: make(map[string]*Job)}
+}
+
+func (s *MemoryStore) Enqueue(ctx context.Context, mentions []types.Mention) ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	out := make([]Job, 0, len(mentions))
+	for _, m := range mentions {
+		j := Job{
+			ID:        bson.NewObjectID().Hex(),
+			Mention:   m,
+			Status:    StatusQueued,
+			RunAt:     now,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		s.jobs[j.ID] = &j
+		out = append(out, j)
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) Claim(ctx context.Context, lease time.Duration) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Only the oldest unfinished job of each author may run.
+	first := make(map[string]string)
+	for _, j := range s.jobs {
+		if a := j.Mention.AuthorID; a != "" && (j.Status == StatusQueued || j.Status == StatusRunning) {
+			if id, ok := first[a]; !ok || j.ID < id {
+				first[a] = j.ID
+			}
+		}
+	}
+
+	now := time.Now()
+	ready := make([]*Job, 0)
+	for _, j := range s.jobs {
+		if a := j.Mention.AuthorID; a != "" && first[a] != j.ID {
+			continue
+		}
+		if (j.Status == StatusQueued && !j.RunAt.After(now)) ||
+			(j.Status == StatusRunning && j.LockedUntil.Before(now)) {
+			ready = append(ready, j)
+		}
+	}
+	if len(ready) == 0 {
+		return nil, nil
+	}
+	sort.Slice(ready, func(a, b int) bool {
+		if ready[a].RunAt.Equal(ready[b].RunAt) {
+			return ready[a].ID < ready[b].ID
+		}
+		return ready[a].RunAt.Before(ready[b].RunAt)
+	})
+
+	j := ready[0]
+	j.Status = StatusRunning
+	j.Attempts++
+	j.LockedUntil = now.Add(lease)
+	j.UpdatedAt = now
+	claimed := *j
+	return &claimed, nil
+}
+
+func (s *MemoryStore) Extend(ctx context.Context, id string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if j, ok := s.jobs[id]; ok && j.Status == StatusRunning {
+		j.LockedUntil = until
+	}
+	return nil
+}
+
+func (s *MemoryStore) Save(ctx context.Context, job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job.UpdatedAt = time.Now()
+	s.jobs[job.ID] = &job
+	return nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return nil, nil
+	}
+	out := *j
+	return &out, nil
+}
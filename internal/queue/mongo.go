@@ -0,0 +1,153 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cg-mentions-bot/internal/types"
+	"cg-mentions-bot/internal/utils/db"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MongoStore is a durable Store backed by a MongoDB collection.
+type MongoStore struct {
+	coll *mongo.Collection
+}
+
+// NewMongoStore returns a job store on xreplyagent.mention_jobs and ensures its indexes.
+func NewMongoStore(client *mongo.Client) (*MongoStore, error) {
+	mg := db.MongoDB{
+		Database:   "xreplyagent",
+		Collection: "mention_jobs",
+	}
+	coll := client.Database(mg.Database).Collection(mg.Collection)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "run_at", Value: 1}}},
+		// Claim groups the unfinished jobs by author, oldest first.
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "_id", Value: 1}}},
+	}); err != nil {
+		return nil, err
+	}
+	return &MongoStore{coll: coll}, nil
+}
+
+func (s *MongoStore) Enqueue(ctx context.Context, mentions []types.Mention) ([]Job, error) {
+	if len(mentions) == 0 {
+		return nil, nil
+	}
+	now := time.Now()
+	out := make([]Job, 0, len(mentions))
+	docs := make([]any, 0, len(mentions))
+	for _, m := range mentions {
+		j := Job{
+			ID:        bson.NewObjectID().Hex(),
+			Mention:   m,
+			Status:    StatusQueued,
+			RunAt:     now,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		out = append(out, j)
+		docs = append(docs, j)
+	}
+	if _, err := s.coll.InsertMany(ctx, docs); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// claimBatch is how many runnable jobs Claim tries before giving up; the rest
+// were claimed by other workers in the meantime.
+const claimBatch = 20
+
+func (s *MongoStore) Claim(ctx context.Context, lease time.Duration) (*Job, error) {
+	now := time.Now()
+	runnable := bson.M{"$or": bson.A{
+		bson.M{"status": StatusQueued, "run_at": bson.M{"$lte": now}},
+		bson.M{"status": StatusRunning, "locked_until": bson.M{"$lt": now}},
+	}}
+	// Only the oldest unfinished job of each author may run, so the candidates
+	// are those heads; jobs without an author are each their own head. Grouping
+	// first keeps an author with a long backlog behind a job in backoff from
+	// filling the batch.
+	cur, err := s.coll.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"status": bson.M{"$in": bson.A{StatusQueued, StatusRunning}}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{"$cond": bson.A{
+				bson.M{"$gt": bson.A{"$mention.twitter_id", ""}}, "$mention.twitter_id", "$_id",
+			}},
+			"head": bson.M{"$first": "$$ROOT"},
+		}}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$head"}}},
+		{{Key: "$match", Value: runnable}},
+		{{Key: "$sort", Value: bson.D{{Key: "run_at", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: claimBatch}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var candidates []Job
+	if err := cur.All(ctx, &candidates); err != nil {
+		return nil, err
+	}
+
+	for _, c := range candidates {
+		// An older unfinished job only ever finishes, so a head stays the head
+		// until it is claimed and two jobs of one author never run together.
+		update := bson.M{
+			"$set": bson.M{"status": StatusRunning, "locked_until": now.Add(lease), "updated_at": now},
+			"$inc": bson.M{"attempts": 1},
+		}
+		var j Job
+		err := s.coll.FindOneAndUpdate(ctx, bson.M{"$and": bson.A{bson.M{"_id": c.ID}, runnable}}, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&j)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			// Another worker claimed it first.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &j, nil
+	}
+	return nil, nil
+}
+
+func (s *MongoStore) Extend(ctx context.Context, id string, until time.Time) error {
+	_, err := s.coll.UpdateOne(ctx, bson.M{"_id": id, "status": StatusRunning}, bson.M{"$set": bson.M{
+		"locked_until": until,
+		"updated_at":   time.Now(),
+	}})
+	return err
+}
+
+func (s *MongoStore) Save(ctx context.Context, job Job) error {
+	_, err := s.coll.UpdateOne(ctx, bson.M{"_id": job.ID}, bson.M{"$set": bson.M{
+		"status":       job.Status,
+		"attempts":     job.Attempts,
+		"last_error":   job.LastError,
+		"run_at":       job.RunAt,
+		"locked_until": job.LockedUntil,
+		"updated_at":   time.Now(),
+	}})
+	return err
+}
+
+func (s *MongoStore) Get(ctx context.Context, id string) (*Job, error) {
+	var j Job
+	if err := s.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&j); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &j, nil
+}
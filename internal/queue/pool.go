@@ -0,0 +1,152 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"cg-mentions-bot/internal/types"
+)
+
+// Pool drains a Store with a fixed number of workers, retrying failed jobs with
+// exponential backoff until MaxAttempts is reached.
+type Pool struct {
+	Store   Store
+	Process func(ctx context.Context, m types.Mention) error
+
+	Workers     int           // default 4
+	MaxAttempts int           // default 3
+	Backoff     time.Duration // delay before the first retry, doubled on each attempt; default 5s
+	MaxBackoff  time.Duration // default 5m
+	Poll        time.Duration // idle wait between claims; default 1s
+	Lease       time.Duration // claim lease, renewed every third of it while a job runs; default DefaultLease
+}
+
+// Run starts the workers and blocks until ctx is cancelled and all in-flight jobs finish.
+func (p *Pool) Run(ctx context.Context) {
+	workers := p.Workers
+	if workers <= 0 {
+		workers = 4
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *Pool) work(ctx context.Context) {
+	poll := p.Poll
+	if poll <= 0 {
+		poll = time.Second
+	}
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		job, err := p.Store.Claim(ctx, p.lease())
+		if err != nil {
+			log.Printf("queue: claim failed: %v", err)
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(poll):
+			}
+			continue
+		}
+		p.run(ctx, *job)
+	}
+}
+
+func (p *Pool) run(ctx context.Context, job Job) {
+	beat, stop := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		p.heartbeat(beat, job.ID)
+	}()
+	err := p.Process(ctx, job.Mention)
+	stop()
+	<-stopped
+	if err == nil {
+		job.Status = StatusDone
+		job.LastError = ""
+	} else {
+		job.LastError = err.Error()
+		if errors.Is(err, ErrPermanent) || job.Attempts >= p.maxAttempts() {
+			job.Status = StatusFailed
+			log.Printf("queue: job %s (tweet %s) failed after %d attempt(s): %v", job.ID, job.Mention.TweetID, job.Attempts, err)
+		} else {
+			job.Status = StatusQueued
+			job.RunAt = time.Now().Add(p.backoff(job.Attempts))
+			log.Printf("queue: job %s (tweet %s) attempt %d failed, retrying at %s: %v", job.ID, job.Mention.TweetID, job.Attempts, job.RunAt.Format(time.RFC3339), err)
+		}
+	}
+	job.LockedUntil = time.Time{}
+
+	// Persist with a fresh context so a shutdown mid-job still records the outcome.
+	saveCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Store.Save(saveCtx, job); err != nil {
+		log.Printf("queue: saving job %s failed: %v", job.ID, err)
+	}
+}
+
+// heartbeat extends the lease of a running job until ctx is done, so a slow
+// job is never claimed by a second worker while it still runs.
+func (p *Pool) heartbeat(ctx context.Context, id string) {
+	lease := p.lease()
+	t := time.NewTicker(lease / 3)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := p.Store.Extend(ctx, id, time.Now().Add(lease)); err != nil && ctx.Err() == nil {
+				log.Printf("queue: extending lease of job %s failed: %v", id, err)
+			}
+		}
+	}
+}
+
+func (p *Pool) lease() time.Duration {
+	if p.Lease <= 0 {
+		return DefaultLease
+	}
+	return p.Lease
+}
+
+func (p *Pool) maxAttempts() int {
+	if p.MaxAttempts <= 0 {
+		return 3
+	}
+	return p.MaxAttempts
+}
+
+func (p *Pool) backoff(attempt int) time.Duration {
+	base := p.Backoff
+	if base <= 0 {
+		base = 5 * time.Second
+	}
+	limit := p.MaxBackoff
+	if limit <= 0 {
+		limit = 5 * time.Minute
+	}
+	d := base
+	for i := 1; i < attempt && d < limit; i++ {
+		d *= 2
+	}
+	if d > limit {
+		d = limit
+	}
+	return d
+}
@@ -0,0 +1,71 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cg-mentions-bot/internal/types"
+)
+
+// Status is the processing state of a queued mention job.
+type Status string
+
+const (
+	StatusQueued  Status = "queued"
+	StatusRunning Status = "running"
+	StatusDone    Status = "done"
+	StatusFailed  Status = "failed"
+)
+
+// DefaultLease is how long a claimed job stays invisible to other workers. The
+// pool extends it while the job runs, so it only expires when the worker is gone
+// (e.g. the process crashed); the job is then claimed again.
+const DefaultLease = 10 * time.Minute
+
+// Job is one mention waiting for (or done with) processing.
+type Job struct {
+	ID          string        `bson:"_id" json:"id"`
+	Mention     types.Mention `bson:"mention" json:"mention"`
+	Status      Status        `bson:"status" json:"status"`
+	Attempts    int           `bson:"attempts" json:"attempts"`
+	LastError   string        `bson:"last_error,omitempty" json:"error,omitempty"`
+	RunAt       time.Time     `bson:"run_at" json:"run_at"`
+	LockedUntil time.Time     `bson:"locked_until,omitempty" json:"-"`
+	CreatedAt   time.Time     `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time     `bson:"updated_at" json:"updated_at"`
+}
+
+// Store persists jobs and hands them out to workers.
+type Store interface {
+	// Enqueue stores one queued job per mention and returns them in order.
+	Enqueue(ctx context.Context, mentions []types.Mention) ([]Job, error)
+	// Claim marks the oldest runnable job as running and returns it, or nil if none is ready.
+	// Jobs of one author run one at a time, in the order they were enqueued: a job is
+	// not claimed while an older job of its author is queued or running.
+	Claim(ctx context.Context, lease time.Duration) (*Job, error)
+	// Extend keeps a running job leased until until; it does nothing once the job
+	// is no longer running.
+	Extend(ctx context.Context, id string, until time.Time) error
+	// Save persists the status, attempts, error and next run time of a job.
+	Save(ctx context.Context, job Job) error
+	// Get returns a job by id, or nil if it does not exist.
+	Get(ctx context.Context, id string) (*Job, error)
+}
+
+// ErrPermanent marks an error that must not be retried.
+var ErrPermanent = errors.New("permanent failure")
+
+// Permanent wraps err so the pool fails the job immediately instead of retrying it.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string        { return e.err.Error() }
+func (e permanentError) Unwrap() error        { return e.err }
+func (e permanentError) Is(target error) bool { return target == ErrPermanent }
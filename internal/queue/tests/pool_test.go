@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cg-mentions-bot/internal/queue"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func waitForStatus(t *testing.T, store queue.Store, id string, want queue.Status) *queue.Job {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		j, err := store.Get(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if j != nil && j.Status == want {
+			return j
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not reach status %s", id, want)
+	return nil
+}
+
+func TestPool(t *testing.T) {
+	t.Run("Transient failures are retried until the job succeeds", func(t *testing.T) {
+		store := queue.NewMemoryStore()
+		var calls int32
+		pool := &queue.Pool{
+			Store: store,
+			Process: func(ctx context.Context, m types.Mention) error {
+				if atomic.AddInt32(&calls, 1) < 2 {
+					return errors.New("upstream timeout")
+				}
+				return nil
+			},
+			Workers:     1,
+			MaxAttempts: 3,
+			Backoff:     time.Millisecond,
+			Poll:        time.Millisecond,
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go pool.Run(ctx)
+
+		jobs, err := store.Enqueue(ctx, []types.Mention{{TweetID: "1"}})
+		assert.NoError(t, err)
+
+		j := waitForStatus(t, store, jobs[0].ID, queue.StatusDone)
+		assert.Equal(t, 2, j.Attempts)
+		assert.Empty(t, j.LastError)
+	})
+
+	t.Run("Permanent failures are not retried", func(t *testing.T) {
+		store := queue.NewMemoryStore()
+		var calls int32
+		pool := &queue.Pool{
+			Store: store,
+			Process: func(ctx context.Context, m types.Mention) error {
+				atomic.AddInt32(&calls, 1)
+				return queue.Permanent(errors.New("bad mention"))
+			},
+			Workers: 1,
+			Backoff: time.Millisecond,
+			Poll:    time.Millisecond,
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go pool.Run(ctx)
+
+		jobs, _ := store.Enqueue(ctx, []types.Mention{{TweetID: "2"}})
+
+		j := waitForStatus(t, store, jobs[0].ID, queue.StatusFailed)
+		assert.Equal(t, 1, j.Attempts)
+		assert.Equal(t, "bad mention", j.LastError)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+	t.Run("Jobs of one author run one at a time and in order on a multi-worker pool", func(t *testing.T) {
+		store := queue.NewMemoryStore()
+		var mu sync.Mutex
+		var order []string
+		var running, overlap, others int32
+		pool := &queue.Pool{
+			Store: store,
+			Process: func(ctx context.Context, m types.Mention) error {
+				if m.AuthorID != "alice" {
+					atomic.AddInt32(&others, 1)
+					return nil
+				}
+				if atomic.AddInt32(&running, 1) > 1 {
+					atomic.StoreInt32(&overlap, 1)
+				}
+				defer atomic.AddInt32(&running, -1)
+				time.Sleep(20 * time.Millisecond)
+				mu.Lock()
+				order = append(order, m.TweetID)
+				mu.Unlock()
+				return nil
+			},
+			Workers: 4,
+			Poll:    time.Millisecond,
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		jobs, err := store.Enqueue(ctx, []types.Mention{
+			{TweetID: "10", AuthorID: "alice", Text: "send 0.1 BNB to @bob"},
+			{TweetID: "11", AuthorID: "alice", Text: "send 0.2 BNB to @carol"},
+			{TweetID: "12", AuthorID: "bob"},
+			{TweetID: "13", AuthorID: "alice", Text: "balance"},
+		})
+		assert.NoError(t, err)
+		go pool.Run(ctx)
+
+		for _, j := range jobs {
+			waitForStatus(t, store, j.ID, queue.StatusDone)
+		}
+		assert.Equal(t, int32(0), atomic.LoadInt32(&overlap), "two jobs of alice ran at the same time")
+		assert.Equal(t, []string{"10", "11", "13"}, order)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&others))
+	})
+	t.Run("A job running past its lease is not claimed a second time", func(t *testing.T) {
+		store := queue.NewMemoryStore()
+		var calls int32
+		pool := &queue.Pool{
+			Store: store,
+			Process: func(ctx context.Context, m types.Mention) error {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(150 * time.Millisecond)
+				return nil
+			},
+			Workers: 2,
+			Poll:    time.Millisecond,
+			Lease:   30 * time.Millisecond,
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go pool.Run(ctx)
+
+		jobs, _ := store.Enqueue(ctx, []types.Mention{{TweetID: "20"}})
+
+		j := waitForStatus(t, store, jobs[0].ID, queue.StatusDone)
+		assert.Equal(t, 1, j.Attempts)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+}
@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"cg-mentions-bot/internal/queue"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// stores returns the job stores to test: the memory store, and the Mongo store
+// when MONGO_TEST_URI points at a disposable database.
+func stores(t *testing.T) map[string]queue.Store {
+	out := map[string]queue.Store{"memory": queue.NewMemoryStore()}
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		return out
+	}
+	client, err := mongo.Connect(options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Disconnect(context.Background()) })
+	// The store uses a fixed collection, so start from an empty one.
+	require.NoError(t, client.Database("xreplyagent").Collection("mention_jobs").Drop(context.Background()))
+	s, err := queue.NewMongoStore(client)
+	require.NoError(t, err)
+	out["mongo"] = s
+	return out
+}
+
+func TestStoreClaim(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run("A long backlog behind a job in backoff does not starve other authors ("+name+")", func(t *testing.T) {
+			ctx := context.Background()
+			head, err := store.Enqueue(ctx, []types.Mention{{TweetID: "a-0", AuthorID: "alice"}})
+			require.NoError(t, err)
+			j, err := store.Claim(ctx, time.Minute)
+			require.NoError(t, err)
+			require.NotNil(t, j)
+			require.Equal(t, head[0].ID, j.ID)
+			j.Status = queue.StatusQueued
+			j.RunAt = time.Now().Add(time.Hour)
+			require.NoError(t, store.Save(ctx, *j))
+
+			// More than the 20 candidates the Mongo store looks at per claim.
+			var backlog []types.Mention
+			for i := 1; i <= 25; i++ {
+				backlog = append(backlog, types.Mention{TweetID: fmt.Sprintf("a-%d", i), AuthorID: "alice"})
+			}
+			_, err = store.Enqueue(ctx, backlog)
+			require.NoError(t, err)
+			bob, err := store.Enqueue(ctx, []types.Mention{{TweetID: "b-0", AuthorID: "bob"}})
+			require.NoError(t, err)
+
+			j, err = store.Claim(ctx, time.Minute)
+			require.NoError(t, err)
+			require.NotNil(t, j)
+			assert.Equal(t, bob[0].ID, j.ID)
+
+			j, err = store.Claim(ctx, time.Minute)
+			require.NoError(t, err)
+			assert.Nil(t, j, "alice's backlog waits for her job in backoff")
+		})
+	}
+}
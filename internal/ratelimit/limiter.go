@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Decision is the outcome of a rate limit check.
+type Decision string
+
+const (
+	Allow     Decision = "allow"
+	Throttled Decision = "throttled" // the user's own bucket is empty
+	Exhausted Decision = "exhausted" // the global budget is spent
+	Blocked   Decision = "blocked"   // the user is on the blocklist
+)
+
+// maxTracked bounds the per-user bucket map; idle buckets are swept past it.
+const maxTracked = 10000
+
+// Limiter applies per-author token buckets, a global budget and the block/allow
+// list in front of the agent. Allowlisted users are never throttled.
+type Limiter struct {
+	PerUser rate.Limit // per-author refill rate
+	Burst   int        // per-author bucket size; default 1
+	Global  *rate.Limiter
+	Lists   ListStore
+	// Minimum gap between two throttle notices to the same user; default 1h.
+	NoticeCooldown time.Duration
+
+	checkMu  sync.Mutex // makes the global check and the token spending one step
+	mu       sync.Mutex
+	buckets  map[string]*bucket
+	notified map[string]time.Time
+}
+
+type bucket struct {
+	lim  *rate.Limiter
+	seen time.Time
+}
+
+// Check decides whether a mention from authorID may reach the agent, consuming a
+// token when it may. A spent global budget is reported before the author's own
+// bucket is touched, so it costs the author nothing.
+func (l *Limiter) Check(ctx context.Context, authorID string) Decision {
+	if l.Lists != nil && authorID != "" {
+		e, err := l.Lists.Get(ctx, authorID)
+		if err != nil {
+			// Fail open on list errors; the buckets still apply.
+			log.Printf("ratelimit: list lookup for %s failed: %v", authorID, err)
+		} else if e != nil {
+			switch e.Access {
+			case AccessBlocked:
+				return Blocked
+			case AccessAllowed:
+				return Allow
+			}
+		}
+	}
+	l.checkMu.Lock()
+	defer l.checkMu.Unlock()
+	if l.Global != nil && l.Global.Tokens() < 1 {
+		return Exhausted
+	}
+	if l.PerUser > 0 && authorID != "" && !l.userBucket(authorID).Allow() {
+		return Throttled
+	}
+	if l.Global != nil && !l.Global.Allow() {
+		return Exhausted
+	}
+	return Allow
+}
+
+// ShouldNotify reports whether the user may be sent a throttle notice now, and
+// records that one was sent.
+func (l *Limiter) ShouldNotify(authorID string) bool {
+	cooldown := l.NoticeCooldown
+	if cooldown <= 0 {
+		cooldown = time.Hour
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.notified == nil {
+		l.notified = make(map[string]time.Time)
+	}
+	now := time.Now()
+	if last, ok := l.notified[authorID]; ok && now.Sub(last) < cooldown {
+		return false
+	}
+	if len(l.notified) >= maxTracked {
+		for id, at := range l.notified {
+			if now.Sub(at) >= cooldown {
+				delete(l.notified, id)
+			}
+		}
+	}
+	l.notified[authorID] = now
+	return true
+}
+
+func (l *Limiter) userBucket(authorID string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buckets == nil {
+		l.buckets = make(map[string]*bucket)
+	}
+	now := time.Now()
+	if b, ok := l.buckets[authorID]; ok {
+		b.seen = now
+		return b.lim
+	}
+	burst := l.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+	if len(l.buckets) >= maxTracked {
+		// A bucket idle long enough to refill completely is the same as a new one.
+		full := time.Duration(float64(burst) / float64(l.PerUser) * float64(time.Second))
+		for id, b := range l.buckets {
+			if now.Sub(b.seen) >= full {
+				delete(l.buckets, id)
+			}
+		}
+	}
+	b := &bucket{lim: rate.NewLimiter(l.PerUser, burst), seen: now}
+	l.buckets[authorID] = b
+	return b.lim
+}
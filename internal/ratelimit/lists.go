@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"cg-mentions-bot/internal/utils/db"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Access is a user's entry on the block/allow list.
+type Access string
+
+const (
+	AccessDefault Access = ""
+	AccessBlocked Access = "blocked"
+	AccessAllowed Access = "allowed"
+)
+
+// ListEntry records why a user was blocked or allowed.
+type ListEntry struct {
+	TwitterID string    `bson:"_id" json:"twitter_id"`
+	Access    Access    `bson:"access" json:"access"`
+	Reason    string    `bson:"reason,omitempty" json:"reason,omitempty"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// ListStore persists the block/allow list keyed by twitter id.
+type ListStore interface {
+	// Get returns the user's entry, or nil if the user is on neither list.
+	Get(ctx context.Context, twitterID string) (*ListEntry, error)
+	Set(ctx context.Context, e ListEntry) error
+	Delete(ctx context.Context, twitterID string) error
+}
+
+// MemoryLists keeps the block/allow list in process.
+type MemoryLists struct {
+	mu      sync.Mutex
+	entries map[string]ListEntry
+}
+
+// NewMemoryLists returns an empty in-memory list store.
+func NewMemoryLists() *MemoryLists {
+	return &MemoryLists{entries: make(map[string]ListEntry)}
+}
+
+func (s *MemoryLists) Get(ctx context.Context, twitterID string) (*ListEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[twitterID]
+	if !ok {
+		return nil, nil
+	}
+	return &e, nil
+}
+
+func (s *MemoryLists) Set(ctx context.Context, e ListEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[e.TwitterID] = e
+	return nil
+}
+
+func (s *MemoryLists) Delete(ctx context.Context, twitterID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, twitterID)
+	return nil
+}
+
+// MongoLists persists the block/allow list in xreplyagent.user_access.
+type MongoLists struct {
+	coll *mongo.Collection
+}
+
+// NewMongoLists returns a Mongo-backed list store.
+func NewMongoLists(client *mongo.Client) *MongoLists {
+	mg := db.MongoDB{
+		Database:   "xreplyagent",
+		Collection: "user_access",
+	}
+	return &MongoLists{coll: client.Database(mg.Database).Collection(mg.Collection)}
+}
+
+func (s *MongoLists) Get(ctx context.Context, twitterID string) (*ListEntry, error) {
+	var e ListEntry
+	if err := s.coll.FindOne(ctx, bson.M{"_id": twitterID}).Decode(&e); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (s *MongoLists) Set(ctx context.Context, e ListEntry) error {
+	_, err := s.coll.ReplaceOne(ctx, bson.M{"_id": e.TwitterID}, e, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s *MongoLists) Delete(ctx context.Context, twitterID string) error {
+	_, err := s.coll.DeleteOne(ctx, bson.M{"_id": twitterID})
+	return err
+}
@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cg-mentions-bot/internal/ratelimit"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+)
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Each author has their own bucket", func(t *testing.T) {
+		l := &ratelimit.Limiter{PerUser: rate.Every(time.Hour), Burst: 2}
+
+		assert.Equal(t, ratelimit.Allow, l.Check(ctx, "1"))
+		assert.Equal(t, ratelimit.Allow, l.Check(ctx, "1"))
+		assert.Equal(t, ratelimit.Throttled, l.Check(ctx, "1"))
+		assert.Equal(t, ratelimit.Allow, l.Check(ctx, "2"))
+	})
+
+	t.Run("The global budget is shared across authors", func(t *testing.T) {
+		l := &ratelimit.Limiter{PerUser: rate.Every(time.Hour), Burst: 5, Global: rate.NewLimiter(rate.Every(time.Hour), 2)}
+
+		assert.Equal(t, ratelimit.Allow, l.Check(ctx, "1"))
+		assert.Equal(t, ratelimit.Allow, l.Check(ctx, "2"))
+		assert.Equal(t, ratelimit.Exhausted, l.Check(ctx, "3"))
+	})
+
+	t.Run("A spent global budget does not use up the author's own tokens", func(t *testing.T) {
+		l := &ratelimit.Limiter{PerUser: rate.Every(time.Hour), Burst: 1, Global: rate.NewLimiter(rate.Every(50*time.Millisecond), 1)}
+		assert.Equal(t, ratelimit.Allow, l.Check(ctx, "1"))
+
+		assert.Equal(t, ratelimit.Exhausted, l.Check(ctx, "2"))
+		time.Sleep(60 * time.Millisecond)
+		assert.Equal(t, ratelimit.Allow, l.Check(ctx, "2"))
+	})
+
+	t.Run("Blocked users are rejected and allowlisted users are never throttled", func(t *testing.T) {
+		lists := ratelimit.NewMemoryLists()
+		_ = lists.Set(ctx, ratelimit.ListEntry{TwitterID: "bad", Access: ratelimit.AccessBlocked})
+		_ = lists.Set(ctx, ratelimit.ListEntry{TwitterID: "vip", Access: ratelimit.AccessAllowed})
+		l := &ratelimit.Limiter{PerUser: rate.Every(time.Hour), Burst: 1, Lists: lists}
+
+		assert.Equal(t, ratelimit.Blocked, l.Check(ctx, "bad"))
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, ratelimit.Allow, l.Check(ctx, "vip"))
+		}
+	})
+
+	t.Run("Throttle notices are sent once per cooldown", func(t *testing.T) {
+		l := &ratelimit.Limiter{NoticeCooldown: time.Hour}
+
+		assert.True(t, l.ShouldNotify("1"))
+		assert.False(t, l.ShouldNotify("1"))
+		assert.True(t, l.ShouldNotify("2"))
+	})
+}
@@ -0,0 +1,110 @@
+// Package shadow records what the bot would have done in dry-run mode: replies it
+// would have posted and value-moving tool calls it would have made.
+package shadow
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Intercepted lists the tools that never reach a real server in shadow mode,
+// besides every sign_* tool (see Intercepts). Callers that can should read an
+// existing wallet with read_wallet instead of simulating create_wallet.
+var Intercepted = map[string]bool{
+	"x_post_reply":       true,
+	"twitter.post_reply": true,
+	"sign_transaction":   true,
+	"transfer_asset":     true,
+	"create_wallet":      true,
+}
+
+// Intercepts reports whether tool is simulated in shadow mode.
+func Intercepts(tool string) bool {
+	return Intercepted[tool] || strings.HasPrefix(tool, "sign_")
+}
+
+// Entry is one intercepted call.
+type Entry struct {
+	ID        string         `bson:"_id" json:"id"`
+	TweetID   string         `bson:"tweet_id,omitempty" json:"tweet_id,omitempty"`
+	TwitterID string         `bson:"twitter_id,omitempty" json:"twitter_id,omitempty"`
+	Tool      string         `bson:"tool" json:"tool"`
+	Args      map[string]any `bson:"args,omitempty" json:"args,omitempty"`
+	Reply     string         `bson:"reply,omitempty" json:"reply,omitempty"` // text of would-be replies
+	Result    string         `bson:"result" json:"result"`                   // simulated result handed back
+	CreatedAt time.Time      `bson:"created_at" json:"created_at"`
+}
+
+// Filter narrows List. Zero values match everything.
+type Filter struct {
+	TweetID string
+	Tool    string
+	Limit   int
+}
+
+// Store keeps intercepted calls for review.
+type Store interface {
+	Add(ctx context.Context, e Entry) error
+	// List returns the newest entries first.
+	List(ctx context.Context, f Filter) ([]Entry, error)
+}
+
+// DefaultLimit caps List when the filter sets no limit.
+const DefaultLimit = 100
+
+// Recorder intercepts tool calls and answers them with simulated results.
+type Recorder struct {
+	Store Store
+	Now   func() time.Time
+}
+
+// Intercept records a call to tool and returns the simulated result the caller
+// should treat as the tool's output. Store errors are returned alongside it so
+// callers can log them without failing the run.
+func (r *Recorder) Intercept(ctx context.Context, tweetID, twitterID, tool string, args map[string]any) (string, error) {
+	now := time.Now
+	if r.Now != nil {
+		now = r.Now
+	}
+	e := Entry{
+		ID:        newID(),
+		TweetID:   tweetID,
+		TwitterID: twitterID,
+		Tool:      tool,
+		Args:      args,
+		Result:    Simulate(tool, args),
+		CreatedAt: now().UTC(),
+	}
+	if text, ok := args["text"].(string); ok {
+		e.Reply = text
+	}
+	if r.Store == nil {
+		return e.Result, nil
+	}
+	return e.Result, r.Store.Add(ctx, e)
+}
+
+// Simulate builds a plausible result for an intercepted tool. Transfers return a
+// bare transaction hash and new wallets a bare address, like the wallet server,
+// zero-padded so they are recognizable as fake wherever they end up.
+func Simulate(tool string, args map[string]any) string {
+	switch {
+	case tool == "x_post_reply" || tool == "twitter.post_reply":
+		return `{"shadow":true,"posted":false,"id":"shadow"}`
+	case tool == "transfer_asset" || strings.HasPrefix(tool, "sign_"):
+		return fmt.Sprintf("0x%064s", "5ad0")
+	case tool == "create_wallet":
+		return fmt.Sprintf("0x%040s", "5ad0")
+	}
+	return `{"shadow":true}`
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
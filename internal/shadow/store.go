@@ -0,0 +1,109 @@
+package shadow
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"cg-mentions-bot/internal/utils/db"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MemoryStore keeps the most recent entries in process.
+type MemoryStore struct {
+	mu      sync.Mutex
+	max     int
+	entries []Entry
+}
+
+// NewMemoryStore returns an in-memory store holding at most max entries
+// (DefaultLimit*10 if max <= 0).
+func NewMemoryStore(max int) *MemoryStore {
+	if max <= 0 {
+		max = DefaultLimit * 10
+	}
+	return &MemoryStore{max: max}
+}
+
+func (s *MemoryStore) Add(ctx context.Context, e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, e)
+	if len(s.entries) > s.max {
+		s.entries = s.entries[len(s.entries)-s.max:]
+	}
+	return nil
+}
+
+func (s *MemoryStore) List(ctx context.Context, f Filter) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	out := []Entry{}
+	for i := len(s.entries) - 1; i >= 0 && len(out) < limit; i-- {
+		e := s.entries[i]
+		if (f.TweetID == "" || e.TweetID == f.TweetID) && (f.Tool == "" || e.Tool == f.Tool) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// MongoStore persists entries in xreplyagent.shadow_calls; they expire after ttl.
+type MongoStore struct {
+	coll *mongo.Collection
+}
+
+// NewMongoStore returns a Mongo-backed store. A TTL index on created_at removes
+// entries after ttl.
+func NewMongoStore(client *mongo.Client, ttl time.Duration) (*MongoStore, error) {
+	mg := db.MongoDB{
+		Database:   "xreplyagent",
+		Collection: "shadow_calls",
+	}
+	coll := client.Database(mg.Database).Collection(mg.Collection)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "created_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(ttl.Seconds())),
+	}); err != nil {
+		return nil, err
+	}
+	return &MongoStore{coll: coll}, nil
+}
+
+func (s *MongoStore) Add(ctx context.Context, e Entry) error {
+	_, err := s.coll.InsertOne(ctx, e)
+	return err
+}
+
+func (s *MongoStore) List(ctx context.Context, f Filter) ([]Entry, error) {
+	filter := bson.M{}
+	if f.TweetID != "" {
+		filter["tweet_id"] = f.TweetID
+	}
+	if f.Tool != "" {
+		filter["tool"] = f.Tool
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	cur, err := s.coll.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	out := []Entry{}
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"cg-mentions-bot/internal/shadow"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Intercepted calls are stored with their arguments and a simulated result", func(t *testing.T) {
+		store := shadow.NewMemoryStore(0)
+		rec := &shadow.Recorder{Store: store}
+
+		out, err := rec.Intercept(ctx, "100", "7", "x_post_reply", map[string]any{"in_reply_to_tweet_id": "100", "text": "gm"})
+		require.NoError(t, err)
+		assert.Contains(t, out, `"shadow":true`)
+
+		out, err = rec.Intercept(ctx, "100", "7", "transfer_asset", map[string]any{"amount": "1"})
+		require.NoError(t, err)
+		assert.Regexp(t, `^0x0{60}5ad0$`, out)
+
+		entries, err := store.List(ctx, shadow.Filter{})
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, "transfer_asset", entries[0].Tool, "newest first")
+		assert.Equal(t, "gm", entries[1].Reply)
+		assert.Equal(t, "100", entries[1].Args["in_reply_to_tweet_id"])
+	})
+
+	t.Run("Listing filters by tweet and tool and keeps only the newest entries", func(t *testing.T) {
+		store := shadow.NewMemoryStore(3)
+		for _, id := range []string{"1", "2", "3", "4"} {
+			require.NoError(t, store.Add(ctx, shadow.Entry{ID: id, TweetID: id, Tool: "x_post_reply"}))
+		}
+
+		all, _ := store.List(ctx, shadow.Filter{})
+		assert.Len(t, all, 3)
+		one, _ := store.List(ctx, shadow.Filter{TweetID: "4"})
+		assert.Len(t, one, 1)
+		none, _ := store.List(ctx, shadow.Filter{Tool: "transfer_asset"})
+		assert.Empty(t, none)
+		gone, _ := store.List(ctx, shadow.Filter{TweetID: "1"})
+		assert.Empty(t, gone)
+	})
+}
@@ -0,0 +1,102 @@
+package traces
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"cg-mentions-bot/internal/utils/db"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// DefaultTTL is how long traces are kept.
+const DefaultTTL = 30 * 24 * time.Hour
+
+// MemoryStore keeps traces in process.
+type MemoryStore struct {
+	mu     sync.Mutex
+	traces map[string]Trace
+}
+
+// NewMemoryStore returns an empty in-memory trace store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{traces: make(map[string]Trace)}
+}
+
+func (s *MemoryStore) Save(ctx context.Context, t Trace) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.traces[t.TweetID] = t
+	return nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, tweetID string) (*Trace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.traces[tweetID]
+	if !ok {
+		return nil, nil
+	}
+	return &t, nil
+}
+
+func (s *MemoryStore) DeleteUser(ctx context.Context, twitterID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, t := range s.traces {
+		if t.TwitterID == twitterID {
+			delete(s.traces, id)
+		}
+	}
+	return nil
+}
+
+// MongoStore persists traces in xreplyagent.agent_traces.
+type MongoStore struct {
+	coll *mongo.Collection
+}
+
+// NewMongoStore returns a Mongo-backed trace store. A TTL index on started_at
+// removes traces after ttl.
+func NewMongoStore(client *mongo.Client, ttl time.Duration) (*MongoStore, error) {
+	mg := db.MongoDB{
+		Database:   "xreplyagent",
+		Collection: "agent_traces",
+	}
+	coll := client.Database(mg.Database).Collection(mg.Collection)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "started_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(ttl.Seconds())),
+	}); err != nil {
+		return nil, err
+	}
+	return &MongoStore{coll: coll}, nil
+}
+
+func (s *MongoStore) Save(ctx context.Context, t Trace) error {
+	_, err := s.coll.ReplaceOne(ctx, bson.M{"_id": t.TweetID}, t, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s *MongoStore) Get(ctx context.Context, tweetID string) (*Trace, error) {
+	var t Trace
+	if err := s.coll.FindOne(ctx, bson.M{"_id": tweetID}).Decode(&t); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *MongoStore) DeleteUser(ctx context.Context, twitterID string) error {
+	_, err := s.coll.DeleteMany(ctx, bson.M{"twitter_id": twitterID})
+	return err
+}
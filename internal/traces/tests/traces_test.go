@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"cg-mentions-bot/internal/traces"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	t.Run("Tool calls, parse errors and token usage end up in the trace", func(t *testing.T) {
+		run := traces.Start("100", "7", "price of bnb?", "gpt-4.1-mini", "PROMPT")
+		ctx := traces.WithRun(context.Background(), run)
+		require.Same(t, run, traces.FromContext(ctx))
+
+		run.LLMCall(100, 20, 120)
+		run.Thought("Coingecko_Price", "I should look up the price")
+		run.Tool("coingecko_price", `{"id":"bnb"}`, "600", nil, time.Now())
+		run.ParseError("unable to parse agent output: hmm")
+		run.Tool("read_wallet", `{}`, "", errors.New("boom"), time.Now())
+		run.LLMCall(150, 30, 180)
+
+		tr := run.Finish("Final Answer: BNB is $600", "BNB is $600", nil)
+		assert.Equal(t, "100", tr.TweetID)
+		assert.Equal(t, "PROMPT", tr.Prompt)
+		require.Len(t, tr.Steps, 2)
+		assert.Equal(t, "I should look up the price", tr.Steps[0].Thought)
+		assert.Equal(t, "600", tr.Steps[0].Output)
+		assert.Equal(t, "boom", tr.Steps[1].Error)
+		assert.Equal(t, []string{"unable to parse agent output: hmm"}, tr.ParseErrors)
+		assert.Equal(t, traces.Usage{LLMCalls: 2, PromptTokens: 250, CompletionTokens: 50, TotalTokens: 300}, tr.Usage)
+		assert.Equal(t, "Final Answer: BNB is $600", tr.RawAnswer)
+		assert.Equal(t, "BNB is $600", tr.Answer)
+	})
+
+	t.Run("Contexts without a run have no trace", func(t *testing.T) {
+		assert.Nil(t, traces.FromContext(context.Background()))
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := traces.NewMemoryStore()
+
+	t.Run("The latest trace of a tweet replaces earlier ones", func(t *testing.T) {
+		require.NoError(t, s.Save(ctx, traces.Trace{TweetID: "1", Answer: "first"}))
+		require.NoError(t, s.Save(ctx, traces.Trace{TweetID: "1", Answer: "second"}))
+
+		got, err := s.Get(ctx, "1")
+		require.NoError(t, err)
+		assert.Equal(t, "second", got.Answer)
+
+		missing, err := s.Get(ctx, "2")
+		require.NoError(t, err)
+		assert.Nil(t, missing)
+	})
+}
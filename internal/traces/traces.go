@@ -0,0 +1,152 @@
+// Package traces records what the agent did for a mention: the prompt, every
+// tool call, parse errors, the final answer and token usage.
+package traces
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Step is one tool call made by the agent.
+type Step struct {
+	Tool      string    `bson:"tool" json:"tool"`
+	Input     string    `bson:"input" json:"input"`
+	Thought   string    `bson:"thought,omitempty" json:"thought,omitempty"` // model text that led to the call
+	Output    string    `bson:"output,omitempty" json:"output,omitempty"`
+	Error     string    `bson:"error,omitempty" json:"error,omitempty"`
+	StartedAt time.Time `bson:"started_at" json:"started_at"`
+	LatencyMS int64     `bson:"latency_ms" json:"latency_ms"`
+}
+
+// Usage sums token counts over the LLM calls of a run.
+type Usage struct {
+	LLMCalls         int `bson:"llm_calls" json:"llm_calls"`
+	PromptTokens     int `bson:"prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int `bson:"completion_tokens" json:"completion_tokens"`
+	TotalTokens      int `bson:"total_tokens" json:"total_tokens"`
+}
+
+// Trace is one agent run, keyed by the tweet it answered.
+type Trace struct {
+	TweetID     string    `bson:"_id" json:"tweet_id"`
+	TwitterID   string    `bson:"twitter_id" json:"twitter_id"`
+	Question    string    `bson:"question" json:"question"`
+	Model       string    `bson:"model" json:"model"`
+	Prompt      string    `bson:"prompt" json:"prompt"`
+	Steps       []Step    `bson:"steps" json:"steps"`
+	ParseErrors []string  `bson:"parse_errors,omitempty" json:"parse_errors,omitempty"`
+	RawAnswer   string    `bson:"raw_answer,omitempty" json:"raw_answer,omitempty"` // before sanitizing
+	Answer      string    `bson:"answer" json:"answer"`
+	Error       string    `bson:"error,omitempty" json:"error,omitempty"`
+	Usage       Usage     `bson:"usage" json:"usage"`
+	StartedAt   time.Time `bson:"started_at" json:"started_at"`
+	DurationMS  int64     `bson:"duration_ms" json:"duration_ms"`
+}
+
+// Store persists traces. Save replaces an earlier trace of the same tweet.
+type Store interface {
+	Save(ctx context.Context, t Trace) error
+	Get(ctx context.Context, tweetID string) (*Trace, error)
+	// DeleteUser removes the traces of every question asked by twitterID.
+	DeleteUser(ctx context.Context, twitterID string) error
+}
+
+// Run collects a trace while the agent works; callbacks may arrive from
+// several goroutines.
+type Run struct {
+	mu    sync.Mutex
+	t     Trace
+	start time.Time
+}
+
+// Start begins a trace for tweetID.
+func Start(tweetID, twitterID, question, model, prompt string) *Run {
+	now := time.Now()
+	return &Run{start: now, t: Trace{
+		TweetID:   tweetID,
+		TwitterID: twitterID,
+		Question:  question,
+		Model:     model,
+		Prompt:    prompt,
+		Steps:     []Step{},
+		StartedAt: now.UTC(),
+	}}
+}
+
+// Thought remembers the model text of the next tool call.
+func (r *Run) Thought(tool, log string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.t.Steps = append(r.t.Steps, Step{Tool: tool, Thought: log})
+}
+
+// Tool records a finished tool call, filling the step opened by Thought if any.
+func (r *Run) Tool(name, input, output string, err error, started time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := Step{Tool: name}
+	idx := -1
+	if n := len(r.t.Steps); n > 0 && strings.EqualFold(r.t.Steps[n-1].Tool, name) && r.t.Steps[n-1].StartedAt.IsZero() {
+		idx = n - 1
+		s = r.t.Steps[idx]
+	}
+	s.Input = input
+	s.Output = output
+	if err != nil {
+		s.Error = err.Error()
+	}
+	s.StartedAt = started.UTC()
+	s.LatencyMS = time.Since(started).Milliseconds()
+	if idx >= 0 {
+		r.t.Steps[idx] = s
+	} else {
+		r.t.Steps = append(r.t.Steps, s)
+	}
+}
+
+// ParseError records model output the agent could not parse.
+func (r *Run) ParseError(msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.t.ParseErrors = append(r.t.ParseErrors, msg)
+}
+
+// LLMCall adds the token usage of one LLM call.
+func (r *Run) LLMCall(prompt, completion, total int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.t.Usage.LLMCalls++
+	r.t.Usage.PromptTokens += prompt
+	r.t.Usage.CompletionTokens += completion
+	r.t.Usage.TotalTokens += total
+}
+
+// Finish closes the run and returns the trace.
+func (r *Run) Finish(raw, answer string, err error) Trace {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.t.RawAnswer = raw
+	r.t.Answer = answer
+	if err != nil {
+		r.t.Error = err.Error()
+	}
+	r.t.DurationMS = time.Since(r.start).Milliseconds()
+	t := r.t
+	t.Steps = append([]Step(nil), r.t.Steps...)
+	return t
+}
+
+type runKey struct{}
+
+// WithRun attaches r to ctx so callbacks can find the run they belong to.
+func WithRun(ctx context.Context, r *Run) context.Context {
+	return context.WithValue(ctx, runKey{}, r)
+}
+
+// FromContext returns the run attached to ctx, or nil.
+func FromContext(ctx context.Context) *Run {
+	r, _ := ctx.Value(runKey{}).(*Run)
+	return r
+}
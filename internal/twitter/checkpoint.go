@@ -0,0 +1,80 @@
+package twitter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"cg-mentions-bot/internal/utils/db"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// CheckpointStore remembers the newest tweet id a poller has handled.
+type CheckpointStore interface {
+	Load(ctx context.Context, key string) (string, error)
+	Save(ctx context.Context, key string, sinceID string) error
+}
+
+// MongoCheckpoints stores poller checkpoints in xreplyagent.poller_checkpoints.
+type MongoCheckpoints struct {
+	coll *mongo.Collection
+}
+
+// NewMongoCheckpoints returns a Mongo-backed CheckpointStore.
+func NewMongoCheckpoints(client *mongo.Client) *MongoCheckpoints {
+	mg := db.MongoDB{
+		Database:   "xreplyagent",
+		Collection: "poller_checkpoints",
+	}
+	return &MongoCheckpoints{coll: client.Database(mg.Database).Collection(mg.Collection)}
+}
+
+func (c *MongoCheckpoints) Load(ctx context.Context, key string) (string, error) {
+	var doc struct {
+		SinceID string `bson:"since_id"`
+	}
+	if err := c.coll.FindOne(ctx, bson.M{"_id": key}).Decode(&doc); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return "", nil
+		}
+		return "", err
+	}
+	return doc.SinceID, nil
+}
+
+func (c *MongoCheckpoints) Save(ctx context.Context, key string, sinceID string) error {
+	_, err := c.coll.UpdateOne(ctx,
+		bson.M{"_id": key},
+		bson.M{"$set": bson.M{"since_id": sinceID, "updated_at": time.Now()}},
+		options.UpdateOne().SetUpsert(true),
+	)
+	return err
+}
+
+// MemoryCheckpoints keeps checkpoints in process; the poller starts fresh after a restart.
+type MemoryCheckpoints struct {
+	mu sync.Mutex
+	m  map[string]string
+}
+
+// NewMemoryCheckpoints returns an empty in-memory CheckpointStore.
+func NewMemoryCheckpoints() *MemoryCheckpoints {
+	return &MemoryCheckpoints{m: make(map[string]string)}
+}
+
+func (c *MemoryCheckpoints) Load(ctx context.Context, key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.m[key], nil
+}
+
+func (c *MemoryCheckpoints) Save(ctx context.Context, key string, sinceID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.m[key] = sinceID
+	return nil
+}
@@ -0,0 +1,282 @@
+package twitter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"cg-mentions-bot/internal/types"
+)
+
+// Poller reads the bot's mentions timeline from the X API v2 and hands new
+// mentions to Handle, so the bot can run without an external webhook sender.
+type Poller struct {
+	BaseURL     string // e.g. https://api.twitter.com/2
+	Bearer      string
+	UserID      string // the bot account's user id
+	Checkpoints CheckpointStore
+	Handle      func(ctx context.Context, mentions []types.Mention) error
+
+	MinInterval time.Duration // lower bound between polls; default 20s
+	HTTPClient  *http.Client
+}
+
+type mentionsTimeline struct {
+	Data []struct {
+		ID             string `json:"id"`
+		Text           string `json:"text"`
+		AuthorID       string `json:"author_id"`
+		ConversationID string `json:"conversation_id"`
+		CreatedAt      string `json:"created_at"`
+		Entities       struct {
+			Mentions []types.MentionedUser `json:"mentions"`
+		} `json:"entities"`
+	} `json:"data"`
+	Includes struct {
+		Users []struct {
+			ID       string `json:"id"`
+			Username string `json:"username"`
+		} `json:"users"`
+	} `json:"includes"`
+	Meta struct {
+		NewestID    string `json:"newest_id"`
+		ResultCount int    `json:"result_count"`
+		NextToken   string `json:"next_token"`
+	} `json:"meta"`
+}
+
+// rateLimit is what the x-rate-limit-* headers said about the current window.
+type rateLimit struct {
+	remaining int
+	reset     time.Time
+	known     bool
+}
+
+// Run polls until ctx is cancelled.
+func (p *Poller) Run(ctx context.Context) {
+	log.Printf("mentions poller started for user %s", p.UserID)
+	for {
+		wait, err := p.poll(ctx)
+		if err != nil {
+			log.Printf("mentions poller: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// poll fetches every mention newer than the checkpoint, submits them oldest
+// first, advances the checkpoint and returns how long to wait before the next poll.
+// When the rate limit stops pagination early, the mentions fetched so far are
+// submitted and the pagination token is kept, so the next poll resumes with the
+// older pages instead of skipping them.
+func (p *Poller) poll(ctx context.Context) (time.Duration, error) {
+	key := "mentions:" + p.UserID
+	sinceID, err := p.Checkpoints.Load(ctx, key)
+	if err != nil {
+		return p.minInterval(), fmt.Errorf("load checkpoint: %w", err)
+	}
+	// An unfinished pagination: its token and the newest id of its first page.
+	token, err := p.Checkpoints.Load(ctx, key+":next")
+	if err != nil {
+		return p.minInterval(), fmt.Errorf("load checkpoint: %w", err)
+	}
+	var newest string
+	if token != "" {
+		if newest, err = p.Checkpoints.Load(ctx, key+":newest"); err != nil {
+			return p.minInterval(), fmt.Errorf("load checkpoint: %w", err)
+		}
+	}
+
+	var mentions []types.Mention
+	var rl rateLimit
+	next := ""
+	for {
+		page, pageRL, err := p.fetch(ctx, sinceID, token)
+		rl = pageRL
+		if err != nil {
+			return p.schedule(rl), err
+		}
+		if newest == "" {
+			newest = page.Meta.NewestID
+		}
+		mentions = append(mentions, p.toMentions(page)...)
+		// Without a checkpoint only the newest page matters: we record where we are
+		// instead of answering the account's entire mention history.
+		if sinceID == "" || page.Meta.NextToken == "" {
+			break
+		}
+		if rl.known && rl.remaining == 0 {
+			next = page.Meta.NextToken
+			break
+		}
+		token = page.Meta.NextToken
+	}
+
+	if newest == "" {
+		return p.schedule(rl), nil
+	}
+	if sinceID == "" {
+		log.Printf("mentions poller: no checkpoint, starting after tweet %s", newest)
+		return p.schedule(rl), p.Checkpoints.Save(ctx, key, newest)
+	}
+
+	// The timeline is newest first; answer in the order people wrote.
+	sort.SliceStable(mentions, func(i, j int) bool { return olderID(mentions[i].TweetID, mentions[j].TweetID) })
+	if len(mentions) > 0 {
+		if err := p.Handle(ctx, mentions); err != nil {
+			return p.schedule(rl), fmt.Errorf("handle %d mention(s): %w", len(mentions), err)
+		}
+	}
+	if next != "" {
+		log.Printf("mentions poller: rate limit reached while paging, resuming older mentions next poll")
+		if err := p.Checkpoints.Save(ctx, key+":newest", newest); err != nil {
+			return p.schedule(rl), fmt.Errorf("save checkpoint: %w", err)
+		}
+		if err := p.Checkpoints.Save(ctx, key+":next", next); err != nil {
+			return p.schedule(rl), fmt.Errorf("save checkpoint: %w", err)
+		}
+		return p.schedule(rl), nil
+	}
+	if err := p.Checkpoints.Save(ctx, key, newest); err != nil {
+		return p.schedule(rl), fmt.Errorf("save checkpoint: %w", err)
+	}
+	if token != "" {
+		if err := p.Checkpoints.Save(ctx, key+":next", ""); err != nil {
+			return p.schedule(rl), fmt.Errorf("save checkpoint: %w", err)
+		}
+	}
+	return p.schedule(rl), nil
+}
+
+func (p *Poller) fetch(ctx context.Context, sinceID string, token string) (*mentionsTimeline, rateLimit, error) {
+	q := url.Values{}
+	q.Set("max_results", "100")
+	q.Set("tweet.fields", "author_id,conversation_id,created_at,entities")
+	q.Set("expansions", "author_id")
+	q.Set("user.fields", "username")
+	if sinceID != "" {
+		q.Set("since_id", sinceID)
+	}
+	if token != "" {
+		q.Set("pagination_token", token)
+	}
+	u := fmt.Sprintf("%s/users/%s/mentions?%s", p.BaseURL, p.UserID, q.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, rateLimit{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.Bearer)
+
+	hc := p.HTTPClient
+	if hc == nil {
+		hc = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, rateLimit{}, err
+	}
+	defer resp.Body.Close()
+
+	rl := parseRateLimit(resp.Header)
+	if resp.StatusCode == http.StatusTooManyRequests {
+		rl.remaining, rl.known = 0, !rl.reset.IsZero()
+		return nil, rl, fmt.Errorf("rate limited until %s", rl.reset.Format(time.RFC3339))
+	}
+	if resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, rl, fmt.Errorf("mentions timeline failed: status %d: %s", resp.StatusCode, string(b))
+	}
+
+	var out mentionsTimeline
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, rl, err
+	}
+	return &out, rl, nil
+}
+
+func (p *Poller) toMentions(page *mentionsTimeline) []types.Mention {
+	usernames := make(map[string]string, len(page.Includes.Users))
+	for _, u := range page.Includes.Users {
+		usernames[u.ID] = u.Username
+	}
+	out := make([]types.Mention, 0, len(page.Data))
+	for _, t := range page.Data {
+		if t.AuthorID == p.UserID {
+			continue
+		}
+		m := types.Mention{
+			TweetID:        t.ID,
+			Text:           t.Text,
+			AuthorID:       t.AuthorID,
+			AuthorUsername: usernames[t.AuthorID],
+			ConversationID: t.ConversationID,
+			CreatedAt:      t.CreatedAt,
+		}
+		if len(t.Entities.Mentions) > 0 {
+			m.Entities = &types.MentionEntities{Mentions: t.Entities.Mentions}
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// schedule spreads the remaining requests of the rate-limit window evenly until
+// it resets, never polling faster than MinInterval.
+func (p *Poller) schedule(rl rateLimit) time.Duration {
+	wait := p.minInterval()
+	if !rl.known {
+		return wait
+	}
+	untilReset := time.Until(rl.reset)
+	if untilReset <= 0 {
+		return wait
+	}
+	if rl.remaining <= 0 {
+		return untilReset + time.Second
+	}
+	if spread := untilReset / time.Duration(rl.remaining); spread > wait {
+		return spread
+	}
+	return wait
+}
+
+func (p *Poller) minInterval() time.Duration {
+	if p.MinInterval <= 0 {
+		return 20 * time.Second
+	}
+	return p.MinInterval
+}
+
+func parseRateLimit(h http.Header) rateLimit {
+	rl := rateLimit{}
+	remaining, errRemaining := strconv.Atoi(h.Get("x-rate-limit-remaining"))
+	reset, errReset := strconv.ParseInt(h.Get("x-rate-limit-reset"), 10, 64)
+	if errReset == nil {
+		rl.reset = time.Unix(reset, 0)
+	}
+	if errRemaining == nil && errReset == nil {
+		rl.remaining = remaining
+		rl.known = true
+	}
+	return rl
+}
+
+// olderID compares tweet ids (snowflakes), which sort by length then lexically.
+func olderID(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"cg-mentions-bot/internal/twitter"
+	"cg-mentions-bot/internal/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoller(t *testing.T) {
+	t.Run("New mentions are handed over oldest first and the checkpoint advances", func(t *testing.T) {
+		var gotSinceID string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotSinceID = r.URL.Query().Get("since_id")
+			w.Header().Set("x-rate-limit-remaining", "10")
+			w.Header().Set("x-rate-limit-reset", strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+			_, _ = w.Write([]byte(`{
+				"data":[
+					{"id":"1003","text":"@bot balance","author_id":"7","conversation_id":"1003"},
+					{"id":"1002","text":"@bot hi","author_id":"8","conversation_id":"1002"},
+					{"id":"1001","text":"my own reply","author_id":"99","conversation_id":"900"}
+				],
+				"includes":{"users":[{"id":"7","username":"alice"},{"id":"8","username":"bob"}]},
+				"meta":{"newest_id":"1003","result_count":3}
+			}`))
+		}))
+		defer srv.Close()
+
+		checkpoints := twitter.NewMemoryCheckpoints()
+		_ = checkpoints.Save(context.Background(), "mentions:99", "1000")
+
+		var mu sync.Mutex
+		var handled []types.Mention
+		ctx, cancel := context.WithCancel(context.Background())
+		p := &twitter.Poller{
+			BaseURL:     srv.URL,
+			Bearer:      "token",
+			UserID:      "99",
+			Checkpoints: checkpoints,
+			Handle: func(ctx context.Context, mentions []types.Mention) error {
+				mu.Lock()
+				defer mu.Unlock()
+				handled = append(handled, mentions...)
+				cancel()
+				return nil
+			},
+			MinInterval: time.Millisecond,
+		}
+		p.Run(ctx)
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, "1000", gotSinceID)
+		if assert.Len(t, handled, 2) {
+			assert.Equal(t, "1002", handled[0].TweetID)
+			assert.Equal(t, "bob", handled[0].AuthorUsername)
+			assert.Equal(t, "1003", handled[1].TweetID)
+		}
+		since, _ := checkpoints.Load(context.Background(), "mentions:99")
+		assert.Equal(t, "1003", since)
+	})
+	t.Run("Pages left behind by the rate limit are fetched on the next poll", func(t *testing.T) {
+		var mu sync.Mutex
+		var requests []string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			q := r.URL.Query()
+			mu.Lock()
+			requests = append(requests, q.Get("since_id")+"/"+q.Get("pagination_token"))
+			mu.Unlock()
+			if q.Get("pagination_token") == "" {
+				// The window is used up after the first page.
+				w.Header().Set("x-rate-limit-remaining", "0")
+				w.Header().Set("x-rate-limit-reset", strconv.FormatInt(time.Now().Add(-time.Second).Unix(), 10))
+				_, _ = w.Write([]byte(`{
+					"data":[{"id":"1005","text":"@bot a","author_id":"7"},{"id":"1004","text":"@bot b","author_id":"7"}],
+					"meta":{"newest_id":"1005","result_count":2,"next_token":"p2"}
+				}`))
+				return
+			}
+			w.Header().Set("x-rate-limit-remaining", "10")
+			w.Header().Set("x-rate-limit-reset", strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+			_, _ = w.Write([]byte(`{
+				"data":[{"id":"1002","text":"@bot c","author_id":"8"},{"id":"1001","text":"@bot d","author_id":"8"}],
+				"meta":{"result_count":2}
+			}`))
+		}))
+		defer srv.Close()
+
+		checkpoints := twitter.NewMemoryCheckpoints()
+		_ = checkpoints.Save(context.Background(), "mentions:99", "1000")
+
+		var handled []string
+		var saved []string
+		ctx, cancel := context.WithCancel(context.Background())
+		p := &twitter.Poller{
+			BaseURL:     srv.URL,
+			Bearer:      "token",
+			UserID:      "99",
+			Checkpoints: checkpoints,
+			Handle: func(ctx context.Context, mentions []types.Mention) error {
+				for _, m := range mentions {
+					handled = append(handled, m.TweetID)
+				}
+				since, _ := checkpoints.Load(ctx, "mentions:99")
+				saved = append(saved, since)
+				if len(handled) == 4 {
+					cancel()
+				}
+				return nil
+			},
+			MinInterval: time.Millisecond,
+		}
+		p.Run(ctx)
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []string{"1000/", "1000/p2"}, requests)
+		assert.Equal(t, []string{"1004", "1005", "1001", "1002"}, handled)
+		assert.Equal(t, []string{"1000", "1000"}, saved, "the checkpoint must not pass the unfetched pages")
+		since, _ := checkpoints.Load(context.Background(), "mentions:99")
+		assert.Equal(t, "1005", since)
+		next, _ := checkpoints.Load(context.Background(), "mentions:99:next")
+		assert.Empty(t, next)
+	})
+}
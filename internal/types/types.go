@@ -7,12 +7,27 @@ import (
 
 // Mention represents one mention payload item.
 type Mention struct {
-	TweetID        string `json:"tweet_id"`
-	Text           string `json:"text"`
-	AuthorID       string `json:"twitter_id"`
-	AuthorUsername string `json:"author_username"`
-	ConversationID string `json:"conversation_id"`
-	CreatedAt      string `json:"created_at"`
+	TweetID        string `json:"tweet_id" bson:"tweet_id"`
+	Text           string `json:"text" bson:"text"`
+	AuthorID       string `json:"twitter_id" bson:"twitter_id"`
+	AuthorUsername string `json:"author_username" bson:"author_username"`
+	ConversationID string `json:"conversation_id" bson:"conversation_id"`
+	CreatedAt      string `json:"created_at" bson:"created_at"`
+	// Entities are X's parsed entities of the tweet, when the sender includes them.
+	Entities *MentionEntities `json:"entities,omitempty" bson:"entities,omitempty"`
+}
+
+// MentionEntities mirrors the entities object of an X tweet.
+type MentionEntities struct {
+	Mentions []MentionedUser `json:"mentions,omitempty" bson:"mentions,omitempty"`
+}
+
+// MentionedUser is one @handle of a tweet as resolved by X.
+type MentionedUser struct {
+	ID       string `json:"id,omitempty" bson:"id,omitempty"`
+	Username string `json:"username" bson:"username"`
+	Start    int    `json:"start,omitempty" bson:"start,omitempty"`
+	End      int    `json:"end,omitempty" bson:"end,omitempty"`
 }
 
 // MentionsPayload is the full body we receive from n8n.
@@ -27,3 +42,42 @@ type ToolInfo struct {
 	Tool    mcp.Tool
 	Handler func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error)
 }
+
+// Turn is one prior tweet in a conversation, either from a user or from the bot.
+type Turn struct {
+	TweetID        string `json:"tweet_id,omitempty" bson:"tweet_id,omitempty"`
+	AuthorID       string `json:"author_id,omitempty" bson:"author_id,omitempty"`
+	AuthorUsername string `json:"author_username,omitempty" bson:"author_username,omitempty"`
+	Text           string `json:"text" bson:"text"`
+	FromBot        bool   `json:"from_bot,omitempty" bson:"from_bot,omitempty"`
+	CreatedAt      string `json:"created_at,omitempty" bson:"created_at,omitempty"`
+}
+
+// AgentRequest is everything the agent needs to answer (and reply to) one mention.
+type AgentRequest struct {
+	Question  string
+	ReplyTo   string
+	TwitterID string
+	// MentionedPeople are the users the tweet mentions (bot and reply chain excluded), in order.
+	MentionedPeople []MentionedUser
+	// History holds earlier tweets of the same conversation, oldest first.
+	History []Turn
+	// Memory is a rendered summary of the user's past interactions, if any.
+	Memory string
+	// Source selects the tool policy context: "mention" (default) or "api".
+	Source string
+}
+
+// AgentResult is the structured outcome of one agent run.
+type AgentResult struct {
+	Answer    string   `json:"answer"`
+	ToolsUsed []string `json:"tools_used,omitempty"`
+	TxHashes  []string `json:"tx_hashes,omitempty"`
+	// Posted reports whether the answer was posted as a reply on X.
+	Posted bool `json:"posted"`
+	// SideEffects reports that the run called a tool that posts or moves value,
+	// successfully or not, or may have; such a run must not be repeated.
+	SideEffects bool `json:"side_effects,omitempty"`
+	// Error carries the failure across the process boundary of the agent binary (-json).
+	Error string `json:"error,omitempty"`
+}
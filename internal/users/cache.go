@@ -0,0 +1,142 @@
+package users
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"cg-mentions-bot/internal/utils/db"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Cache maps lowercased handles to X user ids.
+type Cache interface {
+	// Get returns the ids it knows for the given handles; unknown handles are absent.
+	Get(ctx context.Context, handles []string) (map[string]string, error)
+	Put(ctx context.Context, ids map[string]string) error
+}
+
+// lru is a bounded in-process handle→id cache with per-entry expiry.
+type lru struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	order *list.List
+	items map[string]*list.Element
+}
+
+type lruItem struct {
+	handle  string
+	id      string
+	expires time.Time
+}
+
+func newLRU(size int, ttl time.Duration) *lru {
+	return &lru{size: size, ttl: ttl, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *lru) get(handle string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[handle]
+	if !ok {
+		return "", false
+	}
+	it := el.Value.(*lruItem)
+	if time.Now().After(it.expires) {
+		c.order.Remove(el)
+		delete(c.items, handle)
+		return "", false
+	}
+	c.order.MoveToFront(el)
+	return it.id, true
+}
+
+func (c *lru) put(handle string, id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := time.Now().Add(c.ttl)
+	if el, ok := c.items[handle]; ok {
+		it := el.Value.(*lruItem)
+		it.id, it.expires = id, expires
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[handle] = c.order.PushFront(&lruItem{handle: handle, id: id, expires: expires})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruItem).handle)
+	}
+}
+
+// MongoCache persists handle→id pairs in xreplyagent.x_users; a TTL index
+// removes them after the configured time so renamed handles are re-resolved.
+type MongoCache struct {
+	ttl  time.Duration
+	coll *mongo.Collection
+}
+
+type cachedUser struct {
+	Handle    string    `bson:"_id"`
+	ID        string    `bson:"id"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// NewMongoCache returns a Mongo-backed handle cache whose entries live for ttl.
+func NewMongoCache(client *mongo.Client, ttl time.Duration) (*MongoCache, error) {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	mg := db.MongoDB{
+		Database:   "xreplyagent",
+		Collection: "x_users",
+	}
+	coll := client.Database(mg.Database).Collection(mg.Collection)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}); err != nil {
+		return nil, err
+	}
+	return &MongoCache{ttl: ttl, coll: coll}, nil
+}
+
+func (c *MongoCache) Get(ctx context.Context, handles []string) (map[string]string, error) {
+	cur, err := c.coll.Find(ctx, bson.M{"_id": bson.M{"$in": handles}, "expires_at": bson.M{"$gt": time.Now()}})
+	if err != nil {
+		return nil, err
+	}
+	var docs []cachedUser
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(docs))
+	for _, d := range docs {
+		out[d.Handle] = d.ID
+	}
+	return out, nil
+}
+
+func (c *MongoCache) Put(ctx context.Context, ids map[string]string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	expires := time.Now().Add(c.ttl)
+	models := make([]mongo.WriteModel, 0, len(ids))
+	for handle, id := range ids {
+		models = append(models, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": handle}).
+			SetReplacement(cachedUser{Handle: handle, ID: id, ExpiresAt: expires}).
+			SetUpsert(true))
+	}
+	_, err := c.coll.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
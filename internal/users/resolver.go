@@ -0,0 +1,260 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"cg-mentions-bot/internal/types"
+)
+
+// DefaultTTL is how long a resolved handle is trusted.
+const DefaultTTL = 24 * time.Hour
+
+const (
+	defaultLRUSize = 5000
+	// maxBatch is the most usernames X accepts in one users/by lookup.
+	maxBatch = 100
+)
+
+var handleRe = regexp.MustCompile(`@(\w+)`)
+
+// Resolver turns @handles into X user ids, preferring ids X already put in the
+// payload, then the caches, then one batched users/by lookup for the rest.
+type Resolver struct {
+	BaseURL string // e.g. https://api.twitter.com/2
+	Bearer  string
+	// The bot's own account is never reported as a mentioned user.
+	BotUserID   string
+	BotUsername string
+	Cache       Cache // optional second-level cache (e.g. Mongo)
+	TTL         time.Duration
+	HTTP        *http.Client
+
+	once sync.Once
+	lru  *lru
+}
+
+// NewResolver returns a Resolver with an in-process LRU in front of cache.
+func NewResolver(baseURL, bearer string, cache Cache, ttl time.Duration) *Resolver {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Resolver{
+		BaseURL: baseURL,
+		Bearer:  bearer,
+		Cache:   cache,
+		TTL:     ttl,
+		HTTP:    &http.Client{Timeout: 10 * time.Second},
+		lru:     newLRU(defaultLRUSize, ttl),
+	}
+}
+
+// Mentioned returns the users a mention talks about, with their ids, in order
+// of appearance. The bot itself and the reply-chain handles X prefixes to
+// replies are left out; handles that cannot be resolved are skipped.
+func (r *Resolver) Mentioned(ctx context.Context, m types.Mention) []types.MentionedUser {
+	var refs []types.MentionedUser
+	if m.Entities != nil && len(m.Entities.Mentions) > 0 {
+		refs = append(refs, m.Entities.Mentions...)
+	} else {
+		for _, match := range handleRe.FindAllStringSubmatchIndex(m.Text, -1) {
+			refs = append(refs, types.MentionedUser{Username: m.Text[match[2]:match[3]], Start: match[0], End: match[1]})
+		}
+	}
+
+	chain := map[string]bool{}
+	if isReply(m) {
+		for _, h := range leadingHandles(m.Text) {
+			chain[h] = true
+		}
+	}
+	inBody := map[string]bool{}
+	for _, match := range handleRe.FindAllStringSubmatch(stripLeadingHandles(m.Text), -1) {
+		inBody[strings.ToLower(match[1])] = true
+	}
+
+	var keep []types.MentionedUser
+	var missing []string
+	seen := map[string]bool{}
+	for _, u := range refs {
+		handle := strings.ToLower(u.Username)
+		if seen[handle] || r.isBot(handle, u.ID) || (chain[handle] && !inBody[handle]) {
+			continue
+		}
+		seen[handle] = true
+		keep = append(keep, u)
+		if u.ID == "" {
+			missing = append(missing, handle)
+		}
+	}
+
+	resolved := map[string]string{}
+	if len(missing) > 0 {
+		var err error
+		if resolved, err = r.Resolve(ctx, missing); err != nil {
+			log.Printf("users: resolving %v failed: %v", missing, err)
+		}
+	}
+	out := make([]types.MentionedUser, 0, len(keep))
+	for _, u := range keep {
+		if u.ID == "" {
+			u.ID = resolved[strings.ToLower(u.Username)]
+		}
+		if u.ID != "" && !r.isBot("", u.ID) {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// Resolve maps handles (without "@") to user ids. Unknown handles are absent
+// from the result.
+func (r *Resolver) Resolve(ctx context.Context, handles []string) (map[string]string, error) {
+	out := make(map[string]string, len(handles))
+	var misses []string
+	for _, h := range handles {
+		h = strings.ToLower(strings.TrimPrefix(h, "@"))
+		if id, ok := r.cache().get(h); ok {
+			out[h] = id
+		} else {
+			misses = append(misses, h)
+		}
+	}
+	if len(misses) > 0 && r.Cache != nil {
+		found, err := r.Cache.Get(ctx, misses)
+		if err != nil {
+			log.Printf("users: cache lookup failed: %v", err)
+		}
+		var still []string
+		for _, h := range misses {
+			if id, ok := found[h]; ok {
+				out[h] = id
+				r.cache().put(h, id)
+			} else {
+				still = append(still, h)
+			}
+		}
+		misses = still
+	}
+
+	fetched := map[string]string{}
+	for start := 0; start < len(misses); start += maxBatch {
+		end := min(start+maxBatch, len(misses))
+		batch, err := r.lookup(ctx, misses[start:end])
+		if err != nil {
+			return out, err
+		}
+		for h, id := range batch {
+			fetched[h] = id
+			out[h] = id
+			r.cache().put(h, id)
+		}
+	}
+	if len(fetched) > 0 && r.Cache != nil {
+		if err := r.Cache.Put(ctx, fetched); err != nil {
+			log.Printf("users: cache write failed: %v", err)
+		}
+	}
+	return out, nil
+}
+
+// lookup calls GET /users/by?usernames=a,b,... for up to 100 handles.
+func (r *Resolver) lookup(ctx context.Context, handles []string) (map[string]string, error) {
+	if r.Bearer == "" {
+		return nil, fmt.Errorf("no bearer token configured")
+	}
+	q := url.Values{}
+	q.Set("usernames", strings.Join(handles, ","))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.BaseURL+"/users/by?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+r.Bearer)
+
+	hc := r.HTTP
+	if hc == nil {
+		hc = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("users lookup failed: status %d: %s", resp.StatusCode, string(b))
+	}
+
+	var body struct {
+		Data []struct {
+			ID       string `json:"id"`
+			Username string `json:"username"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(body.Data))
+	for _, u := range body.Data {
+		out[strings.ToLower(u.Username)] = u.ID
+	}
+	return out, nil
+}
+
+func (r *Resolver) cache() *lru {
+	r.once.Do(func() {
+		if r.lru == nil {
+			ttl := r.TTL
+			if ttl <= 0 {
+				ttl = DefaultTTL
+			}
+			r.lru = newLRU(defaultLRUSize, ttl)
+		}
+	})
+	return r.lru
+}
+
+func (r *Resolver) isBot(handle, id string) bool {
+	if id != "" && id == r.BotUserID {
+		return true
+	}
+	return handle != "" && strings.EqualFold(handle, strings.TrimPrefix(r.BotUsername, "@"))
+}
+
+// isReply reports whether the mention answers another tweet of its thread.
+func isReply(m types.Mention) bool {
+	return m.ConversationID != "" && m.ConversationID != m.TweetID
+}
+
+// leadingHandles returns the run of @handles a tweet starts with, which X adds
+// for everyone in the reply chain.
+func leadingHandles(s string) []string {
+	var out []string
+	for _, f := range strings.Fields(s) {
+		if !strings.HasPrefix(f, "@") {
+			break
+		}
+		if m := handleRe.FindStringSubmatch(f); m != nil {
+			out = append(out, strings.ToLower(m[1]))
+		}
+	}
+	return out
+}
+
+func stripLeadingHandles(s string) string {
+	fields := strings.Fields(s)
+	i := 0
+	for i < len(fields) && strings.HasPrefix(fields[i], "@") {
+		i++
+	}
+	return strings.Join(fields[i:], " ")
+}
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"cg-mentions-bot/internal/types"
+	"cg-mentions-bot/internal/users"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeX(t *testing.T, calls *int32, queries *[]string) *httptest.Server {
+	ids := map[string]string{"alice": "1", "bob": "2", "carol": "3"}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		q := r.URL.Query().Get("usernames")
+		*queries = append(*queries, q)
+		var data []string
+		for _, h := range strings.Split(q, ",") {
+			if id, ok := ids[h]; ok {
+				data = append(data, `{"id":"`+id+`","username":"`+h+`"}`)
+			}
+		}
+		_, _ = w.Write([]byte(`{"data":[` + strings.Join(data, ",") + `]}`))
+	}))
+}
+
+func ids(list []types.MentionedUser) []string {
+	var out []string
+	for _, u := range list {
+		out = append(out, u.ID)
+	}
+	return out
+}
+
+func TestResolver(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Handles are resolved in one batch and cached afterwards", func(t *testing.T) {
+		var calls int32
+		var queries []string
+		srv := fakeX(t, &calls, &queries)
+		defer srv.Close()
+		r := users.NewResolver(srv.URL, "token", nil, 0)
+		r.BotUsername = "bot"
+
+		m := types.Mention{TweetID: "9", Text: "@bot send 1 BNB to @Alice and @bob and @ghost"}
+		assert.Equal(t, []string{"1", "2"}, ids(r.Mentioned(ctx, m)))
+		assert.Equal(t, []string{"alice,bob,ghost"}, queries)
+
+		assert.Equal(t, []string{"1", "2"}, ids(r.Mentioned(ctx, m)))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls), "only the unknown handle is looked up again")
+	})
+
+	t.Run("Reply-chain handles are skipped unless they are addressed in the body", func(t *testing.T) {
+		var calls int32
+		var queries []string
+		srv := fakeX(t, &calls, &queries)
+		defer srv.Close()
+		r := users.NewResolver(srv.URL, "token", nil, 0)
+		r.BotUsername = "bot"
+
+		m := types.Mention{TweetID: "9", ConversationID: "5", Text: "@carol @alice @bot tip @alice 5 USDT"}
+		assert.Equal(t, []string{"1"}, ids(r.Mentioned(ctx, m)))
+	})
+
+	t.Run("Ids from the payload entities are used without calling X", func(t *testing.T) {
+		var calls int32
+		var queries []string
+		srv := fakeX(t, &calls, &queries)
+		defer srv.Close()
+		r := users.NewResolver(srv.URL, "token", nil, 0)
+		r.BotUserID = "99"
+
+		m := types.Mention{TweetID: "9", Text: "@bot pay @dave", Entities: &types.MentionEntities{Mentions: []types.MentionedUser{
+			{ID: "99", Username: "bot"}, {ID: "42", Username: "dave"},
+		}}}
+		assert.Equal(t, []string{"42"}, ids(r.Mentioned(ctx, m)))
+		assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+	})
+}
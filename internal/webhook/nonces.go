@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"cg-mentions-bot/internal/utils/db"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// NonceStore remembers nonces until they can no longer pass the timestamp check.
+type NonceStore interface {
+	// Use records nonce until expiresAt and reports whether it was unseen.
+	Use(ctx context.Context, nonce string, expiresAt time.Time) (bool, error)
+}
+
+// MemoryNonces is a process-local NonceStore.
+type MemoryNonces struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// NewMemoryNonces returns an empty in-memory NonceStore.
+func NewMemoryNonces() *MemoryNonces {
+	return &MemoryNonces{seen: make(map[string]time.Time)}
+}
+
+func (n *MemoryNonces) Use(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := time.Now()
+	for k, exp := range n.seen {
+		if exp.Before(now) {
+			delete(n.seen, k)
+		}
+	}
+	if _, ok := n.seen[nonce]; ok {
+		return false, nil
+	}
+	n.seen[nonce] = expiresAt
+	return true, nil
+}
+
+// MongoNonces shares seen nonces between bot instances via xreplyagent.webhook_nonces.
+type MongoNonces struct {
+	coll *mongo.Collection
+}
+
+// NewMongoNonces returns a Mongo NonceStore and ensures its TTL index.
+func NewMongoNonces(client *mongo.Client) (*MongoNonces, error) {
+	mg := db.MongoDB{
+		Database:   "xreplyagent",
+		Collection: "webhook_nonces",
+	}
+	coll := client.Database(mg.Database).Collection(mg.Collection)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}); err != nil {
+		return nil, err
+	}
+	return &MongoNonces{coll: coll}, nil
+}
+
+func (n *MongoNonces) Use(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	_, err := n.coll.InsertOne(ctx, bson.M{"_id": nonce, "expires_at": expiresAt})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"cg-mentions-bot/internal/webhook"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func signedRequest(secret string, ts time.Time, nonce string, body string) *http.Request {
+	stamp := strconv.FormatInt(ts.Unix(), 10)
+	r := httptest.NewRequest(http.MethodPost, "/mentions", nil)
+	r.Header.Set(webhook.HeaderTimestamp, stamp)
+	r.Header.Set(webhook.HeaderNonce, nonce)
+	r.Header.Set(webhook.HeaderSignature, webhook.Sign(secret, stamp, nonce, []byte(body)))
+	return r
+}
+
+func TestVerifier(t *testing.T) {
+	body := `{"count":0,"mentions":[]}`
+	newVerifier := func() *webhook.Verifier {
+		return &webhook.Verifier{Secrets: []string{"old", "new"}, Nonces: webhook.NewMemoryNonces()}
+	}
+
+	t.Run("Any active secret is accepted during rotation", func(t *testing.T) {
+		v := newVerifier()
+		assert.NoError(t, v.Verify(context.Background(), signedRequest("old", time.Now(), "n1", body), []byte(body)))
+		assert.NoError(t, v.Verify(context.Background(), signedRequest("new", time.Now(), "n2", body), []byte(body)))
+	})
+
+	t.Run("Unknown secrets and tampered bodies are rejected", func(t *testing.T) {
+		v := newVerifier()
+		assert.ErrorIs(t, v.Verify(context.Background(), signedRequest("retired", time.Now(), "n1", body), []byte(body)), webhook.ErrBadSignature)
+		assert.ErrorIs(t, v.Verify(context.Background(), signedRequest("new", time.Now(), "n2", body), []byte(body+" ")), webhook.ErrBadSignature)
+	})
+
+	t.Run("Stale timestamps and reused nonces are rejected", func(t *testing.T) {
+		v := newVerifier()
+		stale := signedRequest("new", time.Now().Add(-10*time.Minute), "n1", body)
+		assert.ErrorIs(t, v.Verify(context.Background(), stale, []byte(body)), webhook.ErrStaleTimestamp)
+
+		assert.NoError(t, v.Verify(context.Background(), signedRequest("new", time.Now(), "n2", body), []byte(body)))
+		assert.ErrorIs(t, v.Verify(context.Background(), signedRequest("new", time.Now(), "n2", body), []byte(body)), webhook.ErrReplayedNonce)
+	})
+}